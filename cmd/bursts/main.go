@@ -0,0 +1,119 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/tknie/bitgartentools"
+	"github.com/tknie/bitgartentools/tools"
+	"github.com/tknie/log"
+	"github.com/tknie/services"
+)
+
+const description = `This tool searches bursts and sequences of near-identical frames.
+Frames of the same camera model taken within the gap and with small
+perception hash distance are grouped. Each group is ranked by quality
+and all but the best frames are tagged or marked deleted.
+`
+
+func init() {
+	services.ServerMessage("Start Bursts application %s (build at %s)", bitgartentools.BuildVersion, bitgartentools.BuildDate)
+
+	err := log.InitZapLogWithFilename("bursts.log")
+	if err != nil {
+		fmt.Printf("Error initialzing logging: %v\n", err)
+		return
+	}
+}
+
+func main() {
+	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
+	var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+	parameter := &tools.BurstParameter{}
+	var policyFile string
+	flag.IntVar(&parameter.Gap, "g", tools.DefaultBurstGap, "Maximal seconds between two frames of a burst")
+	flag.IntVar(&parameter.Distance, "d", tools.DefaultBurstDistance, "Maximal perception hash distance between two frames")
+	flag.IntVar(&parameter.MinCount, "m", tools.DefaultBurstMinCount, "Minimal number of frames of a burst")
+	flag.IntVar(&parameter.Keep, "k", tools.DefaultBurstKeep, "Number of best frames kept")
+	flag.IntVar(&parameter.Limit, "l", 0, "Maximum number of bursts handled")
+	flag.StringVar(&parameter.Tag, "tag", "", "Tag all but the best frames with the given tag")
+	flag.BoolVar(&parameter.Delete, "D", false, "Mark delete all but the best frames")
+	flag.StringVar(&parameter.Report, "report", "", "Review mode, write HTML report of the proposed decisions to the given file")
+	flag.StringVar(&parameter.Decisions, "decisions", "", "Review mode, write the proposed decisions to the given JSON file")
+	flag.StringVar(&policyFile, "P", "", "Retention policy YAML file deciding frames of same quality")
+	flag.BoolVar(&parameter.Commit, "C", false, "Enable commit to database")
+	flag.BoolVar(&parameter.Json, "j", false, "Output in JSON format")
+	flag.Usage = func() {
+		fmt.Print(description)
+		fmt.Println("Default flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	bitgartentools.InitTool("bursts", parameter.Json)
+	var err error
+	defer bitgartentools.FinalizeTool("bursts", parameter.Json, err)
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			panic("could not create CPU profile: " + err.Error())
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			panic("could not start CPU profile: " + err.Error())
+		}
+		defer pprof.StopCPUProfile()
+	}
+	defer writeMemProfile(*memprofile)
+
+	if policyFile != "" {
+		parameter.Policy, err = tools.LoadRetentionPolicy(policyFile)
+		if err != nil {
+			fmt.Println("Error loading retention policy:", err)
+			return
+		}
+	}
+	err = tools.Bursts(parameter)
+	if err != nil {
+		fmt.Println("Error searching bursts:", err)
+	}
+	log.Log.Debugf("Error bursts: %v", err)
+}
+
+func writeMemProfile(file string) {
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			panic("could not create memory profile: " + err.Error())
+		}
+		runtime.GC() // get up-to-date statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			panic("could not write memory profile: " + err.Error())
+		}
+		defer f.Close()
+		fmt.Println("Memory profile written")
+	}
+
+}
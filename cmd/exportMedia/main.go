@@ -32,7 +32,18 @@ import (
 	"github.com/tknie/services"
 )
 
-const description = `This tool exports all files into a directory
+const description = `This tool exports all files into a directory.
+With -a or -all-albums each album is written into a folder with the
+pictures ordered by album index and an album.json manifest, which can
+be re-imported with -import.
+With -archive the media is streamed into a ZIP or TAR archive instead
+of a directory, '-' writes a TAR archive to stdout.
+With -mirror the directory is kept as mirror with a manifest, only new
+pictures are written and a rotating sample is verified on each run.
+With -xmp tags, album captions, rating, GPS and capture time are written
+into XMP sidecars, -embed writes them into the exported JPEG copies.
+With -privacy the GPS position and serial numbers of the exported copies
+are filtered by the privacy profile.
  `
 
 func init() {
@@ -59,11 +70,39 @@ func main() {
 	directory := ""
 	markDelete := false
 	workers := 2
+	album := ""
+	allAlbums := false
+	importDirectory := ""
+	pathTemplate := ""
+	archive := ""
+	archiveFormat := ""
+	manifest := false
+	mirror := false
+	prune := false
+	quarantine := ""
+	verifySample := tools.DefaultVerifySample
+	sidecar := false
+	embed := false
+	privacyFile := ""
 	flag.IntVar(&limit, "l", 10, "Maximum records to read (0 is all)")
 	flag.IntVar(&workers, "t", 2, "Maximum number of workers writing media")
 	flag.BoolVar(&json, "j", false, "Output in JSON format")
 	flag.BoolVar(&markDelete, "D", false, "Search include mark deleted")
 	flag.StringVar(&directory, "d", "", "Write files to directory")
+	flag.StringVar(&pathTemplate, "p", tools.DefaultExportPath, "Path template of the exported files, fields are Year, Month, Day, Date, Camera, Make, Album, Tags, Tag, Checksum, Title, Name, Ext and Initial")
+	flag.StringVar(&archive, "archive", "", "Stream media into the ZIP or TAR archive file, '-' is stdout")
+	flag.StringVar(&archiveFormat, "archive-format", "", "Archive format zip or tar, default derived of the archive name")
+	flag.BoolVar(&manifest, "manifest", false, "Add a manifest.json with all exported files to the archive")
+	flag.BoolVar(&mirror, "mirror", false, "Maintain the directory as mirror with manifest, only new pictures are written")
+	flag.BoolVar(&prune, "prune", false, "Remove mirror files of pictures deleted or marked deleted (needs -l 0)")
+	flag.StringVar(&quarantine, "quarantine", "", "Move mirror files of removed pictures into the quarantine directory (needs -l 0)")
+	flag.IntVar(&verifySample, "verify", tools.DefaultVerifySample, "Number of mirror files verified in each run")
+	flag.BoolVar(&sidecar, "xmp", false, "Write XMP sidecars with tags, caption, rating, GPS and capture time")
+	flag.BoolVar(&embed, "embed", false, "Embed XMP and IPTC metadata into the exported JPEG copies")
+	flag.StringVar(&privacyFile, "privacy", "", "Privacy profile YAML file filtering GPS and serial numbers of the exported copies")
+	flag.StringVar(&album, "a", "", "Export the album with the given title into an album folder")
+	flag.BoolVar(&allAlbums, "all-albums", false, "Export all albums into album folders")
+	flag.StringVar(&importDirectory, "import", "", "Re-import the exported album folder")
 	flag.Parse()
 	var err error
 
@@ -82,10 +121,33 @@ func main() {
 	}
 	defer writeMemProfile(*memprofile)
 
-	tools.StartExport(workers)
+	var privacy *tools.PrivacyProfile
+	if privacyFile != "" {
+		privacy, err = tools.LoadPrivacyProfile(privacyFile)
+		if err != nil {
+			fmt.Println("Error loading privacy profile:", err)
+			return
+		}
+	}
 
-	err = tools.ExportMedia(&tools.ExportMediaParameter{Limit: limit, MarkDelete: markDelete,
-		Directory: directory})
+	switch {
+	case importDirectory != "":
+		err = tools.ImportAlbum(importDirectory)
+	case album != "" || allAlbums:
+		err = tools.ExportAlbums(&tools.ExportMediaParameter{Directory: directory,
+			Album: album, AllAlbums: allAlbums, Archive: archive, ArchiveFormat: archiveFormat,
+			Sidecar: sidecar, Embed: embed, Privacy: privacy})
+	default:
+		if archive == "" {
+			tools.StartExport(workers)
+		}
+
+		err = tools.ExportMedia(&tools.ExportMediaParameter{Limit: limit, MarkDelete: markDelete,
+			Directory: directory, PathTemplate: pathTemplate, Archive: archive,
+			ArchiveFormat: archiveFormat, Manifest: manifest, Mirror: mirror, Prune: prune,
+			Quarantine: quarantine, VerifySample: verifySample, Sidecar: sidecar, Embed: embed,
+			Privacy: privacy})
+	}
 	if err != nil {
 		fmt.Println("Export Media error:", err)
 	}
@@ -49,19 +49,35 @@ func init() {
 func main() {
 	var limit int
 	var minCount int
+	var distance int
+	var video bool
+	var orientation bool
 	var heicclean bool
 	var nameclean bool
 	var commit bool
 	var jsonResult bool
 	var title string
+	var report string
+	var decisions string
+	var apply string
+	var policyFile string
+	var namesFile string
 
 	flag.IntVar(&limit, "l", tools.DefaultLimit, "Maximum number of records loaded")
 	flag.IntVar(&minCount, "m", tools.DefaultMinCount, "Minimum number of count per hash")
+	flag.IntVar(&distance, "d", 0, "Maximal Hamming distance of average, perception and difference hash for near-duplicates (0 is exact perception hash)")
 	//	flag.StringVar(&hashType, "h", "", "Hash type to use, valid are (averageHash,perceptHash,diffHash,waveletHash), default perceptHash")
 	flag.BoolVar(&commit, "C", false, "Enable commit to database")
 	flag.BoolVar(&sql.ExitOnError, "E", false, "Exit if an error happens")
 	flag.BoolVar(&heicclean, "H", false, "Cleanup heic images")
+	flag.BoolVar(&video, "V", false, "Cleanup similar videos using the video fingerprints, -d is the average frame hash distance")
+	flag.BoolVar(&orientation, "R", false, "Cleanup rotated or mirrored copies using the orientHash hashes, -d is the perception hash distance")
 	flag.BoolVar(&nameclean, "N", false, "Cleanup images dependant on names and hash by checking and compare. Let the biggest image.")
+	flag.StringVar(&report, "report", "", "Review mode, write HTML report of the proposed decisions to the given file")
+	flag.StringVar(&decisions, "decisions", "", "Review mode, write the proposed decisions to the given JSON file")
+	flag.StringVar(&policyFile, "P", "", "Retention policy YAML file deciding which picture of a group is kept")
+	flag.StringVar(&namesFile, "names", "", "Derived name rules YAML file used by the name clean")
+	flag.StringVar(&apply, "apply", "", "Apply the reviewed decisions of the given JSON file")
 	flag.StringVar(&title, "t", "", "Specific title to be searched for")
 	flag.BoolVar(&jsonResult, "j", false, "return output in JSON format")
 	flag.Usage = func() {
@@ -75,15 +91,37 @@ func main() {
 	var err error
 	defer bitgartentools.FinalizeTool("hashclean", jsonResult, err)
 
+	policy := tools.DefaultRetentionPolicy
+	if policyFile != "" {
+		policy, err = tools.LoadRetentionPolicy(policyFile)
+		if err != nil {
+			fmt.Println("Error loading retention policy:", err)
+			return
+		}
+	}
+
+	rules := tools.DefaultDerivedNameRules
+	if namesFile != "" {
+		rules, err = tools.LoadDerivedNameRules(namesFile)
+		if err != nil {
+			fmt.Println("Error loading derived name rules:", err)
+			return
+		}
+	}
+
 	switch {
+	case apply != "":
+		err = tools.ApplyDecisions(apply, policy, commit)
 	case nameclean:
 		err = tools.NameClean(&tools.NameCleanParameter{Limit: limit, MinCount: minCount, Title: title,
-			Commit: commit, Json: jsonResult})
+			Policy: policy, Rules: rules, Commit: commit, Json: jsonResult})
 	case heicclean:
 		err = tools.HeicClean(&tools.HashCleanParameter{Limit: limit, MinCount: minCount, Title: title,
 			Commit: commit, Json: jsonResult})
 	default:
-		err = tools.HashClean(&tools.HashCleanParameter{Limit: limit, MinCount: minCount, Commit: commit, Json: jsonResult})
+		err = tools.HashClean(&tools.HashCleanParameter{Limit: limit, MinCount: minCount, Distance: distance,
+			Video: video, Orientation: orientation, Report: report, Decisions: decisions,
+			Policy: policy, Commit: commit, Json: jsonResult})
 	}
 	log.Log.Debugf("Error processing hashclean: %v", err)
 }
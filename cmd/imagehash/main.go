@@ -59,14 +59,26 @@ func main() {
 	hashType := tools.Hashes[tools.DefaultHash]
 	jsonResult := false
 	commit := false
+	video := false
+	frames := tools.DefaultVideoFrames
+	workers := tools.DefaultHashWorkers
+	batchSize := tools.DefaultHashBatch
+	source := tools.HashSourceMedia
+	verify := 0
 
 	flag.IntVar(&limit, "l", 50, "Maximum number of records loaded")
 	flag.StringVar(&preFilter, "f", "", "Prefix of title used in search")
 	flag.BoolVar(&deleted, "D", false, "Scan deleted pictures as well")
-	flag.BoolVar(&all, "A", false, "Scan all pictures (no limit to one week)")
+	flag.BoolVar(&all, "A", false, "Re-hash all pictures, not only pictures with missing or stale hash version")
 	flag.BoolVar(&jsonResult, "j", false, "return output in JSON format")
 	flag.BoolVar(&commit, "C", false, "commit all changes")
-	flag.StringVar(&hashType, "h", tools.Hashes[tools.DefaultHash], "Hash type to use, valid are (averageHash,perceptHash,diffHash,waveletHash), default perceptHash")
+	flag.IntVar(&workers, "t", tools.DefaultHashWorkers, "Number of workers decoding and hashing pictures")
+	flag.IntVar(&batchSize, "b", tools.DefaultHashBatch, "Number of pictures inserted in one transaction")
+	flag.StringVar(&source, "s", tools.HashSourceMedia, "Source of the hash, valid are (media,thumbnail)")
+	flag.IntVar(&verify, "verify", 0, "Verify on the given number of random pictures that media and thumbnail hashes agree")
+	flag.BoolVar(&video, "V", false, "Generate video fingerprints instead of image hashes")
+	flag.IntVar(&frames, "n", tools.DefaultVideoFrames, "Number of keyframes hashed per video")
+	flag.StringVar(&hashType, "h", tools.Hashes[tools.DefaultHash], "Comma separated hash types to use, valid are (averageHash,perceptHash,diffHash,waveletHash,orientHash) with optional bit size suffix like perceptHash256, perceptHash contains average and difference hash as well, orientHash contains the hashes of all rotations and mirrors")
 	flag.Usage = func() {
 		fmt.Print(description)
 		fmt.Println("Default flags:")
@@ -99,8 +111,18 @@ func main() {
 		}, infoMap)
 	}
 
-	err = tools.ImageHash(&tools.ImageHashParameter{Limit: limit, HashType: hashType,
-		Deleted: deleted, All: all, PreFilter: preFilter, Json: jsonResult, Commit: commit})
+	switch {
+	case video:
+		err = tools.VideoHash(&tools.VideoHashParameter{Limit: limit, Frames: frames,
+			All: all, Commit: commit})
+	case verify > 0:
+		_, err = tools.VerifyHashSource(&tools.ImageHashParameter{HashType: hashType,
+			Verify: verify, Json: jsonResult})
+	default:
+		err = tools.ImageHash(&tools.ImageHashParameter{Limit: limit, HashType: hashType,
+			Deleted: deleted, All: all, PreFilter: preFilter, Source: source, Workers: workers,
+			BatchSize: batchSize, Json: jsonResult, Commit: commit})
+	}
 	if err != nil {
 		fmt.Printf("Error generating image hash: %v\n", err)
 	}
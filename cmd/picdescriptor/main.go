@@ -0,0 +1,104 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/tknie/bitgartentools"
+	"github.com/tknie/bitgartentools/tools"
+	"github.com/tknie/log"
+	"github.com/tknie/services"
+)
+
+const description = `This tool generates the visual descriptors BlurHash, average colour
+and dominant colour palette out of the picture thumbnails.
+`
+
+func init() {
+	services.ServerMessage("Start Picture Descriptor application %s (build at %s)", bitgartentools.BuildVersion, bitgartentools.BuildDate)
+
+	err := log.InitZapLogWithFilename("picdescriptor.log")
+	if err != nil {
+		fmt.Printf("Error initialzing logging: %v\n", err)
+		return
+	}
+}
+
+func main() {
+	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
+	var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+	var chksum string
+	var commit bool
+	var all bool
+	var limit int
+	json := false
+	flag.StringVar(&chksum, "c", "", "Search for picture id checksum")
+	flag.IntVar(&limit, "l", 0, "Maximum pictures to process (0 is all)")
+	flag.BoolVar(&all, "A", false, "Regenerate descriptors already available")
+	flag.BoolVar(&commit, "C", false, "Commit updates")
+	flag.BoolVar(&json, "j", false, "Output in JSON format")
+	flag.Usage = func() {
+		fmt.Print(description)
+		fmt.Println("Default flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	bitgartentools.InitTool("picDescriptor", json)
+	var err error
+	defer bitgartentools.FinalizeTool("picDescriptor", json, err)
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			panic("could not create CPU profile: " + err.Error())
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			panic("could not start CPU profile: " + err.Error())
+		}
+		defer pprof.StopCPUProfile()
+	}
+	defer writeMemProfile(*memprofile)
+
+	err = tools.Descriptor(&tools.DescriptorParameter{ChkSum: chksum, Limit: limit,
+		All: all, Commit: commit})
+	log.Log.Debugf("Error descriptor creation: %v", err)
+}
+
+func writeMemProfile(file string) {
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			panic("could not create memory profile: " + err.Error())
+		}
+		runtime.GC() // get up-to-date statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			panic("could not write memory profile: " + err.Error())
+		}
+		defer f.Close()
+		fmt.Println("Memory profile written")
+	}
+
+}
@@ -60,6 +60,8 @@ func main() {
 	var albumid int
 	var insertAlbum bool
 	var json bool
+	var videoProxy bool
+	var proxyRes int
 	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
 	var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
 
@@ -73,6 +75,8 @@ func main() {
 	flag.StringVar(&binarySize, "b", "500MB", "Maximum binary blob size")
 	flag.BoolVar(&sql.ExitOnError, "E", false, "Exit if an error happens")
 	flag.BoolVar(&json, "j", false, "Output in JSON format")
+	flag.BoolVar(&videoProxy, "P", false, "Transcode web-friendly proxies of loaded videos")
+	flag.IntVar(&proxyRes, "r", tools.DefaultProxyResolution, "Max width or height of the video proxy")
 	flag.Usage = func() {
 		fmt.Print(description)
 		fmt.Println("Default flags:")
@@ -125,7 +129,8 @@ func main() {
 		NrThreadStorer: nrThreadStorer, MaxBlobSize: sz, Filter: filter,
 		AlbumId: albumid, InsertAlbum: insertAlbum,
 		ShortenPath: shortenPath, FileName: fileName,
-		Directories: directories, Json: json})
+		Directories: directories, Json: json,
+		VideoProxy: videoProxy, ProxyRes: proxyRes})
 	log.Log.Debugf("Error loading data: %v", err)
 }
 
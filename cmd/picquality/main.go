@@ -0,0 +1,115 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/tknie/bitgartentools"
+	"github.com/tknie/bitgartentools/tools"
+	"github.com/tknie/log"
+	"github.com/tknie/services"
+)
+
+const description = `This tool calculates the image quality score out of sharpness
+(Laplacian variance), exposure and clipping, noise and resolution.
+The score is used by hashclean as tie-breaker and to select the
+album cover.
+`
+
+func init() {
+	services.ServerMessage("Start Picture Quality application %s (build at %s)", bitgartentools.BuildVersion, bitgartentools.BuildDate)
+
+	err := log.InitZapLogWithFilename("picquality.log")
+	if err != nil {
+		fmt.Printf("Error initialzing logging: %v\n", err)
+		return
+	}
+}
+
+func main() {
+	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
+	var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+	var chksum string
+	var album string
+	var commit bool
+	var all bool
+	var covers bool
+	var limit int
+	json := false
+	flag.StringVar(&chksum, "c", "", "Search for picture id checksum")
+	flag.StringVar(&album, "a", "", "Select cover with best quality for album title")
+	flag.BoolVar(&covers, "covers", false, "Select cover with best quality for all albums")
+	flag.IntVar(&limit, "l", 0, "Maximum pictures to process (0 is all)")
+	flag.BoolVar(&all, "A", false, "Recalculate quality scores already available")
+	flag.BoolVar(&commit, "C", false, "Commit updates")
+	flag.BoolVar(&json, "j", false, "Output in JSON format")
+	flag.Usage = func() {
+		fmt.Print(description)
+		fmt.Println("Default flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	bitgartentools.InitTool("picQuality", json)
+	var err error
+	defer bitgartentools.FinalizeTool("picQuality", json, err)
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			panic("could not create CPU profile: " + err.Error())
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			panic("could not start CPU profile: " + err.Error())
+		}
+		defer pprof.StopCPUProfile()
+	}
+	defer writeMemProfile(*memprofile)
+
+	if album != "" || covers {
+		err = tools.AlbumCover(album, commit)
+		log.Log.Debugf("Error album cover: %v", err)
+		return
+	}
+	err = tools.Quality(&tools.QualityParameter{ChkSum: chksum, Limit: limit,
+		All: all, Commit: commit})
+	log.Log.Debugf("Error quality score: %v", err)
+}
+
+func writeMemProfile(file string) {
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			panic("could not create memory profile: " + err.Error())
+		}
+		runtime.GC() // get up-to-date statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			panic("could not write memory profile: " + err.Error())
+		}
+		defer f.Close()
+		fmt.Println("Memory profile written")
+	}
+
+}
@@ -0,0 +1,123 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/tknie/bitgartentools"
+	"github.com/tknie/bitgartentools/tools"
+	"github.com/tknie/log"
+	"github.com/tknie/services"
+)
+
+const description = `This tool restores pictures marked deleted by hashclean or heicthumb.
+All mark delete changes are recorded in the journal with the run id of
+the tool. A whole run or selected pictures can be restored.
+`
+
+func init() {
+	services.ServerMessage("Start Restore application %s (build at %s)", bitgartentools.BuildVersion, bitgartentools.BuildDate)
+
+	err := log.InitZapLogWithFilename("restore.log")
+	if err != nil {
+		fmt.Printf("Error initialzing logging: %v\n", err)
+		return
+	}
+}
+
+func main() {
+	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
+	var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+	var runID string
+	var chksums string
+	var list int
+	var commit bool
+	json := false
+	flag.StringVar(&runID, "r", "", "Run id to be restored")
+	flag.StringVar(&chksums, "c", "", "Comma separated list of picture checksums to be restored")
+	flag.IntVar(&list, "list", 0, "List the given number of last runs recorded in the journal")
+	flag.BoolVar(&commit, "C", false, "Commit restore, otherwise dry run")
+	flag.BoolVar(&json, "j", false, "Output in JSON format")
+	flag.Usage = func() {
+		fmt.Print(description)
+		fmt.Println("Default flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	bitgartentools.InitTool("restore", json)
+	var err error
+	defer bitgartentools.FinalizeTool("restore", json, err)
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			panic("could not create CPU profile: " + err.Error())
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			panic("could not start CPU profile: " + err.Error())
+		}
+		defer pprof.StopCPUProfile()
+	}
+	defer writeMemProfile(*memprofile)
+
+	if list > 0 {
+		runs, err := tools.JournalRuns(list)
+		if err != nil {
+			fmt.Println("Error reading journal:", err)
+			return
+		}
+		for _, r := range runs {
+			fmt.Printf("%-45s %-12s %s pictures=%d restored=%d\n", r.Runid, r.Tool, r.Started, r.Pictures, r.Restored)
+		}
+		return
+	}
+	checksums := make([]string, 0)
+	if chksums != "" {
+		checksums = strings.Split(chksums, ",")
+	}
+	err = tools.Restore(&tools.RestoreParameter{RunID: runID, Checksums: checksums, Commit: commit})
+	if err != nil {
+		fmt.Println("Error restoring:", err)
+	}
+	log.Log.Debugf("Error restore: %v", err)
+}
+
+func writeMemProfile(file string) {
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			panic("could not create memory profile: " + err.Error())
+		}
+		runtime.GC() // get up-to-date statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			panic("could not write memory profile: " + err.Error())
+		}
+		defer f.Close()
+		fmt.Println("Memory profile written")
+	}
+
+}
@@ -32,7 +32,9 @@ import (
 	"github.com/tknie/services"
 )
 
-const description = `This tool create thumbnails for videos.
+const description = `This tool create thumbnails for videos. Optional an animated
+GIF preview and a sprite sheet with timing manifest for hover-scrubbing
+are stored as renditions of the video.
 `
 
 func init() {
@@ -51,9 +53,13 @@ func main() {
 	var chksum string
 	var title string
 	var commit bool
+	var previewFrames int
+	var spriteFrames int
 	json := false
 	flag.StringVar(&chksum, "c", "", "Search for picture id checksum")
 	flag.StringVar(&title, "a", "", "Search for album title")
+	flag.IntVar(&previewFrames, "p", 0, "Number of frames of the animated GIF preview (0 is no preview)")
+	flag.IntVar(&spriteFrames, "s", 0, "Number of frames of the scrubbing sprite sheet (0 is no sprite sheet)")
 	flag.BoolVar(&commit, "C", false, "Commit updates")
 	flag.BoolVar(&json, "j", false, "Output in JSON format")
 	flag.Usage = func() {
@@ -79,7 +85,8 @@ func main() {
 	}
 	defer writeMemProfile(*memprofile)
 
-	err = tools.VideoThumb(&tools.VideoThumbParameter{Title: title, ChkSum: chksum, Commit: commit})
+	err = tools.VideoThumb(&tools.VideoThumbParameter{Title: title, ChkSum: chksum,
+		PreviewFrames: previewFrames, SpriteFrames: spriteFrames, Commit: commit})
 	log.Log.Debugf("Error video thumb creation: %v", err)
 }
 
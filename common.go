@@ -20,6 +20,7 @@ package bitgartentools
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/tknie/services"
@@ -28,7 +29,15 @@ import (
 // TimeFormat time formating schema
 const TimeFormat = "2006-01-02 15:04:05"
 
+// ToolName name of the running tool
+var ToolName = "unknown"
+
+// RunID identifies the run of the tool, e.g. in the mark delete journal
+var RunID = fmt.Sprintf("unknown-%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+
 func InitTool(toolName string, json bool) {
+	ToolName = toolName
+	RunID = fmt.Sprintf("%s-%s-%d", toolName, time.Now().Format("20060102-150405"), os.Getpid())
 	if json {
 		fmt.Printf("{\"start\":\"%s\",\"tool\":\"%s\",", time.Now().Format(TimeFormat), toolName)
 		return
@@ -33,7 +33,7 @@ CREATE OR REPLACE VIEW public.vsearchpicdoublikates
 AS SELECT count(hash) AS count,
     hash
    FROM picturehash p
-  WHERE (EXISTS ( SELECT 1
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
            FROM pictures pp
           WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
   GROUP BY hash
@@ -17,6 +17,215 @@ GRANT SELECT ON TABLE public.picture TO read_album_role;
 -- public.valbums source
 ALTER TABLE public.albums ADD "locked" bool DEFAULT false NOT NULL;
 ALTER TABLE public.albums ADD collection bool DEFAULT false NOT NULL;
+ALTER TABLE public.pictures ADD proxychecksum varchar(40) NULL;
+ALTER TABLE public.pictures ADD blurhash varchar(64) NULL;
+ALTER TABLE public.pictures ADD averagecolor varchar(7) NULL;
+ALTER TABLE public.pictures ADD dominantcolors varchar(255) NULL;
+ALTER TABLE public.pictures ADD quality float8 NULL;
+CREATE INDEX pictures_quality_idx ON public.pictures USING btree (quality);
+
+-- public.picturehash families: kind is algorithm * 100000 + bit size,
+-- the former standard hashs are 64-bit perception hashs
+
+ALTER TABLE public.picturehash ADD exthash text NULL;
+ALTER TABLE public.picturehash DROP CONSTRAINT picturehash_unique;
+ALTER TABLE public.picturehash ADD CONSTRAINT picturehash_unique UNIQUE (checksumpicture, kind);
+UPDATE public.picturehash SET kind = 200064 WHERE kind = 0;
+UPDATE public.batch_repo SET query = decode('73656C6563740A09636F756E742868617368292C0A09686173680A66726F6D0A09706963747572656861736820700A77686572650A09702E6B696E64203D203230303036340A09616E6420657869737473280A0973656C6563740A0909310A0966726F6D0A090970696374757265732070700A0977686572650A090970702E636865636B73756D70696374757265203D20702E636865636B73756D706963747572650A0909616E642070702E6D61726B64656C657465203D2066616C7365290A67726F75702062790A09686173680A686176696E670A09636F756E74286861736829203E20310A6F726465722062790A09636F756E7420646573630A6C696D69742032350A','hex') WHERE "name" = 'SearchDoublikates';
+UPDATE public.batch_repo SET query = decode('73656C6563740A09636865636B73756D706963747572652C0A09736861323536636865636B73756D2C0A094D494D45747970652C0A096F7074696F6E732C0A096D61726B64656C6574652C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A096770736C617469747564652C0A096770736C6F6E6769747564652C0A097469746C652C0A09535452494E475F4147472028612E7461676E616D652C272C270A2020202020206F726465722062790A092020612E7461676E616D650A202020202920746167732C0A20202020636F756E74282A29204F564552282920415320746F74616C5F636F756E742C0A202020202853454C4543542070682E70657263657074696F6E686173682046524F4D2070696374757265686173682070682057484552452070682E636865636B73756D70696374757265203D2074312E636865636B73756D7069637475726520414E442070682E6B696E64203D20323030303634204C494D495420312920415320686173680A66726F6D0A0970696374757265732074310A6C656674206A6F696E20706963747572657461677320610A09097573696E672028636865636B73756D70696374757265290A77686572650A093C77686572653E0A67726F75702062790A09636865636B73756D706963747572652C736861323536636865636B73756D2C094D494D45747970652C0A096F7074696F6E732C0A096D61726B64656C6574652C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A096770736C617469747564652C0A096770736C6F6E6769747564652C0A097469746C650A6F726465722062790A093C6F726465723E0A6C696D6974203C6C696D69743E','hex') WHERE "name" = 'picSearchDynJoin2';
+
+-- views reading picturehash only use the standard hash kind
+
+CREATE OR REPLACE VIEW public.vavgpicdoublikates
+AS SELECT count(averagehash) AS count,
+    averagehash
+   FROM picturehash p
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
+  GROUP BY averagehash
+ HAVING count(averagehash) > 1
+  ORDER BY (count(averagehash)) DESC
+ LIMIT 25;
+
+CREATE OR REPLACE VIEW public.vdifpicdoublikates
+AS SELECT count(differencehash) AS count,
+    differencehash
+   FROM picturehash p
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
+  GROUP BY differencehash
+ HAVING count(differencehash) > 1
+  ORDER BY (count(differencehash)) DESC
+ LIMIT 25;
+
+CREATE OR REPLACE VIEW public.vperpicdoublikates
+AS SELECT count(perceptionhash) AS count,
+    perceptionhash
+   FROM picturehash p
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
+  GROUP BY perceptionhash
+ HAVING count(perceptionhash) > 1
+  ORDER BY (count(perceptionhash)) DESC
+ LIMIT 25;
+
+CREATE OR REPLACE VIEW public.vsearchpicdoublikates
+AS SELECT count(hash) AS count,
+    hash
+   FROM picturehash p
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
+  GROUP BY hash
+ HAVING count(hash) > 1
+  ORDER BY (count(hash)) DESC
+ LIMIT 25;
+
+CREATE OR REPLACE VIEW public.vsearchpictags
+AS SELECT checksumpicture,
+    sha256checksum,
+    mimetype,
+    options,
+    exifxdimension,
+    exifydimension,
+    exiforientation,
+    height,
+    width,
+    exifmodel,
+    exifmake,
+    exiforigtime,
+    created,
+    updated_at,
+    exiftaken,
+    markdelete,
+    title,
+    gpslatitude,
+    gpslongitude,
+    ( SELECT string_agg(DISTINCT (''''::text || p.tagname::text) || ''''::text, ','::text) AS string_agg
+           FROM picturetags p
+          WHERE p.checksumpicture::text = t1.checksumpicture::text) AS tags,
+    ( SELECT ph.perceptionhash
+           FROM picturehash ph
+          WHERE ph.checksumpicture::text = t1.checksumpicture::text AND ph.kind = 200064
+         LIMIT 1) AS hash,
+    count(*) OVER () AS total_count
+   FROM pictures t1;
+
+-- public.picturehash algorithm and version of the hash generation,
+-- rows without version are stale and re-hashed by imagehash
+
+ALTER TABLE public.picturehash ADD algorithm varchar(64) NULL;
+ALTER TABLE public.picturehash ADD "version" varchar(64) NULL;
+UPDATE public.picturehash SET algorithm = 'perceptHash' WHERE kind = 200064;
+CREATE INDEX picturehash_version_idx ON public.picturehash USING btree (kind, version);
+
+-- public.picturehash source of the hashed image (media or thumbnail),
+-- thumbnail hashs are re-hashed from the media by imagehash
+
+ALTER TABLE public.picturehash ADD "source" varchar(16) NULL;
+
+-- public.markdeletejournal records all mark delete changes to be restored
+
+CREATE TABLE public.markdeletejournal (
+	id bigserial NOT NULL,
+	runid varchar(80) NOT NULL,
+	tool varchar(40) NOT NULL,
+	reason varchar(255) NULL,
+	checksumpicture varchar(40) NOT NULL,
+	previous bool DEFAULT false NOT NULL,
+	tags text NULL,
+	moved text NULL,
+	restored timestamp NULL,
+	created timestamp NULL,
+	updated_at timestamp NULL,
+	CONSTRAINT markdeletejournal_pkey PRIMARY KEY (id)
+);
+CREATE INDEX markdeletejournal_runid_idx ON public.markdeletejournal USING btree (runid);
+CREATE INDEX markdeletejournal_checksumpicture_idx ON public.markdeletejournal USING btree (checksumpicture);
+
+-- Table Triggers
+
+create trigger update_timestamp before
+insert
+    or
+update
+    on
+    public.markdeletejournal for each row execute function update_timestamp();
+
+-- Permissions
+
+ALTER TABLE public.markdeletejournal OWNER TO postgres;
+GRANT ALL ON TABLE public.markdeletejournal TO postgres;
+GRANT DELETE, INSERT, UPDATE, SELECT ON TABLE public.markdeletejournal TO admin_album_role;
+GRANT SELECT ON TABLE public.markdeletejournal TO read_album_role;
+
+-- public.picturerenditions
+
+CREATE TABLE public.picturerenditions (
+	id bigserial NOT NULL,
+	checksumpicture varchar(40) NOT NULL,
+	kind varchar(40) NOT NULL,
+	mimetype varchar(255) NOT NULL,
+	checksum varchar(40) NOT NULL,
+	width int4 DEFAULT 0 NOT NULL,
+	height int4 DEFAULT 0 NOT NULL,
+	media bytea NULL,
+	created timestamp NULL,
+	updated_at timestamp NULL,
+	CONSTRAINT picturerenditions_pkey PRIMARY KEY (id),
+	CONSTRAINT picturerenditions_unique UNIQUE (checksumpicture, kind),
+	CONSTRAINT picturerenditions_checksumpicture_fkey FOREIGN KEY (checksumpicture) REFERENCES public.pictures(checksumpicture) ON DELETE RESTRICT ON UPDATE RESTRICT
+);
+
+-- Table Triggers
+
+create trigger update_timestamp before
+insert
+    or
+update
+    on
+    public.picturerenditions for each row execute function update_timestamp();
+
+-- Permissions
+
+ALTER TABLE public.picturerenditions OWNER TO postgres;
+GRANT ALL ON TABLE public.picturerenditions TO postgres;
+GRANT ALL ON TABLE public.picturerenditions TO admin_album_role;
+GRANT SELECT ON TABLE public.picturerenditions TO read_album_role;
+
+-- public.videohash
+
+CREATE TABLE public.videohash (
+	id bigserial NOT NULL,
+	checksumpicture varchar(40) NOT NULL,
+	duration float8 DEFAULT 0 NOT NULL,
+	frames int4 DEFAULT 0 NOT NULL,
+	hashes text NOT NULL,
+	created timestamp NULL,
+	updated_at timestamp NULL,
+	CONSTRAINT videohash_pkey PRIMARY KEY (id),
+	CONSTRAINT videohash_unique UNIQUE (checksumpicture)
+);
+
+-- Table Triggers
+
+create trigger update_timestamp before
+insert
+    or
+update
+    on
+    public.videohash for each row execute function update_timestamp();
+
+-- Permissions
+
+ALTER TABLE public.videohash OWNER TO postgres;
+GRANT ALL ON TABLE public.videohash TO postgres;
+GRANT DELETE, INSERT, UPDATE, SELECT ON TABLE public.videohash TO admin_album_role;
+GRANT SELECT ON TABLE public.videohash TO read_album_role;
+
 
 -- public.valbums source
 
@@ -44,6 +253,6 @@ INSERT INTO public.batch_repo ("name",query,paramcount,"database") VALUES
 	 ('albumSearch',decode('53454C4543540A092A0A46524F4D0A0976616C62756D7320746E0A4F524445522042590A093C6F726465723E0A4C494D4954203C6C696D69743E','hex'),2,'valbums'),
 	 ('picSearchTag',decode('73656C656374202A2C20636F756E74282A29204F564552282920415320746F74616C5F636F756E740A66726F6D20767365617263687069637461677320746E200A77686572650A093C77686572653E0A6F726465722062790A093C6F726465723E0A6C696D6974203C6C696D69743E','hex'),5,'pictures'),
 	 ('albumSearch2',decode('53454C4543540A0949442C0A095469746C652C0A097075626C69736865642C0A095468756D626E61696C486173680A46524F4D0A09416C62756D7320746E0A57484552450A20205469746C6520213D202744656661756C7420416C62756D270A4F524445522042590A093C6F726465723E0A4C494D4954203C6C696D69743E','hex'),1,'Albums'),
-	 ('SearchDoublikates',decode('73656C6563740A09636F756E742868617368292C0A09686173680A66726F6D0A09706963747572656861736820700A77686572650A09657869737473280A0973656C6563740A0909310A0966726F6D0A090970696374757265732070700A0977686572650A090970702E636865636B73756D70696374757265203D20702E636865636B73756D706963747572650A0909616E642070702E6D61726B64656C657465203D2066616C7365290A67726F75702062790A09686173680A686176696E670A09636F756E74286861736829203E20310A6F726465722062790A09636F756E7420646573630A6C696D69742032350A','hex'),0,'picturehash'),
+	 ('SearchDoublikates',decode('73656C6563740A09636F756E742868617368292C0A09686173680A66726F6D0A09706963747572656861736820700A77686572650A09702E6B696E64203D203230303036340A09616E6420657869737473280A0973656C6563740A0909310A0966726F6D0A090970696374757265732070700A0977686572650A090970702E636865636B73756D70696374757265203D20702E636865636B73756D706963747572650A0909616E642070702E6D61726B64656C657465203D2066616C7365290A67726F75702062790A09686173680A686176696E670A09636F756E74286861736829203E20310A6F726465722062790A09636F756E7420646573630A6C696D69742032350A','hex'),0,'picturehash'),
 	 ('picSearchByTag',decode('73656C6563740A09702E636865636B73756D706963747572652C0A09736861323536636865636B73756D2C0A094D494D45747970652C0A096F7074696F6E732C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A097469746C650A66726F6D0A09706963747572657320702C0A09280A0973656C6563740A0909636865636B73756D706963747572650A0966726F6D0A090970696374757265746167730A0977686572650A09097461676E616D65206C696B6520273C7461676E616D653E272920630A77686572650A09702E636865636B73756D70696374757265203D20632E636865636B73756D706963747572650A09616E64206D61726B64656C657465203D2066616C7365','hex'),1,'pictures'),
-	 ('picSearchDynJoin2',decode('73656C6563740A09636865636B73756D706963747572652C0A09736861323536636865636B73756D2C0A094D494D45747970652C0A096F7074696F6E732C0A096D61726B64656C6574652C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A096770736C617469747564652C0A096770736C6F6E6769747564652C0A097469746C652C0A09535452494E475F4147472028612E7461676E616D652C272C270A2020202020206F726465722062790A092020612E7461676E616D650A202020202920746167732C0A20202020636F756E74282A29204F564552282920415320746F74616C5F636F756E742C0A202020202853454C4543542070682E70657263657074696F6E686173682046524F4D2070696374757265686173682070682057484552452070682E636865636B73756D70696374757265203D2074312E636865636B73756D7069637475726520204C494D495420312920415320686173680A66726F6D0A0970696374757265732074310A6C656674206A6F696E20706963747572657461677320610A09097573696E672028636865636B73756D70696374757265290A77686572650A093C77686572653E0A67726F75702062790A09636865636B73756D706963747572652C736861323536636865636B73756D2C094D494D45747970652C0A096F7074696F6E732C0A096D61726B64656C6574652C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A096770736C617469747564652C0A096770736C6F6E6769747564652C0A097469746C650A6F726465722062790A093C6F726465723E0A6C696D6974203C6C696D69743E','hex'),5,'pictures');
+	 ('picSearchDynJoin2',decode('73656C6563740A09636865636B73756D706963747572652C0A09736861323536636865636B73756D2C0A094D494D45747970652C0A096F7074696F6E732C0A096D61726B64656C6574652C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A096770736C617469747564652C0A096770736C6F6E6769747564652C0A097469746C652C0A09535452494E475F4147472028612E7461676E616D652C272C270A2020202020206F726465722062790A092020612E7461676E616D650A202020202920746167732C0A20202020636F756E74282A29204F564552282920415320746F74616C5F636F756E742C0A202020202853454C4543542070682E70657263657074696F6E686173682046524F4D2070696374757265686173682070682057484552452070682E636865636B73756D70696374757265203D2074312E636865636B73756D7069637475726520414E442070682E6B696E64203D20323030303634204C494D495420312920415320686173680A66726F6D0A0970696374757265732074310A6C656674206A6F696E20706963747572657461677320610A09097573696E672028636865636B73756D70696374757265290A77686572650A093C77686572653E0A67726F75702062790A09636865636B73756D706963747572652C736861323536636865636B73756D2C094D494D45747970652C0A096F7074696F6E732C0A096D61726B64656C6574652C0A09657869667864696D656E73696F6E2C0A09657869667964696D656E73696F6E2C0A09657869666F7269656E746174696F6E2C0A096865696768742C0A0977696474682C0A09657869666D6F64656C2C0A09657869666D616B652C0A09657869666F72696774696D652C0A09637265617465642C0A09757064617465645F61742C0A096578696674616B656E2C0A096D696D65747970652C0A096770736C617469747564652C0A096770736C6F6E6769747564652C0A097469746C650A6F726465722062790A093C6F726465723E0A6C696D6974203C6C696D69743E','hex'),5,'pictures');
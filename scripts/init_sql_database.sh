@@ -164,6 +164,41 @@ CREATE TABLE public.batch_repo (
 ALTER TABLE public.batch_repo OWNER TO "admin";
 GRANT ALL ON TABLE public.batch_repo TO "admin";
 
+# create markdeletejournal
+
+CREATE TABLE public.markdeletejournal (
+	id bigserial NOT NULL,
+	runid varchar(80) NOT NULL,
+	tool varchar(40) NOT NULL,
+	reason varchar(255) NULL,
+	checksumpicture varchar(40) NOT NULL,
+	previous bool DEFAULT false NOT NULL,
+	tags text NULL,
+	moved text NULL,
+	restored timestamp NULL,
+	created timestamp NULL,
+	updated_at timestamp NULL,
+	CONSTRAINT markdeletejournal_pkey PRIMARY KEY (id)
+);
+CREATE INDEX markdeletejournal_runid_idx ON public.markdeletejournal USING btree (runid);
+CREATE INDEX markdeletejournal_checksumpicture_idx ON public.markdeletejournal USING btree (checksumpicture);
+
+-- Table Triggers
+
+create trigger update_timestamp before
+insert
+    or
+update
+    on
+    public.markdeletejournal for each row execute function update_timestamp();
+
+-- Permissions
+
+ALTER TABLE public.markdeletejournal OWNER TO postgres;
+GRANT ALL ON TABLE public.markdeletejournal TO postgres;
+GRANT DELETE, INSERT, UPDATE, SELECT ON TABLE public.markdeletejournal TO admin_album_role;
+GRANT SELECT ON TABLE public.markdeletejournal TO read_album_role;
+
 # create picturehash
 
 CREATE TABLE public.picturehash (
@@ -176,9 +211,14 @@ CREATE TABLE public.picturehash (
 	averagehash numeric DEFAULT 0 NOT NULL,
 	perceptionhash numeric DEFAULT 0 NOT NULL,
 	differencehash numeric DEFAULT 0 NOT NULL,
+	exthash text NULL,
+	algorithm varchar(64) NULL,
+	"version" varchar(64) NULL,
+	"source" varchar(16) NULL,
 	CONSTRAINT picturehash_pkey PRIMARY KEY (id),
-	CONSTRAINT picturehash_unique UNIQUE (checksumpicture)
+	CONSTRAINT picturehash_unique UNIQUE (checksumpicture, kind)
 );
+CREATE INDEX picturehash_version_idx ON public.picturehash USING btree (kind, version);
 
 -- Table Triggers
 
@@ -228,6 +268,40 @@ GRANT SELECT ON TABLE public.picturelocations TO read_album_role;
 GRANT DELETE, INSERT, UPDATE, SELECT ON TABLE public.picturelocations TO anja;
 GRANT DELETE, INSERT, UPDATE, SELECT ON TABLE public.picturelocations TO tkn WITH GRANT OPTION;
 
+# create picturerenditions
+
+CREATE TABLE public.picturerenditions (
+	id bigserial NOT NULL,
+	checksumpicture varchar(40) NOT NULL,
+	kind varchar(40) NOT NULL,
+	mimetype varchar(255) NOT NULL,
+	checksum varchar(40) NOT NULL,
+	width int4 DEFAULT 0 NOT NULL,
+	height int4 DEFAULT 0 NOT NULL,
+	media bytea NULL,
+	created timestamp NULL,
+	updated_at timestamp NULL,
+	CONSTRAINT picturerenditions_pkey PRIMARY KEY (id),
+	CONSTRAINT picturerenditions_unique UNIQUE (checksumpicture, kind),
+	CONSTRAINT picturerenditions_checksumpicture_fkey FOREIGN KEY (checksumpicture) REFERENCES public.pictures(checksumpicture) ON DELETE RESTRICT ON UPDATE RESTRICT
+);
+
+-- Table Triggers
+
+create trigger update_timestamp before
+insert
+    or
+update
+    on
+    public.picturerenditions for each row execute function update_timestamp();
+
+-- Permissions
+
+ALTER TABLE public.picturerenditions OWNER TO postgres;
+GRANT ALL ON TABLE public.picturerenditions TO postgres;
+GRANT ALL ON TABLE public.picturerenditions TO admin_album_role;
+GRANT SELECT ON TABLE public.picturerenditions TO read_album_role;
+
 # create pictures
 
 CREATE TABLE public.pictures (
@@ -256,6 +330,11 @@ CREATE TABLE public.pictures (
 	gpscoordinates varchar(100) NULL,
 	gpslatitude float8 DEFAULT 0 NOT NULL,
 	gpslongitude float8 DEFAULT 0 NOT NULL,
+	proxychecksum varchar(40) NULL,
+	blurhash varchar(64) NULL,
+	averagecolor varchar(7) NULL,
+	dominantcolors varchar(255) NULL,
+	quality float8 NULL,
 	CONSTRAINT pictures_checksumpicture_key UNIQUE (checksumpicture),
 	CONSTRAINT pictures_pkey PRIMARY KEY (id),
 	CONSTRAINT pictures_sha256checksum_key UNIQUE (sha256checksum)
@@ -360,6 +439,36 @@ CREATE TABLE public.user_info (
 ALTER TABLE public.user_info OWNER TO "admin";
 GRANT ALL ON TABLE public.user_info TO "admin";
 
+# create videohash
+
+CREATE TABLE public.videohash (
+	id bigserial NOT NULL,
+	checksumpicture varchar(40) NOT NULL,
+	duration float8 DEFAULT 0 NOT NULL,
+	frames int4 DEFAULT 0 NOT NULL,
+	hashes text NOT NULL,
+	created timestamp NULL,
+	updated_at timestamp NULL,
+	CONSTRAINT videohash_pkey PRIMARY KEY (id),
+	CONSTRAINT videohash_unique UNIQUE (checksumpicture)
+);
+
+-- Table Triggers
+
+create trigger update_timestamp before
+insert
+    or
+update
+    on
+    public.videohash for each row execute function update_timestamp();
+
+-- Permissions
+
+ALTER TABLE public.videohash OWNER TO postgres;
+GRANT ALL ON TABLE public.videohash TO postgres;
+GRANT DELETE, INSERT, UPDATE, SELECT ON TABLE public.videohash TO admin_album_role;
+GRANT SELECT ON TABLE public.videohash TO read_album_role;
+
 -- public.audit source
 
 CREATE OR REPLACE VIEW public.audit
@@ -445,7 +554,7 @@ CREATE OR REPLACE VIEW public.vavgpicdoublikates
 AS SELECT count(averagehash) AS count,
     averagehash
    FROM picturehash p
-  WHERE (EXISTS ( SELECT 1
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
            FROM pictures pp
           WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
   GROUP BY averagehash
@@ -464,7 +573,7 @@ CREATE OR REPLACE VIEW public.vdifpicdoublikates
 AS SELECT count(differencehash) AS count,
     differencehash
    FROM picturehash p
-  WHERE (EXISTS ( SELECT 1
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
            FROM pictures pp
           WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
   GROUP BY differencehash
@@ -483,7 +592,7 @@ CREATE OR REPLACE VIEW public.vperpicdoublikates
 AS SELECT count(perceptionhash) AS count,
     perceptionhash
    FROM picturehash p
-  WHERE (EXISTS ( SELECT 1
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
            FROM pictures pp
           WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
   GROUP BY perceptionhash
@@ -546,7 +655,7 @@ CREATE OR REPLACE VIEW public.vsearchpicdoublikates
 AS SELECT count(hash) AS count,
     hash
    FROM picturehash p
-  WHERE (EXISTS ( SELECT 1
+  WHERE p.kind = 200064 AND (EXISTS ( SELECT 1
            FROM pictures pp
           WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
   GROUP BY hash
@@ -646,7 +755,7 @@ AS SELECT checksumpicture,
           WHERE p.checksumpicture::text = t1.checksumpicture::text) AS tags,
     ( SELECT ph.perceptionhash
            FROM picturehash ph
-          WHERE ph.checksumpicture::text = t1.checksumpicture::text
+          WHERE ph.checksumpicture::text = t1.checksumpicture::text AND ph.kind = 200064
          LIMIT 1) AS hash,
     count(*) OVER () AS total_count
    FROM pictures t1;
@@ -660,7 +769,7 @@ GRANT SELECT ON TABLE public.vsearchpictags TO read_album_role;
 CREATE UNIQUE INDEX audit_table_id_key ON public.audit_table USING btree (id);
 CREATE UNIQUE INDEX user_access_unique ON public.user_access USING btree (privileges, accreditation);
 CREATE UNIQUE INDEX picturehash_pkey ON public.picturehash USING btree (id);
-CREATE UNIQUE INDEX picturehash_unique ON public.picturehash USING btree (checksumpicture);
+CREATE UNIQUE INDEX picturehash_unique ON public.picturehash USING btree (checksumpicture, kind);
 CREATE INDEX picturetags_checksumpicture_idx ON public.picturetags USING btree (checksumpicture);
 CREATE UNIQUE INDEX picturetags_pkey ON public.picturetags USING btree (id);
 CREATE UNIQUE INDEX albums_pkey ON public.albums USING btree (id);
@@ -691,12 +691,14 @@ func insertPictureData(ti *timeInfo, pic *store.Pictures) error {
 			Fields: []string{"ChecksumPicture", "Sha256Checksum", "Title", "Fill",
 				"Height", "Width", "Media", "Thumbnail", "mimetype", "exifmodel", "exifmake",
 				"exiftaken", "exiforigtime", "exifxdimension", "exifydimension",
-				"exiforientation", "created", "exif", "GPScoordinates", "GPSlatitude", "GPSlongitude", "picopt"},
+				"exiforientation", "created", "exif", "GPScoordinates", "GPSlatitude", "GPSlongitude", "picopt",
+				"blurhash", "averagecolor", "dominantcolors", "quality"},
 			Values: [][]any{{pic.ChecksumPicture, pic.ChecksumPictureSHA, pic.Title, fill, pic.Height,
 				pic.Width, media, pic.Thumbnail, pic.MIMEType,
 				pic.ExifModel, pic.ExifMake, pic.ExifTaken.Format(timeFormat),
 				pic.ExifOrigTime.Format(timeFormat), pic.ExifXDimension, pic.ExifYDimension,
-				orientation, pic.Generated, pic.Exif, pic.GPScoordinates, pic.GPSlatitude, pic.GPSlongitude, picopt}},
+				orientation, pic.Generated, pic.Exif, pic.GPScoordinates, pic.GPSlatitude, pic.GPSlongitude, picopt,
+				nullString(pic.BlurHash), nullString(pic.AverageColor), nullString(pic.DominantColors), pic.Quality}},
 		}
 		_, err = id.Insert("Pictures", inserts)
 		if err != nil {
@@ -799,3 +801,9 @@ func Display() (err error) {
 func StopWorker() {
 	stop <- true
 }
+
+// nullString store an empty string as NULL, e.g. descriptors which could
+// not be generated are backfilled later
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,99 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package sql
+
+import (
+	"fmt"
+
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const (
+	// RenditionPreview animated GIF preview of a video
+	RenditionPreview = "preview"
+	// RenditionSprite JPEG sprite sheet used for hover-scrubbing of a video
+	RenditionSprite = "sprite"
+	// RenditionSpriteManifest JSON timing manifest of the sprite sheet
+	RenditionSpriteManifest = "spritemanifest"
+)
+
+// Rendition derived media of a picture or video
+type Rendition struct {
+	ChecksumPicture string
+	Kind            string
+	MIMEType        string
+	Checksum        string
+	Width           int
+	Height          int
+	Media           []byte
+}
+
+// StoreRendition store the rendition, an existing rendition of the same
+// kind is replaced
+func StoreRendition(id common.RegDbID, rendition *Rendition) error {
+	if rendition.Checksum == "" {
+		rendition.Checksum = store.CreateMd5(rendition.Media)
+	}
+	err := id.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	_, err = id.Delete("picturerenditions", &common.Entries{
+		Criteria: fmt.Sprintf("checksumpicture = '%s' AND kind = '%s'",
+			rendition.ChecksumPicture, rendition.Kind)})
+	if err != nil {
+		id.Rollback()
+		log.Log.Errorf("Error removing old rendition %s/%s: %v", rendition.ChecksumPicture, rendition.Kind, err)
+		return err
+	}
+	insert := &common.Entries{
+		Fields: []string{"checksumpicture", "kind", "mimetype", "checksum", "width", "height", "media"},
+		Values: [][]any{{rendition.ChecksumPicture, rendition.Kind, rendition.MIMEType,
+			rendition.Checksum, rendition.Width, rendition.Height, rendition.Media}},
+	}
+	_, err = id.Insert("picturerenditions", insert)
+	if err != nil {
+		id.Rollback()
+		log.Log.Errorf("Error inserting rendition %s/%s: %v", rendition.ChecksumPicture, rendition.Kind, err)
+		return err
+	}
+	log.Log.Debugf("Rendition %s/%s stored", rendition.ChecksumPicture, rendition.Kind)
+	return id.Commit()
+}
+
+// ReadRenditionKinds kinds of all renditions stored for the picture
+func ReadRenditionKinds(id common.RegDbID, checksum string) (map[string]bool, error) {
+	kinds := make(map[string]bool)
+	q := &common.Query{TableName: "picturerenditions",
+		Search:     "SELECT kind FROM picturerenditions WHERE checksumpicture = $1",
+		Parameters: []any{checksum}}
+	err := id.BatchSelectFct(q, func(search *common.Query, result *common.Result) error {
+		kinds[result.Rows[0].(string)] = true
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Error reading renditions of %s: %v", checksum, err)
+		return nil, err
+	}
+	return kinds, nil
+}
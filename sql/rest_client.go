@@ -74,44 +74,93 @@ func CheckRestClient(md5 string) (bool, error) {
 }
 
 func DownloadToTitle(md5 string, title string) error {
+	fmt.Println("Downloading", md5, "to", title)
+	data, err := downloadFile(md5)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(title)
+	if err != nil {
+		fmt.Println("Error creating file:", err)
+		return err
+	}
+	defer f.Close()
+	dst := bufio.NewWriter(f)
+	defer dst.Flush()
+	var n int64
+	n, err = io.Copy(dst, data)
+	if err != nil {
+		fmt.Println("Error copying file:", err)
+		return err
+	}
+	fmt.Println("Downloaded bytes:", n)
+	return nil
+}
+
+// RestMediaSize size of the media stored in the webstore, -1 if the
+// webstore does not report the size
+func RestMediaSize(md5 string) (int64, error) {
 	ctx := context.Background()
 	c, err := api.NewClient(bitgartenUrl, &sec{})
 	if err != nil {
 		log.Log.Debugf("Error creating client: %v", err)
-		return err
+		return -1, err
+	}
+	res, err := c.BrowseLocation(ctx, api.BrowseLocationParams{Path: filepath.Clean(bitgartenLocation) + "/" + md5})
+	if err != nil {
+		log.Log.Errorf("Browse location failed: %v", err)
+		return -1, err
+	}
+	switch r := res.(type) {
+	case *api.BrowseLocationOK:
+		if f, ok := r.GetFile(); ok && f.Size.Set {
+			return f.Size.Value, nil
+		}
+		return -1, nil
+	case *api.BrowseLocationNotFound:
+		return -1, fmt.Errorf("media %s not found in webstore", md5)
+	}
+	return -1, fmt.Errorf("error browse location of %s: %T", md5, res)
+}
+
+// DownloadToWriter stream the media of the webstore into the writer
+func DownloadToWriter(md5 string, w io.Writer) (int64, error) {
+	log.Log.Debugf("Downloading %s to writer", md5)
+	data, err := downloadFile(md5)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, data)
+	if err != nil {
+		fmt.Println("Error copying file:", err)
+		return n, err
+	}
+	return n, nil
+}
+
+func downloadFile(md5 string) (io.Reader, error) {
+	ctx := context.Background()
+	c, err := api.NewClient(bitgartenUrl, &sec{})
+	if err != nil {
+		log.Log.Debugf("Error creating client: %v", err)
+		return nil, err
 	}
-	fmt.Println("Downloading", md5, "to", title)
 	params := api.DownloadFileParams{Path: filepath.Clean(bitgartenLocation) + "/" + md5}
 	d, err := c.DownloadFile(ctx, params)
 	if err != nil {
 		fmt.Println("Error downloading file:", err)
-		return err
+		return nil, err
 	}
 	switch res := d.(type) {
 	case *api.DownloadFileOK:
-		f, err := os.Create(title)
-		if err != nil {
-			fmt.Println("Error creating file:", err)
-			return err
-		}
-		defer f.Close()
-		dst := bufio.NewWriter(f)
-		defer dst.Flush()
-		var n int64
-		n, err = io.Copy(dst, res.Data)
-		if err != nil {
-			fmt.Println("Error copying file:", err)
-			return err
-		}
-		fmt.Println("Downloaded bytes:", n)
+		return res.Data, nil
 	case *api.DownloadFileForbidden, *api.DownloadFileUnauthorized:
 		fmt.Println("Error permission file:")
-		return fmt.Errorf("Permission Error")
+		return nil, fmt.Errorf("Permission Error")
 	default:
 		fmt.Printf("Error downloading file: %T\n", d)
-		return fmt.Errorf("Error download file type %T:", d)
+		return nil, fmt.Errorf("Error download file type %T:", d)
 	}
-	return nil
 }
 
 func StoreRestClient(md5 string, media []byte) error {
@@ -139,6 +188,31 @@ func StoreRestClient(md5 string, media []byte) error {
 	return fmt.Errorf("ERROR WEB")
 }
 
+// DeleteRestClient delete the media blob of the given checksum in the webstore,
+// a missing blob is not an error
+func DeleteRestClient(md5 string) error {
+	log.Log.Debugf("Delete REST binary %s", md5)
+	ctx := context.Background()
+	c, err := api.NewClient(bitgartenUrl, &sec{})
+	if err != nil {
+		fmt.Println("Error client", err)
+		return err
+	}
+	params := api.DeleteFileLocationParams{Path: filepath.Clean(bitgartenLocation) + "/" + md5}
+	res, err := c.DeleteFileLocation(ctx, params)
+	if err != nil {
+		return err
+	}
+	switch res.(type) {
+	case *api.StatusResponse, *api.DeleteFileLocationNotFound:
+		return nil
+	default:
+	}
+	log.Log.Errorf("ERROR %s: %v", params.Path, err)
+	log.Log.Errorf("RES  : %T %v", res, res)
+	return fmt.Errorf("ERROR WEB")
+}
+
 type sec struct{}
 
 func (sec *sec) BasicAuth(ctx context.Context, operationName string) (api.BasicAuth, error) {
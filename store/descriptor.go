@@ -0,0 +1,313 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash component count used for placeholders
+const (
+	blurHashXComponents = 4
+	blurHashYComponents = 3
+)
+
+// number of colours in the dominant colour palette
+const paletteSize = 5
+
+// Descriptor visual descriptors of a picture thumbnail
+type Descriptor struct {
+	BlurHash       string
+	AverageColor   string
+	DominantColors string
+}
+
+// CreateDescriptor calculate BlurHash, average colour and dominant colour
+// palette out of the thumbnail
+func CreateDescriptor(thumbnail []byte) (*Descriptor, error) {
+	if len(thumbnail) == 0 {
+		return nil, fmt.Errorf("thumbnail empty")
+	}
+	img, _, err := image.Decode(bytes.NewReader(thumbnail))
+	if err != nil {
+		return nil, err
+	}
+	return CreateImageDescriptor(img), nil
+}
+
+// CreateImageDescriptor calculate visual descriptors of the image
+func CreateImageDescriptor(img image.Image) *Descriptor {
+	return &Descriptor{BlurHash: BlurHash(img, blurHashXComponents, blurHashYComponents),
+		AverageColor:   AverageColor(img),
+		DominantColors: DominantColors(img, paletteSize)}
+}
+
+// AverageColor average colour of the image in '#RRGGBB' notation
+func AverageColor(img image.Image) string {
+	b := img.Bounds()
+	var r, g, bl, n uint64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pr, pg, pb, _ := img.At(x, y).RGBA()
+			r += uint64(pr >> 8)
+			g += uint64(pg >> 8)
+			bl += uint64(pb >> 8)
+			n++
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%02X%02X%02X", r/n, g/n, bl/n)
+}
+
+type colorBucket struct {
+	r, g, b uint64
+	count   uint64
+}
+
+// DominantColors palette of the most used colours with their share in percent,
+// the palette is given as comma separated list of 'RRGGBB:percent' entries
+func DominantColors(img image.Image, size int) string {
+	b := img.Bounds()
+	buckets := make(map[uint32]*colorBucket)
+	total := uint64(0)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pr, pg, pb, _ := img.At(x, y).RGBA()
+			r, g, bl := pr>>8, pg>>8, pb>>8
+			// 4 bits per channel are enough to group similar colours
+			key := (r>>4)<<8 | (g>>4)<<4 | bl>>4
+			c, ok := buckets[key]
+			if !ok {
+				c = &colorBucket{}
+				buckets[key] = c
+			}
+			c.r += uint64(r)
+			c.g += uint64(g)
+			c.b += uint64(bl)
+			c.count++
+			total++
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	list := make([]*colorBucket, 0, len(buckets))
+	for _, c := range buckets {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		return list[i].r+list[i].g+list[i].b < list[j].r+list[j].g+list[j].b
+	})
+	if len(list) > size {
+		list = list[:size]
+	}
+	entries := make([]string, 0, len(list))
+	for _, c := range list {
+		entries = append(entries, fmt.Sprintf("%02X%02X%02X:%d", c.r/c.count, c.g/c.count,
+			c.b/c.count, c.count*100/total))
+	}
+	return strings.Join(entries, ",")
+}
+
+// ColorName classify the colour into a simple colour name
+func ColorName(r, g, b uint8) string {
+	rf := float64(r) / 255
+	gf := float64(g) / 255
+	bf := float64(b) / 255
+	max := math.Max(rf, math.Max(gf, bf))
+	min := math.Min(rf, math.Min(gf, bf))
+	switch {
+	case max < 0.2:
+		return "black"
+	case max-min < 0.15*max || max-min < 0.05:
+		if max > 0.85 {
+			return "white"
+		}
+		return "grey"
+	}
+	var hue float64
+	d := max - min
+	switch max {
+	case rf:
+		hue = math.Mod((gf-bf)/d, 6)
+	case gf:
+		hue = (bf-rf)/d + 2
+	default:
+		hue = (rf-gf)/d + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+	switch {
+	case hue < 15 || hue >= 345:
+		return "red"
+	case hue < 45:
+		if max < 0.6 {
+			return "brown"
+		}
+		return "orange"
+	case hue < 70:
+		return "yellow"
+	case hue < 170:
+		return "green"
+	case hue < 200:
+		return "cyan"
+	case hue < 260:
+		return "blue"
+	case hue < 290:
+		return "purple"
+	default:
+		return "pink"
+	}
+}
+
+// ColorShare percentage of the dominant colour palette belonging
+// to the given colour name
+func ColorShare(dominantColors, name string) int {
+	share := 0
+	for _, entry := range strings.Split(dominantColors, ",") {
+		c, p, ok := strings.Cut(entry, ":")
+		if !ok || len(c) != 6 {
+			continue
+		}
+		v, err := strconv.ParseUint(c, 16, 32)
+		if err != nil {
+			continue
+		}
+		pct, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+		if ColorName(uint8(v>>16), uint8(v>>8), uint8(v)) == strings.ToLower(name) {
+			share += pct
+		}
+	}
+	return share
+}
+
+// BlurHash encode the image into a BlurHash placeholder string
+// (see https://blurha.sh)
+func BlurHash(img image.Image, xComponents, yComponents int) string {
+	b := img.Bounds()
+	width := b.Dx()
+	height := b.Dy()
+	if width == 0 || height == 0 {
+		return ""
+	}
+	// convert once to linear colour space
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			linear[y*width+x] = [3]float64{sRGBToLinear(r >> 8), sRGBToLinear(g >> 8), sRGBToLinear(bl >> 8)}
+		}
+	}
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+			var f [3]float64
+			for y := 0; y < height; y++ {
+				cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := normalisation * math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * cy
+					p := linear[y*width+x]
+					f[0] += basis * p[0]
+					f[1] += basis * p[1]
+					f[2] += basis * p[2]
+				}
+			}
+			scale := 1.0 / float64(width*height)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+	maximumValue := 1.0
+	if len(factors) > 1 {
+		actualMaximum := 0.0
+		for _, f := range factors[1:] {
+			for _, v := range f {
+				actualMaximum = math.Max(actualMaximum, math.Abs(v))
+			}
+		}
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encode83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+	dc := factors[0]
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range factors[1:] {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return hash.String()
+}
+
+func encode83(value, length int) string {
+	result := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result[i-1] = base83Chars[digit]
+	}
+	return string(result)
+}
+
+func sRGBToLinear(value uint32) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,61 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package store
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func uniformImage(c color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDescriptorUniform(t *testing.T) {
+	img := uniformImage(color.RGBA{R: 20, G: 60, B: 200, A: 255})
+	d := CreateImageDescriptor(img)
+	assert.Equal(t, "#143CC8", d.AverageColor)
+	assert.Equal(t, "143CC8:100", d.DominantColors)
+	assert.Len(t, d.BlurHash, 6+2*(blurHashXComponents*blurHashYComponents-1))
+	// size flag of 4x3 components
+	assert.Equal(t, byte('L'), d.BlurHash[0])
+	assert.Equal(t, 100, ColorShare(d.DominantColors, "blue"))
+	assert.Equal(t, 0, ColorShare(d.DominantColors, "red"))
+}
+
+func TestColorName(t *testing.T) {
+	assert.Equal(t, "black", ColorName(10, 10, 10))
+	assert.Equal(t, "white", ColorName(250, 250, 250))
+	assert.Equal(t, "grey", ColorName(128, 128, 128))
+	assert.Equal(t, "red", ColorName(220, 20, 20))
+	assert.Equal(t, "green", ColorName(20, 200, 40))
+	assert.Equal(t, "blue", ColorName(20, 60, 200))
+	assert.Equal(t, "yellow", ColorName(230, 220, 30))
+	assert.Equal(t, 70, ColorShare("1030E0:50,FFFFFF:30,2040A0:20", "blue"))
+}
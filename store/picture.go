@@ -110,7 +110,7 @@ type Pictures struct {
 	Md5                string    `adabas:"::M5"`
 	ChecksumThumbnail  string    `adabas:"::CT"`
 	ChecksumPicture    string    `adabas:"::CP"`
-	ChecksumPictureSHA string    `adabas:":ignore"`
+	ChecksumPictureSHA string    `adabas:":ignore" flynn:"sha256checksum"`
 	Title              string    `adabas:"::TI"`
 	Fill               string    `adabas:"::FI"`
 	MIMEType           string    `adabas:"::TY"`
@@ -133,6 +133,10 @@ type Pictures struct {
 	GPSlatitude        float64
 	GPSlongitude       float64
 	PicOpt             string
+	BlurHash           string    `adabas:":ignore"`
+	AverageColor       string    `adabas:":ignore"`
+	DominantColors     string    `adabas:":ignore"`
+	Quality            float64   `adabas:":ignore"`
 	Available          Available `adabas:":ignore"`
 	StoreAlbum         int       `adabas:":ignore"`
 	// PictureLocations  []PictureLocations `adabas:"::PL"`
@@ -186,23 +190,33 @@ func CreateSHA(input []byte) string {
 
 func resizeHeif(media []byte, max int) ([]byte, *exif.Exif, uint32, uint32, error) {
 	log.Log.Debugf("Resize HEIF to %d", max)
+	srcImage, e, err := decodeHeif(media)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	thumb, w, h, err := resizeImage(srcImage, max)
+	return thumb, e, w, h, err
+}
+
+// decodeHeif decode HEIF image and rotate it corresponding to the EXIF orientation
+func decodeHeif(media []byte) (image.Image, *exif.Exif, error) {
 	ra := bytes.NewReader(media)
 	exifData, err := goheif.ExtractExif(ra)
 	if err != nil {
 		log.Log.Infof("Error extracting exif: %v", err)
-		return nil, nil, 0, 0, err
+		return nil, nil, err
 	}
 	e, err := exif.Decode(bytes.NewBuffer(exifData))
 	if err != nil {
 		log.Log.Infof("Error decoding exif: %v", err)
-		return nil, nil, 0, 0, err
+		return nil, nil, err
 	}
 	log.Log.Debugf("Exif HEIF len: %d", len(media))
 	r := bytes.NewBuffer(media)
 	srcImage, err := goheif.Decode(r)
 	if err != nil {
 		log.Log.Debugf("Decode image for thumbnail error %v", err)
-		return nil, nil, 0, 0, err
+		return nil, nil, err
 	}
 
 	t, err := e.Get(exif.Orientation)
@@ -226,9 +240,9 @@ func resizeHeif(media []byte, max int) ([]byte, *exif.Exif, uint32, uint32, erro
 			srcImage = imaging.Rotate90(srcImage)
 		}
 	}
-	thumb, w, h, err := resizeImage(srcImage, max)
-	return thumb, e, w, h, err
+	return srcImage, e, nil
 }
+
 func resizePicture(media []byte, max int) ([]byte, uint32, uint32, error) {
 	log.Log.Debugf("Resize image to %d", max)
 	var buffer bytes.Buffer
@@ -344,6 +358,18 @@ func (pic *PictureBinary) CreateThumbnail() error {
 
 }
 
+// createDescriptor set the visual descriptors out of the thumbnail
+func (pic *Pictures) createDescriptor() {
+	d, err := CreateDescriptor(pic.Thumbnail)
+	if err != nil {
+		log.Log.Infof("Error generating descriptor of %s: %v", pic.PictureName, err)
+		return
+	}
+	pic.BlurHash = d.BlurHash
+	pic.AverageColor = d.AverageColor
+	pic.DominantColors = d.DominantColors
+}
+
 func NewPictures(fileName string) *Pictures {
 	return &Pictures{Directory: filepath.Dir(fileName), PictureName: filepath.Base(fileName)}
 }
@@ -352,32 +378,46 @@ func NewPictures(fileName string) *Pictures {
 func (pic *Pictures) CreateThumbnail() error {
 	switch {
 	case strings.HasPrefix(strings.ToLower(pic.MIMEType), "image/h"):
-		thmb, e, w, h, err := resizeHeif(pic.Media, 200)
+		srcImage, e, err := decodeHeif(pic.Media)
+		if err != nil {
+			log.Log.Infof("Error generating HEIF thumbnail of %s: %v", pic.PictureName, err)
+			return err
+		}
+		thmb, w, h, err := resizeImage(srcImage, 200)
 		if err != nil {
 			log.Log.Infof("Error generating HEIF thumbnail of %s: %v", pic.PictureName, err)
 			return err
 		}
+		pic.Quality = QualityScore(srcImage).Score
 		pic.Thumbnail = thmb
 		pic.Width = w
 		pic.Height = h
 		pic.ChecksumThumbnail = CreateMd5(pic.Thumbnail)
 		pic.Md5 = pic.ChecksumThumbnail
 		log.Log.Debugf("Thumbnail checksum %s", pic.ChecksumThumbnail)
+		pic.createDescriptor()
 
 		return pic.analyseExif(e)
 
 	case strings.HasPrefix(pic.MIMEType, "image"):
-		thmb, w, h, err := resizePicture(pic.Media, 200)
+		srcImage, _, err := image.Decode(bytes.NewReader(pic.Media))
+		if err != nil {
+			log.Log.Infof("Error generating picture thumbnail of %s: %v", pic.PictureName, err)
+			return err
+		}
+		thmb, w, h, err := resizeImage(srcImage, 200)
 		if err != nil {
 			log.Log.Infof("Error generating picture thumbnail of %s: %v", pic.PictureName, err)
 			return err
 		}
+		pic.Quality = QualityScore(srcImage).Score
 		pic.Thumbnail = thmb
 		pic.Width = w
 		pic.Height = h
 		pic.ChecksumThumbnail = CreateMd5(pic.Thumbnail)
 		pic.Md5 = pic.ChecksumThumbnail
 		log.Log.Debugf("Thumbnail checksum %s", pic.ChecksumThumbnail)
+		pic.createDescriptor()
 
 		err = pic.ExifReader()
 		if err != nil && err != io.EOF {
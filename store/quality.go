@@ -0,0 +1,132 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package store
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/nfnt/resize"
+	"github.com/tknie/goheif"
+)
+
+// maximal edge size the quality is analysed with
+const qualityAnalyseSize = 512
+
+// resolution in mega pixels counted as full resolution score
+const qualityFullResolution = 12.0
+
+// Quality image quality measures, all partial scores are in the range 0 to 1
+// and Score combines them in the range 0 to 100
+type Quality struct {
+	Sharpness  float64
+	Exposure   float64
+	Clipping   float64
+	Noise      float64
+	Resolution float64
+	Score      float64
+}
+
+// DecodeImage decode image media including HEIC
+func DecodeImage(media []byte, mimeType string) (image.Image, error) {
+	if strings.HasPrefix(strings.ToLower(mimeType), "image/h") {
+		return goheif.Decode(bytes.NewReader(media))
+	}
+	img, _, err := image.Decode(bytes.NewReader(media))
+	return img, err
+}
+
+// QualityScore analyse sharpness (Laplacian variance), exposure and clipping,
+// noise and resolution of the image
+func QualityScore(img image.Image) *Quality {
+	b := img.Bounds()
+	q := &Quality{}
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return q
+	}
+	mp := float64(b.Dx()*b.Dy()) / 1000000
+	q.Resolution = math.Min(1, mp/qualityFullResolution)
+
+	small := img
+	if b.Dx() > qualityAnalyseSize || b.Dy() > qualityAnalyseSize {
+		small = resize.Thumbnail(qualityAnalyseSize, qualityAnalyseSize, img, resize.Bilinear)
+	}
+	w, h, lum := luminance(small)
+
+	// exposure and clipping out of the luminance histogram
+	sum := 0.0
+	clipped := 0
+	for _, l := range lum {
+		sum += l
+		if l <= 2 || l >= 253 {
+			clipped++
+		}
+	}
+	mean := sum / float64(len(lum))
+	q.Exposure = 1 - math.Abs(mean-128)/128
+	q.Clipping = 1 - math.Min(1, float64(clipped)/float64(len(lum))*5)
+
+	// sharpness is the variance of the Laplacian, noise is estimated
+	// with the method of Immerkaer (difference of two Laplacians)
+	lapSum, lapSq, noiseSum := 0.0, 0.0, 0.0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			c := lum[y*w+x]
+			n := lum[(y-1)*w+x]
+			s := lum[(y+1)*w+x]
+			west := lum[y*w+x-1]
+			e := lum[y*w+x+1]
+			lap := n + s + west + e - 4*c
+			lapSum += lap
+			lapSq += lap * lap
+			nw := lum[(y-1)*w+x-1]
+			ne := lum[(y-1)*w+x+1]
+			sw := lum[(y+1)*w+x-1]
+			se := lum[(y+1)*w+x+1]
+			noiseSum += math.Abs(nw + ne + sw + se - 2*(n+s+west+e) + 4*c)
+		}
+	}
+	count := float64((w - 2) * (h - 2))
+	lapMean := lapSum / count
+	variance := lapSq/count - lapMean*lapMean
+	q.Sharpness = math.Min(1, math.Log10(1+variance)/3)
+	sigma := math.Sqrt(math.Pi/2) * noiseSum / (6 * count)
+	q.Noise = 1 - math.Min(1, sigma/20)
+
+	q.Score = 100 * (0.35*q.Sharpness + 0.2*q.Exposure + 0.15*q.Clipping +
+		0.15*q.Noise + 0.15*q.Resolution)
+	return q
+}
+
+func luminance(img image.Image) (int, int, []float64) {
+	b := img.Bounds()
+	w := b.Dx()
+	h := b.Dy()
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			lum[y*w+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+		}
+	}
+	return w, h, lum
+}
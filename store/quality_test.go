@@ -0,0 +1,55 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package store
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualityScore(t *testing.T) {
+	flat := image.NewGray(image.Rect(0, 0, 64, 64))
+	detail := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			flat.SetGray(x, y, color.Gray{Y: 128})
+			v := uint8(96)
+			if (x/4+y/4)%2 == 0 {
+				v = 160
+			}
+			detail.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	qf := QualityScore(flat)
+	qd := QualityScore(detail)
+	assert.Equal(t, 0.0, qf.Sharpness)
+	assert.Equal(t, 1.0, qf.Exposure)
+	assert.Equal(t, 1.0, qf.Clipping)
+	assert.Greater(t, qd.Sharpness, qf.Sharpness)
+	assert.Greater(t, qd.Score, qf.Score)
+
+	black := image.NewGray(image.Rect(0, 0, 64, 64))
+	qb := QualityScore(black)
+	assert.Equal(t, 0.0, qb.Exposure)
+	assert.Equal(t, 0.0, qb.Clipping)
+}
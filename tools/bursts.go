@@ -0,0 +1,332 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/services"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const (
+	// DefaultBurstGap maximal seconds between two frames of a burst
+	DefaultBurstGap = 2
+	// DefaultBurstDistance maximal perception hash distance between two frames
+	DefaultBurstDistance = 12
+	// DefaultBurstMinCount minimal number of frames of a burst
+	DefaultBurstMinCount = 3
+	// DefaultBurstKeep number of best frames kept
+	DefaultBurstKeep = 1
+)
+
+const readBurstEntries = `
+SELECT p.checksumpicture, COALESCE(p.exifmodel, '') AS exifmodel,
+  extract(epoch FROM p.exiforigtime)::bigint::text AS taken,
+  ph.perceptionhash::text AS perceptionhash
+  FROM pictures p, picturehash ph
+  WHERE p.markdelete = false AND p.exiforigtime > '0001-01-01'
+  AND COALESCE(p.exifmodel, '') <> ''
+  AND ph.checksumpicture::text = p.checksumpicture::text AND ph.kind = {{.}}
+  ORDER BY 2, p.exiforigtime
+`
+
+// zeroTakenTime epoch of the '0001-01-01 00:00:00' stored for pictures
+// without EXIF original time
+var zeroTakenTime = time.Time{}.Unix()
+
+type BurstParameter struct {
+	Gap       int
+	Distance  int
+	MinCount  int
+	Keep      int
+	Limit     int
+	Tag       string
+	Delete    bool
+	Report    string
+	Decisions string
+	Policy    *RetentionPolicy
+	Commit    bool
+	Json      bool
+}
+
+// BurstEntry picture with camera model, time taken and perception hash
+type BurstEntry struct {
+	Checksumpicture string
+	Model           string
+	Taken           int64
+	Perception      uint64
+}
+
+type burstHash struct {
+	Checksumpicture string
+	Exifmodel       string
+	Taken           string
+	Perceptionhash  string
+}
+
+// BurstGroups split the entries into sequences of the same camera model.
+// A sequence continues as long as the next frame is taken within the gap
+// and its perception hash is within the distance of the previous frame.
+// Only sequences with at least minCount frames are returned. Entries
+// without camera model or time taken are never part of a burst.
+func BurstGroups(entries []*BurstEntry, gap int64, maxDistance, minCount int) [][]*BurstEntry {
+	sort.SliceStable(entries, func(x, y int) bool {
+		if entries[x].Model != entries[y].Model {
+			return entries[x].Model < entries[y].Model
+		}
+		return entries[x].Taken < entries[y].Taken
+	})
+	groups := make([][]*BurstEntry, 0)
+	var current []*BurstEntry
+	flush := func() {
+		if len(current) >= minCount && len(current) > 1 {
+			groups = append(groups, current)
+		}
+		current = nil
+	}
+	for _, e := range entries {
+		if e.Model == "" || e.Taken <= zeroTakenTime {
+			continue
+		}
+		if len(current) > 0 {
+			last := current[len(current)-1]
+			if last.Model != e.Model || e.Taken-last.Taken > gap ||
+				HammingDistance(last.Perception, e.Perception) > maxDistance {
+				flush()
+			}
+		}
+		current = append(current, e)
+	}
+	flush()
+	return groups
+}
+
+// burstPolicy policy ranking the frames by quality first, the rules of the
+// given policy decide frames of same quality
+func burstPolicy(policy *RetentionPolicy) *RetentionPolicy {
+	prefer := []string{RuleQuality}
+	for _, rule := range policy.Prefer {
+		if rule != RuleQuality {
+			prefer = append(prefer, rule)
+		}
+	}
+	return &RetentionPolicy{Protect: policy.Protect, MergeTags: policy.MergeTags, Prefer: prefer}
+}
+
+// rankBurst rank the frames and mark all but the best keep frames, protected
+// frames are never marked
+func rankBurst(policy *RetentionPolicy, picturesByHash []*PictureByHash, keep int) *RetentionDecision {
+	decision := policy.Decide(picturesByHash)
+	if decision == nil {
+		return nil
+	}
+	for i, pbh := range picturesByHash {
+		if i < keep {
+			pbh.delete = false
+		}
+	}
+	return decision
+}
+
+// readBursts read all pictures with original time and standard hash
+func readBursts(id common.RegDbID) ([]*BurstEntry, error) {
+	sqlCmd, err := templateSql(readBurstEntries, StandardHashKind)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*BurstEntry, 0)
+	query := &common.Query{
+		TableName:  "pictures",
+		DataStruct: &burstHash{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		bh := result.Data.(*burstHash)
+		e := &BurstEntry{Checksumpicture: bh.Checksumpicture, Model: bh.Exifmodel}
+		var err error
+		e.Taken, err = strconv.ParseInt(bh.Taken, 10, 64)
+		if err != nil {
+			log.Log.Errorf("Error parsing time %s of %s: %v", bh.Taken, bh.Checksumpicture, err)
+			return nil
+		}
+		e.Perception, err = strconv.ParseUint(bh.Perceptionhash, 10, 64)
+		if err != nil {
+			log.Log.Errorf("Error parsing hash %s of %s: %v", bh.Perceptionhash, bh.Checksumpicture, err)
+			return nil
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Bursts search bursts and sequences of near-identical frames, rank each
+// burst by quality and tag or mark delete all but the best frames
+func Bursts(parameter *BurstParameter) error {
+	if parameter.Policy == nil {
+		parameter.Policy = DefaultRetentionPolicy
+	}
+	if parameter.Keep < 1 {
+		parameter.Keep = DefaultBurstKeep
+	}
+	var review *DuplicateReview
+	if parameter.Report != "" || parameter.Decisions != "" {
+		// review mode only proposes the decisions
+		parameter.Commit = false
+		review = &DuplicateReview{Created: time.Now(), tool: ReviewToolBursts}
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	entries, err := readBursts(id)
+	if err != nil {
+		fmt.Println("Error query bursts:", err)
+		return err
+	}
+	groups := BurstGroups(entries, int64(parameter.Gap), parameter.Distance, parameter.MinCount)
+	services.ServerMessage("Found %d bursts in %d pictures with gap %ds and distance %d",
+		len(groups), len(entries), parameter.Gap, parameter.Distance)
+	policy := burstPolicy(parameter.Policy)
+	for i, group := range groups {
+		if parameter.Limit > 0 && i >= parameter.Limit {
+			break
+		}
+		checksums := make([]string, 0, len(group))
+		for _, e := range group {
+			checksums = append(checksums, e.Checksumpicture)
+		}
+		key := fmt.Sprintf("burst %s %s", group[0].Model, time.Unix(group[0].Taken, 0).UTC().Format("2006-01-02 15:04:05"))
+		fmt.Printf("Working on %d.Burst %s with %d frames: %s\n", i+1, key, len(group), strings.Join(checksums, ","))
+		sqlCmd, err := templateSql(readPictureByChecksums, checksums)
+		if err != nil {
+			return err
+		}
+		picturesByHash, err := readPicturesByHash(id, sqlCmd)
+		if err != nil {
+			return err
+		}
+		decision := rankBurst(policy, picturesByHash, parameter.Keep)
+		if decision == nil {
+			continue
+		}
+		switch {
+		case review != nil:
+			review.add(key, decision, picturesByHash)
+		case parameter.Delete:
+			err = markBurstDelete(parameter.Commit, fmt.Sprintf("burst frame of %s in %s, rule %s", decision.Keep.Checksumpicture, key, decision.Rule),
+				picturesByHash)
+		case parameter.Tag != "":
+			err = tagPictures(id, parameter.Tag, parameter.Commit, picturesByHash)
+		default:
+			for _, pbh := range picturesByHash {
+				fmt.Printf("  %s %s quality=%.2f delete=%v\n", pbh.Checksumpicture, pbh.Title, pbh.Quality, pbh.delete)
+			}
+		}
+		if err != nil {
+			fmt.Println("Error resolving burst:", err)
+			return err
+		}
+	}
+	if review != nil {
+		return review.write(parameter.Report, parameter.Decisions)
+	}
+	return nil
+}
+
+// markBurstDelete mark all frames to be deleted as deleted in one
+// transaction. Albums, tags and locations are not changed, the frames
+// are no duplicates of the kept frame.
+func markBurstDelete(commit bool, reason string, picturesByHash []*PictureByHash) error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	err = id.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	for _, pbh := range picturesByHash {
+		if !pbh.delete {
+			continue
+		}
+		ra, err := markPictureDelete(id, pbh.Checksumpicture, reason, "")
+		if err != nil {
+			log.Log.Infof("Error setting mark picture delete: %v", err)
+			id.Rollback()
+			return err
+		}
+		if ra != 1 {
+			id.Rollback()
+			return fmt.Errorf("incorrect update mark delete of %s: %d", pbh.Checksumpicture, ra)
+		}
+		services.ServerMessage("Mark delete -> %v", pbh.Checksumpicture)
+	}
+	if !commit {
+		return id.Rollback()
+	}
+	return id.Commit()
+}
+
+// tagPictures tag all pictures marked to be deleted with the given tag,
+// the tags are inserted in one transaction
+func tagPictures(id common.RegDbID, tag string, commit bool, picturesByHash []*PictureByHash) error {
+	list := make([][]any, 0)
+	for _, pbh := range picturesByHash {
+		if pbh.delete && !hasTag(pbh.Tags, tag) {
+			fmt.Printf("Tag %s %s with <%s>\n", pbh.Checksumpicture, pbh.Title, tag)
+			list = append(list, []any{tag, pbh.Checksumpicture})
+		}
+	}
+	if len(list) == 0 || !commit {
+		return nil
+	}
+	err := id.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	_, err = id.Insert("picturetags", &common.Entries{
+		Fields: []string{"tagname", "checksumpicture"},
+		Values: list,
+	})
+	if err != nil {
+		id.Rollback()
+		log.Log.Errorf("Error inserting burst tags: %v", err)
+		return err
+	}
+	return id.Commit()
+}
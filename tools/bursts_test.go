@@ -0,0 +1,84 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBurstGroups(t *testing.T) {
+	entries := []*BurstEntry{
+		{Checksumpicture: "A1", Model: "iPhone", Taken: 100, Perception: 0x0},
+		{Checksumpicture: "A3", Model: "iPhone", Taken: 101, Perception: 0x3},
+		{Checksumpicture: "A2", Model: "iPhone", Taken: 100, Perception: 0x1},
+		// chained frames drift away from the first frame
+		{Checksumpicture: "A4", Model: "iPhone", Taken: 103, Perception: 0xf},
+		// gap too large
+		{Checksumpicture: "B1", Model: "iPhone", Taken: 110, Perception: 0xf},
+		{Checksumpicture: "B2", Model: "iPhone", Taken: 111, Perception: 0xf},
+		// other camera at the same time
+		{Checksumpicture: "C1", Model: "Canon", Taken: 100, Perception: 0x0},
+		{Checksumpicture: "C2", Model: "Canon", Taken: 101, Perception: 0xffff},
+		{Checksumpicture: "C3", Model: "Canon", Taken: 101, Perception: 0xffff},
+		// no EXIF time and no model are never a burst
+		{Checksumpicture: "S1", Model: "", Taken: zeroTakenTime, Perception: 0x0},
+		{Checksumpicture: "S2", Model: "", Taken: zeroTakenTime, Perception: 0x0},
+		{Checksumpicture: "S3", Model: "", Taken: zeroTakenTime, Perception: 0x1},
+		{Checksumpicture: "Z1", Model: "iPhone", Taken: zeroTakenTime, Perception: 0x0},
+		{Checksumpicture: "Z2", Model: "iPhone", Taken: zeroTakenTime, Perception: 0x0},
+		{Checksumpicture: "M1", Model: "", Taken: 200, Perception: 0x0},
+		{Checksumpicture: "M2", Model: "", Taken: 200, Perception: 0x0},
+	}
+	groups := BurstGroups(entries, 2, 2, 2)
+	assert.Len(t, groups, 3)
+	ids := func(g []*BurstEntry) []string {
+		l := make([]string, 0)
+		for _, e := range g {
+			l = append(l, e.Checksumpicture)
+		}
+		return l
+	}
+	assert.Equal(t, []string{"C2", "C3"}, ids(groups[0]))
+	assert.Equal(t, []string{"A1", "A2", "A3", "A4"}, ids(groups[1]))
+	assert.Equal(t, []string{"B1", "B2"}, ids(groups[2]))
+	assert.Len(t, BurstGroups(entries, 2, 2, 3), 1)
+}
+
+func TestRankBurst(t *testing.T) {
+	policy := burstPolicy(DefaultRetentionPolicy)
+	assert.Equal(t, RuleQuality, policy.Prefer[0])
+	assert.Len(t, policy.Prefer, len(DefaultRetentionPolicy.Prefer))
+	pictures := []*PictureByHash{
+		{Checksumpicture: "A", Width: 4000, Quality: 0.5},
+		{Checksumpicture: "B", Width: 4000, Quality: 0.9},
+		{Checksumpicture: "C", Width: 4000, Quality: 0.7},
+		{Checksumpicture: "D", Width: 4000, Quality: 0.1, Tags: "'bitgarten'"},
+	}
+	decision := rankBurst(policy, pictures, 2)
+	assert.Equal(t, "B", decision.Keep.Checksumpicture)
+	assert.Equal(t, RuleQuality, decision.Rule)
+	deleted := make(map[string]bool)
+	for _, pbh := range pictures {
+		deleted[pbh.Checksumpicture] = pbh.delete
+	}
+	assert.Equal(t, map[string]bool{"A": true, "B": false, "C": false, "D": false}, deleted)
+}
@@ -20,72 +20,48 @@ package tools
 
 import (
 	"fmt"
-	"math/big"
-	"strconv"
-	"strings"
 
-	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/tknie/bitgartentools/sql"
 	"github.com/tknie/flynn/common"
 	"github.com/tknie/log"
 )
 
-const checkQuery = `
-with titleTable (title,
-subtitle) as (
-select
-	title,
-	replace(replace(replace(title ,
-	'_4_5005_c.jpeg',
-	''),'_1_105_c.jpeg',''),'_1_201_a.heic','')
-from
-	pictures
-where
-	(title like '%_4_5005_c.jpeg%' or title like '%_1_105_c.jpeg%' or title like '%_1_201_a.heic%'){{.}})
-select * from
-(select
-	pt.checksumpicture,
-	pt.markdelete,
-	pt.title,
-	pt.width ,
-	pt.height ,
-	tt.subtitle,
-	ph.perceptionhash,
-	string_agg(a.tagname::text, ','::text ORDER BY (a.tagname::text)) AS tags,
-	count (*) over (partition by ph.perceptionhash order by ph.perceptionhash) as cnt,
-	row_number () over (partition by ph.perceptionhash order by ph.perceptionhash) as r
-from
-	titletable tt,
-	pictures pt,
-	picturehash ph
-	LEFT JOIN picturetags a USING (checksumpicture)
-where
-	pt.title like concat(tt.subtitle,
-	'%')
-	and pt.checksumpicture = ph.checksumpicture and markdelete=false
-group by
-	ph.perceptionhash,
-	pt.checksumpicture,
-	pt.markdelete,
-	pt.title,
-	pt.width ,
-	pt.height ,
-	tt.subtitle
-order by
-	perceptionhash,
-	width desc) where cnt > 1;` // and r > 1;`
-
 type NameCleanParameter struct {
 	Limit    int
 	MinCount int
 	Title    string
+	Policy   *RetentionPolicy
+	Rules    *DerivedNameRules
 	Commit   bool
 	Json     bool
 }
 
-var did common.RegDbID
+// nameGroup pictures with same base name and same perception hash
+type nameGroup struct {
+	rule      *DerivedNameRule
+	base      string
+	hash      string
+	checksums []string
+	derived   map[string]bool
+}
+
+type derivedName struct {
+	Base            string
+	Checksumpicture string
+	Derived         bool
+	Perceptionhash  string
+}
 
+// NameClean search pictures with derived names and same hash, the
+// preference of the derived name rule and the retention policy decide
+// which picture of each group is kept
 func NameClean(parameter *NameCleanParameter) error {
+	if parameter.Policy == nil {
+		parameter.Policy = DefaultRetentionPolicy
+	}
+	if parameter.Rules == nil {
+		parameter.Rules = DefaultDerivedNameRules
+	}
 	id, err := sql.DatabaseHandler()
 	if err != nil {
 		fmt.Println("POSTGRES error", err)
@@ -93,64 +69,60 @@ func NameClean(parameter *NameCleanParameter) error {
 	}
 	defer id.FreeHandler()
 
-	did, err = sql.DatabaseHandler()
-	if err != nil {
-		fmt.Println("POSTGRES error", err)
-		return err
-	}
-	defer did.FreeHandler()
-
-	search := ""
-	if parameter.Title != "" {
-		search = "AND title like '" + parameter.Title + "%'"
-	}
-	search, _ = templateSql(checkQuery, search)
-	log.Log.Debugf("Search : %s", search)
-
-	query := &common.Query{
-		TableName: "picturehash",
-		Limit:     strconv.Itoa(parameter.Limit),
-		Search:    search,
-	}
 	counter := uint64(0)
 	deleted := uint64(0)
-	var currentHash pgtype.Numeric
-	subtitle := ""
-	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
-		index := result.GetRowValueByName("r").(int64)
-		tags := result.GetRowValueByName("tags")
-		if index == 1 {
-			currentHash = result.GetRowValueByName("perceptionhash").(pgtype.Numeric)
-			subtitle = result.GetRowValueByName("subtitle").(string)
-		} else {
-			if currentHash.Int.Cmp(big.NewInt(0)) > 0 {
-				recordHash := result.GetRowValueByName("perceptionhash").(pgtype.Numeric)
-				s := result.GetRowValueByName("subtitle").(string)
-				if strings.HasPrefix(s, subtitle) {
-					if recordHash.Int.Cmp(currentHash.Int) == 0 {
-						if tags == nil {
-							deleted++
-							if parameter.Commit {
-								checksumPicture := result.GetRowValueByName("checksumPicture").(string)
-								markDelete(checksumPicture)
-							}
-						}
-						if !parameter.Json {
-							fmt.Println(subtitle, "Deleted:", deleted, "Tags:", tags, "Hash:", currentHash)
-						}
-					} else {
-						if !parameter.Json {
-							fmt.Println("Record hash differences:", recordHash, currentHash)
-						}
-					}
-				}
+	groups := make([]*nameGroup, 0)
+	for _, rule := range parameter.Rules.Rules {
+		ruleGroups, n, err := parameter.readNameGroups(id, rule)
+		if err != nil {
+			fmt.Println("Error query derived names of rule", rule.Name, ":", err)
+			return err
+		}
+		counter += n
+		groups = append(groups, ruleGroups...)
+	}
+	for i, g := range groups {
+		if parameter.Limit > 0 && i >= parameter.Limit {
+			break
+		}
+		sqlCmd, err := templateSql(readPictureByChecksums, g.checksums)
+		if err != nil {
+			return err
+		}
+		picturesByHash, err := readPicturesByHash(id, sqlCmd)
+		if err != nil {
+			return err
+		}
+		if len(picturesByHash) < 2 {
+			continue
+		}
+		decision := g.rule.Decide(parameter.Policy, picturesByHash, g.derived)
+		if decision == nil {
+			continue
+		}
+		groupDeleted := uint64(0)
+		for _, pbh := range picturesByHash {
+			// name clean never deletes tagged pictures
+			if pbh.delete && pbh.Tags != "" {
+				pbh.delete = false
+			}
+			if pbh.delete {
+				groupDeleted++
 			}
 		}
-		counter++
-		return nil
-	})
-	if err != nil {
-		return err
+		if groupDeleted == 0 {
+			continue
+		}
+		deleted += groupDeleted
+		if !parameter.Json {
+			fmt.Println(g.base, "Keep:", decision.Keep.Checksumpicture, "Rule:", decision.Rule, "Deleted:", groupDeleted)
+		}
+		err = cleanUpPictures(parameter.Commit, fmt.Sprintf("derived name %s of %s, rule %s", g.rule.Name, decision.Keep.Checksumpicture, decision.Rule),
+			decision.TagMap, decision.Keep, picturesByHash)
+		if err != nil {
+			fmt.Println("Error cleanup pictures:", err)
+			return err
+		}
 	}
 	if parameter.Json {
 		fmt.Printf("\"deleted\":%d,\"counter\":%d,", deleted, counter)
@@ -161,18 +133,41 @@ func NameClean(parameter *NameCleanParameter) error {
 	return nil
 }
 
-func markDelete(checksumPicture string) error {
-	update := &common.Entries{
-		Fields: []string{"markdelete"},
-		Values: [][]any{{true}},
-		Update: []string{"checksumpicture='" + checksumPicture + "'"},
+// readNameGroups read all groups of pictures with same base name and same
+// perception hash containing at least one derived picture of the rule
+func (parameter *NameCleanParameter) readNameGroups(id common.RegDbID, rule *DerivedNameRule) ([]*nameGroup, uint64, error) {
+	query := &common.Query{
+		TableName:  "pictures",
+		DataStruct: &derivedName{},
+		Search:     readDerivedNames,
+		Parameters: []any{rule.Pattern, rule.Base, parameter.Title + "%", StandardHashKind},
 	}
-	_, n, err := did.Update("pictures", update)
+	counter := uint64(0)
+	groups := make([]*nameGroup, 0)
+	var group *nameGroup
+	hasDerived := false
+	flush := func() {
+		if group != nil && len(group.checksums) > 1 && hasDerived {
+			groups = append(groups, group)
+		}
+	}
+	err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		dn := result.Data.(*derivedName)
+		counter++
+		if group == nil || group.base != dn.Base || group.hash != dn.Perceptionhash {
+			flush()
+			group = &nameGroup{rule: rule, base: dn.Base, hash: dn.Perceptionhash, derived: make(map[string]bool)}
+			hasDerived = false
+		}
+		group.checksums = append(group.checksums, dn.Checksumpicture)
+		group.derived[dn.Checksumpicture] = dn.Derived
+		hasDerived = hasDerived || dn.Derived
+		return nil
+	})
 	if err != nil {
-		fmt.Println("Error mark delete", n, ":", err)
-		fmt.Println("Pic:", checksumPicture)
-		return err
+		return nil, 0, err
 	}
-	fmt.Println(checksumPicture, "mark deleted")
-	return nil
+	flush()
+	log.Log.Debugf("Rule %s found %d groups in %d pictures", rule.Name, len(groups), counter)
+	return groups, counter, nil
 }
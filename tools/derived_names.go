@@ -0,0 +1,157 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/tknie/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Preferences of a derived name rule
+const (
+	// PreferOriginal keep the picture with the original name
+	PreferOriginal = "original"
+	// PreferDerived keep the derived variant, e.g. the edited picture
+	PreferDerived = "derived"
+	// PreferPolicy the retention policy decides
+	PreferPolicy = "policy"
+)
+
+// readDerivedNames read all pictures with the same base name as a picture
+// matching the rule pattern. The base name of pictures not matching is the
+// title without extension. Parameters are the pattern, the base name
+// replacement, the title filter and the hash kind.
+const readDerivedNames = `
+WITH t AS (
+SELECT p.checksumpicture, p.title ~ $1 AS derived,
+  CASE WHEN p.title ~ $1 THEN regexp_replace(p.title, $1, $2)
+       ELSE regexp_replace(p.title, '\.[^.]*$', '') END AS base
+  FROM pictures p WHERE p.markdelete = false)
+SELECT t.base, t.checksumpicture, t.derived, ph.perceptionhash::text AS perceptionhash
+  FROM t, picturehash ph
+  WHERE ph.checksumpicture::text = t.checksumpicture::text AND ph.kind = $4 AND ph.perceptionhash <> 0
+  AND t.base IN (SELECT regexp_replace(pp.title, $1, $2) FROM pictures pp
+     WHERE pp.markdelete = false AND pp.title ~ $1 AND pp.title LIKE $3)
+  ORDER BY t.base, perceptionhash, t.checksumpicture
+`
+
+// DerivedNameRule rule finding pictures derived from an original, e.g.
+// scaled, edited or copied pictures
+type DerivedNameRule struct {
+	Name string `yaml:"name"`
+	// Pattern regular expression matching the title of derived pictures
+	Pattern string `yaml:"pattern"`
+	// Base replacement of the pattern extracting the base name of the
+	// original without extension, e.g. '\1'
+	Base string `yaml:"base"`
+	// Prefer which picture of a group is kept: original, derived or policy
+	Prefer string `yaml:"prefer"`
+}
+
+// DerivedNameRules all rules used by the name clean
+type DerivedNameRules struct {
+	Rules []*DerivedNameRule `yaml:"rules"`
+}
+
+// DefaultDerivedNameRules rules used if no rules file is given
+var DefaultDerivedNameRules = &DerivedNameRules{Rules: []*DerivedNameRule{
+	{Name: "apple-scaled", Pattern: `^(.*)_(4_5005_c|1_105_c)\.jpeg$`, Base: `\1`, Prefer: PreferOriginal},
+	{Name: "apple-heic", Pattern: `^(.*)_1_201_a\.heic$`, Base: `\1`, Prefer: PreferOriginal},
+	{Name: "apple-edited", Pattern: `^IMG_E([0-9]+)\.[^.]+$`, Base: `IMG_\1`, Prefer: PreferDerived},
+	{Name: "google-edited", Pattern: `^(.*)-edited\.[^.]+$`, Base: `\1`, Prefer: PreferDerived},
+	{Name: "copy", Pattern: `^(.*[^ ]) ?\([0-9]+\)\.[^.]+$`, Base: `\1`, Prefer: PreferOriginal},
+	{Name: "whatsapp", Pattern: `^IMG-([0-9]{8})-WA[0-9]+\.jpe?g$`, Base: `IMG-\1-WA`, Prefer: PreferPolicy},
+}}
+
+// LoadDerivedNameRules read the derived name rules out of the YAML file
+func LoadDerivedNameRules(file string) (*DerivedNameRules, error) {
+	byteValue, err := ReadScanFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rules := &DerivedNameRules{}
+	err = yaml.Unmarshal(byteValue, rules)
+	if err != nil {
+		log.Log.Debugf("Unmarshal error: %#v", err)
+		return nil, fmt.Errorf("error parsing derived name rules %s: %v", file, err)
+	}
+	err = rules.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Validate check that all rules have a valid pattern, base and preference
+func (rules *DerivedNameRules) Validate() error {
+	if len(rules.Rules) == 0 {
+		return fmt.Errorf("derived name rules contain no rule")
+	}
+	for _, rule := range rules.Rules {
+		if rule.Name == "" || rule.Base == "" {
+			return fmt.Errorf("derived name rule %s needs name and base", rule.Pattern)
+		}
+		_, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern of derived name rule %s: %v", rule.Name, err)
+		}
+		switch rule.Prefer {
+		case PreferOriginal, PreferDerived, PreferPolicy:
+		case "":
+			rule.Prefer = PreferPolicy
+		default:
+			return fmt.Errorf("unknown preference %s of derived name rule %s, valid are %s, %s and %s",
+				rule.Prefer, rule.Name, PreferOriginal, PreferDerived, PreferPolicy)
+		}
+	}
+	return nil
+}
+
+// Decide select the picture to keep with the retention policy restricted to
+// the preferred pictures of the rule. All other pictures which are not
+// protected are marked to be deleted.
+func (rule *DerivedNameRule) Decide(policy *RetentionPolicy, picturesByHash []*PictureByHash, derived map[string]bool) *RetentionDecision {
+	decision := policy.Decide(picturesByHash)
+	if decision == nil || rule.Prefer == PreferPolicy {
+		return decision
+	}
+	preferred := make([]*PictureByHash, 0)
+	for _, pbh := range picturesByHash {
+		if derived[pbh.Checksumpicture] == (rule.Prefer == PreferDerived) {
+			preferred = append(preferred, pbh)
+		}
+	}
+	if len(preferred) == 0 || len(preferred) == len(picturesByHash) {
+		return decision
+	}
+	sub := policy.Decide(preferred)
+	if sub.Keep == decision.Keep {
+		return decision
+	}
+	decision.Keep = sub.Keep
+	decision.Rule = rule.Prefer + ":" + rule.Name
+	for _, pbh := range picturesByHash {
+		pbh.delete = pbh != decision.Keep && !policy.Protected(pbh)
+	}
+	return decision
+}
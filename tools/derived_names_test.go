@@ -0,0 +1,127 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDerivedNameRules(t *testing.T) {
+	rules, err := LoadDerivedNameRules("../derived_names.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultDerivedNameRules, rules)
+	assert.NoError(t, DefaultDerivedNameRules.Validate())
+
+	file := filepath.Join(t.TempDir(), "names.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`rules:
+ - name: scan
+   pattern: '^(.*)_scan\.tiff$'
+   base: '\1'
+`), 0644))
+	rules, err = LoadDerivedNameRules(file)
+	assert.NoError(t, err)
+	assert.Equal(t, PreferPolicy, rules.Rules[0].Prefer)
+
+	assert.NoError(t, os.WriteFile(file, []byte(`rules:
+ - name: scan
+   pattern: '^(.*_scan\.tiff$'
+   base: '\1'
+`), 0644))
+	_, err = LoadDerivedNameRules(file)
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(file, []byte(`rules:
+ - name: scan
+   pattern: '^(.*)_scan\.tiff$'
+   base: '\1'
+   prefer: newest
+`), 0644))
+	_, err = LoadDerivedNameRules(file)
+	assert.Error(t, err)
+}
+
+func TestDefaultDerivedNamePatterns(t *testing.T) {
+	bases := map[string]string{
+		"IMG_1234_4_5005_c.jpeg":         "IMG_1234",
+		"IMG_1234_1_105_c.jpeg":          "IMG_1234",
+		"IMG_1234_1_201_a.heic":          "IMG_1234",
+		"IMG_E1234.JPG":                  "IMG_1234",
+		"PXL_20200101_123456-edited.jpg": "PXL_20200101_123456",
+		"Holiday (1).jpg":                "Holiday",
+		"Holiday(2).png":                 "Holiday",
+		"IMG-20200101-WA0001.jpg":        "IMG-20200101-WA",
+	}
+	for title, base := range bases {
+		found := false
+		for _, rule := range DefaultDerivedNameRules.Rules {
+			re := regexp.MustCompile(rule.Pattern)
+			if re.MatchString(title) {
+				assert.Equal(t, base, re.ReplaceAllString(title, strings.ReplaceAll(rule.Base, `\1`, "${1}")), title)
+				found = true
+				break
+			}
+		}
+		assert.True(t, found, title)
+	}
+	for _, title := range []string{"IMG_1234.HEIC", "IMG_1234.jpg", "Holiday.jpg"} {
+		for _, rule := range DefaultDerivedNameRules.Rules {
+			assert.False(t, regexp.MustCompile(rule.Pattern).MatchString(title), title)
+		}
+	}
+}
+
+func TestDerivedNameDecide(t *testing.T) {
+	group := func() []*PictureByHash {
+		return []*PictureByHash{
+			{Checksumpicture: "O", Title: "IMG_1234.JPG", Width: 4000},
+			{Checksumpicture: "E", Title: "IMG_E1234.JPG", Width: 3000},
+			{Checksumpicture: "C", Title: "IMG_1234 (1).JPG", Width: 2000, Tags: "'bitgarten'"},
+		}
+	}
+	derived := map[string]bool{"E": true, "C": true}
+	deleted := func(pictures []*PictureByHash) map[string]bool {
+		m := make(map[string]bool)
+		for _, pbh := range pictures {
+			m[pbh.Checksumpicture] = pbh.delete
+		}
+		return m
+	}
+
+	pictures := group()
+	decision := (&DerivedNameRule{Name: "apple-edited", Prefer: PreferDerived}).Decide(DefaultRetentionPolicy, pictures, derived)
+	assert.Equal(t, "E", decision.Keep.Checksumpicture)
+	assert.Equal(t, "derived:apple-edited", decision.Rule)
+	assert.Equal(t, map[string]bool{"O": true, "E": false, "C": false}, deleted(pictures))
+
+	pictures = group()
+	decision = (&DerivedNameRule{Name: "copy", Prefer: PreferOriginal}).Decide(DefaultRetentionPolicy, pictures, derived)
+	assert.Equal(t, "O", decision.Keep.Checksumpicture)
+	assert.Equal(t, RuleWidest, decision.Rule)
+	assert.Equal(t, map[string]bool{"O": false, "E": true, "C": false}, deleted(pictures))
+
+	pictures = group()
+	decision = (&DerivedNameRule{Name: "whatsapp", Prefer: PreferPolicy}).Decide(DefaultRetentionPolicy, pictures, derived)
+	assert.Equal(t, "O", decision.Keep.Checksumpicture)
+}
@@ -0,0 +1,117 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+type DescriptorParameter struct {
+	ChkSum string
+	Limit  int
+	All    bool
+	Commit bool
+}
+
+type descriptorGenerate struct {
+	id        common.RegDbID
+	parameter *DescriptorParameter
+	generated uint64
+	failed    uint64
+}
+
+// Descriptor backfill BlurHash, average colour and dominant colours
+// out of the thumbnails of the pictures
+func Descriptor(parameter *DescriptorParameter) error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+	wid, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer wid.FreeHandler()
+
+	limit := "ALL"
+	if parameter.Limit > 0 {
+		limit = strconv.Itoa(parameter.Limit)
+	}
+	search := "thumbnail IS NOT NULL AND markdelete = false"
+	if !parameter.All {
+		search += " AND blurhash IS NULL"
+	}
+	if parameter.ChkSum != "" {
+		search = fmt.Sprintf("checksumpicture = '%s' AND ", parameter.ChkSum) + search
+	}
+	gen := &descriptorGenerate{id: wid, parameter: parameter}
+	q := &common.Query{TableName: "Pictures",
+		DataStruct:   &store.Pictures{},
+		Fields:       []string{"checksumpicture", "title", "Thumbnail"},
+		Search:       search,
+		Limit:        limit,
+		FctParameter: gen,
+	}
+	_, err = id.Query(q, generateDescriptor)
+	if err != nil {
+		log.Log.Errorf("Error descriptor query: %v", err)
+		fmt.Println("Error descriptor query ...:", err)
+		return err
+	}
+	fmt.Printf("Descriptors generated=%d failed=%d\n", gen.generated, gen.failed)
+	return nil
+}
+
+func generateDescriptor(search *common.Query, result *common.Result) error {
+	gen := search.FctParameter.(*descriptorGenerate)
+	pic := result.Data.(*store.Pictures)
+	d, err := store.CreateDescriptor(pic.Thumbnail)
+	if err != nil {
+		log.Log.Errorf("Error descriptor %s: %v", pic.ChecksumPicture, err)
+		fmt.Printf("Error descriptor %s(%s): %v\n", pic.ChecksumPicture, pic.Title, err)
+		gen.failed++
+		return nil
+	}
+	log.Log.Debugf("Descriptor %s: %s %s %s", pic.ChecksumPicture, d.BlurHash, d.AverageColor, d.DominantColors)
+	gen.generated++
+	if !gen.parameter.Commit {
+		return nil
+	}
+	update := &common.Entries{
+		Fields: []string{"blurhash", "averagecolor", "dominantcolors"},
+		Values: [][]any{{d.BlurHash, d.AverageColor, d.DominantColors}},
+		Update: []string{fmt.Sprintf("checksumpicture = '%s'", pic.ChecksumPicture)},
+	}
+	_, _, err = gen.id.Update("pictures", update)
+	if err != nil {
+		fmt.Println("Error updating descriptor", pic.ChecksumPicture, ":", err)
+		return err
+	}
+	return gen.id.Commit()
+}
@@ -0,0 +1,275 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const readMovedAlbumEntries = `
+SELECT ap.albumid, ap."index"::int4 AS index, COALESCE(ap.name, '') AS name,
+  COALESCE(ap.description, '') AS description, COALESCE(ap.mimetype, '') AS mimetype,
+  COALESCE(ap.fill, '') AS fill, COALESCE(ap.skiptime, 0)::int4 AS skiptime,
+  COALESCE(ap.height, 0)::int4 AS height, COALESCE(ap.width, 0)::int4 AS width,
+  EXISTS (SELECT 1 FROM albumpictures k WHERE k.albumid = ap.albumid AND k.checksumpicture = $2) AS removed
+  FROM albumpictures ap WHERE ap.checksumpicture = $1
+`
+
+const readMovedThumbnails = `SELECT id FROM albums WHERE thumbnailhash = $1`
+
+const readMovedLocations = `
+SELECT picturename, picturehost, picturedirectory FROM picturelocations WHERE checksumpicture = $1
+`
+
+// movedReferences references of a duplicate moved onto the kept picture,
+// recorded in the mark delete journal to be reversed by restore
+type movedReferences struct {
+	Keep       string             `json:"keep"`
+	Albums     []*movedAlbumEntry `json:"albums,omitempty"`
+	Thumbnails []int              `json:"thumbnails,omitempty"`
+	Locations  []*movedLocation   `json:"locations,omitempty"`
+}
+
+// movedAlbumEntry previous album entry of the duplicate, removed entries
+// are deleted because the album already contains the kept picture
+type movedAlbumEntry struct {
+	Albumid     int    `json:"albumid"`
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Mimetype    string `json:"mimetype"`
+	Fill        string `json:"fill"`
+	Skiptime    int    `json:"skiptime"`
+	Height      int    `json:"height"`
+	Width       int    `json:"width"`
+	Removed     bool   `json:"removed"`
+}
+
+// movedLocation previous picture location of the duplicate
+type movedLocation struct {
+	Picturename      string `json:"picturename"`
+	Picturehost      string `json:"picturehost"`
+	Picturedirectory string `json:"picturedirectory"`
+}
+
+// readMovedReferences read album entries, album thumbnails and picture
+// locations of the duplicate before they are moved
+func readMovedReferences(id common.RegDbID, keep, duplicate string) (*movedReferences, error) {
+	moved := &movedReferences{Keep: keep}
+	err := id.BatchSelectFct(&common.Query{
+		TableName:  "albumpictures",
+		DataStruct: &movedAlbumEntry{},
+		Search:     readMovedAlbumEntries,
+		Parameters: []any{duplicate, keep},
+	}, func(search *common.Query, result *common.Result) error {
+		e := &movedAlbumEntry{}
+		*e = *result.Data.(*movedAlbumEntry)
+		moved.Albums = append(moved.Albums, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = id.BatchSelectFct(&common.Query{
+		TableName:  "albums",
+		Search:     readMovedThumbnails,
+		Parameters: []any{duplicate},
+	}, func(search *common.Query, result *common.Result) error {
+		moved.Thumbnails = append(moved.Thumbnails, int(result.Rows[0].(int32)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = id.BatchSelectFct(&common.Query{
+		TableName:  "picturelocations",
+		DataStruct: &movedLocation{},
+		Search:     readMovedLocations,
+		Parameters: []any{duplicate},
+	}, func(search *common.Query, result *common.Result) error {
+		l := &movedLocation{}
+		*l = *result.Data.(*movedLocation)
+		moved.Locations = append(moved.Locations, l)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// moveReferences move the album entries, the tags and the picture locations
+// of the duplicate onto the kept picture. Index and description of the album
+// entries are not changed, so the album order is preserved. Album entries of
+// albums already containing the kept picture are removed. Tags not part of
+// the tag map are removed. The previous references are returned for the
+// journal. Runs in the transaction of the handler.
+func moveReferences(id common.RegDbID, tagMap, keepTags map[string]bool, keep, duplicate *PictureByHash) (*movedReferences, error) {
+	criteria := "checksumpicture='" + duplicate.Checksumpicture + "'"
+
+	moved, err := readMovedReferences(id, keep.Checksumpicture, duplicate.Checksumpicture)
+	if err != nil {
+		log.Log.Errorf("Error reading references of %s: %v", duplicate.Checksumpicture, err)
+		return nil, err
+	}
+	dr, err := id.Delete("albumpictures", &common.Entries{
+		Criteria: criteria + " AND albumid IN (SELECT albumid FROM albumpictures WHERE checksumpicture='" +
+			keep.Checksumpicture + "')"})
+	if err != nil {
+		log.Log.Errorf("Error removing album pictures of %s: %v", duplicate.Checksumpicture, err)
+		return nil, err
+	}
+	if dr > 0 {
+		fmt.Printf("Removed %d album entries of %s already containing %s\n", dr, duplicate.Checksumpicture, keep.Checksumpicture)
+	}
+
+	fields := []string{"checksumpicture", "height", "width"}
+	values := []any{keep.Checksumpicture, keep.Height, keep.Width}
+	if keep.Mimetype != "" {
+		fields = append(fields, "mimetype")
+		values = append(values, keep.Mimetype)
+	}
+	_, n, err := id.Update("albumpictures", &common.Entries{
+		Fields: fields,
+		Update: []string{criteria},
+		Values: [][]any{values},
+	})
+	if err != nil {
+		log.Log.Errorf("Error moving album pictures of %s: %v", duplicate.Checksumpicture, err)
+		return nil, err
+	}
+	if n > 0 {
+		fmt.Printf("Moved %d album entries from %s to %s\n", n, duplicate.Checksumpicture, keep.Checksumpicture)
+	}
+	_, _, err = id.Update("albums", &common.Entries{
+		Fields: []string{"thumbnailhash"},
+		Update: []string{"thumbnailhash='" + duplicate.Checksumpicture + "'"},
+		Values: [][]any{{keep.Checksumpicture}},
+	})
+	if err != nil {
+		log.Log.Errorf("Error moving album thumbnail of %s: %v", duplicate.Checksumpicture, err)
+		return nil, err
+	}
+
+	_, n, err = id.Update("picturelocations", &common.Entries{
+		Fields: []string{"checksumpicture"},
+		Update: []string{criteria},
+		Values: [][]any{{keep.Checksumpicture}},
+	})
+	if err != nil {
+		log.Log.Errorf("Error moving picture locations of %s: %v", duplicate.Checksumpicture, err)
+		return nil, err
+	}
+	log.Log.Debugf("Moved %d locations from %s to %s", n, duplicate.Checksumpicture, keep.Checksumpicture)
+
+	if duplicate.Tags == "" {
+		return moved, nil
+	}
+	for _, t := range strings.Split(duplicate.Tags, ",") {
+		if t == "" || !tagMap[t] || keepTags[t] {
+			continue
+		}
+		keepTags[t] = true
+		fmt.Printf("Move tag <%s> from %s to %s\n", t, duplicate.Checksumpicture, keep.Checksumpicture)
+		_, _, err = id.Update("picturetags", &common.Entries{
+			Fields: []string{"checksumpicture"},
+			Update: []string{criteria, "tagname=" + quoteTag(t)},
+			Values: [][]any{{keep.Checksumpicture}},
+		})
+		if err != nil {
+			log.Log.Errorf("Error moving tag %s of %s: %v", t, duplicate.Checksumpicture, err)
+			return nil, err
+		}
+	}
+	dr, err = id.Delete("picturetags", &common.Entries{Criteria: criteria})
+	if err != nil {
+		return nil, err
+	}
+	log.Log.Debugf("%d remaining tags of %s deleted", dr, duplicate.Checksumpicture)
+	return moved, nil
+}
+
+// restoreReferences move the journaled references back from the kept
+// picture to the restored duplicate. Runs in the transaction of the handler.
+func restoreReferences(id common.RegDbID, checksumpicture string, moved *movedReferences) error {
+	keepCriteria := "checksumpicture='" + moved.Keep + "'"
+	for _, e := range moved.Albums {
+		if !e.Removed {
+			_, n, err := id.Update("albumpictures", &common.Entries{
+				Fields: []string{"checksumpicture", "mimetype", "height", "width"},
+				Update: []string{keepCriteria, fmt.Sprintf("albumid=%d", e.Albumid),
+					fmt.Sprintf("index=%d", e.Index)},
+				Values: [][]any{{checksumpicture, e.Mimetype, e.Height, e.Width}},
+			})
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				continue
+			}
+		}
+		_, err := id.Insert("albumpictures", &common.Entries{
+			Fields: []string{"albumid", "index", "name", "description", "checksumpicture",
+				"mimetype", "fill", "skiptime", "height", "width"},
+			Values: [][]any{{e.Albumid, e.Index, e.Name, e.Description, checksumpicture,
+				e.Mimetype, e.Fill, e.Skiptime, e.Height, e.Width}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, albumID := range moved.Thumbnails {
+		_, _, err := id.Update("albums", &common.Entries{
+			Fields: []string{"thumbnailhash"},
+			Update: []string{fmt.Sprintf("id=%d", albumID), "thumbnailhash='" + moved.Keep + "'"},
+			Values: [][]any{{checksumpicture}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range moved.Locations {
+		_, _, err := id.Update("picturelocations", &common.Entries{
+			Fields: []string{"checksumpicture"},
+			Update: []string{keepCriteria, "picturename=" + sqlQuote(l.Picturename),
+				"picturehost=" + sqlQuote(l.Picturehost), "picturedirectory=" + sqlQuote(l.Picturedirectory)},
+			Values: [][]any{{checksumpicture}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteTag quote the tag as SQL string, the tag list contains the tags
+// already in single quotes
+func quoteTag(tag string) string {
+	return sqlQuote(strings.Trim(tag, "'"))
+}
+
+// sqlQuote quote the value as SQL string
+func sqlQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
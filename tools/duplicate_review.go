@@ -0,0 +1,335 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/services"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const readReviewDetails = `
+select p.checksumpicture, p.thumbnail,
+COALESCE(( SELECT string_agg(DISTINCT a.title::text, ', '::text) AS string_agg
+           FROM albumpictures ap, albums a
+          WHERE ap.albumid = a.id AND ap.checksumpicture::text = p.checksumpicture::text), '') AS albums
+from pictures p where checksumpicture IN ({{range $i, $c := .}}{{if $i}},{{end}}'{{$c}}'{{end}});
+`
+
+const reviewReport = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Duplicate review {{.Created.Format "2006-01-02 15:04"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+tr.keep { background: #e6f4e6; }
+tr.delete { background: #f8e0e0; }
+img { max-width: 200px; max-height: 200px; }
+</style>
+</head>
+<body>
+<h1>Duplicate review</h1>
+<p>Created {{.Created.Format "2006-01-02 15:04:05"}} with {{len .Groups}} groups. Edit the decisions file and apply it with <code>hashclean --apply</code>.</p>
+{{range $i, $g := .Groups}}
+<h2>{{inc $i}}. Group {{$g.Key}}</h2>
+<p>Decided by rule <b>{{$g.Rule}}</b></p>
+<table>
+<tr><th>Thumbnail</th><th>Title</th><th>Checksum</th><th>Dimension</th><th>Quality</th><th>Tags</th><th>Albums</th><th>Decision</th></tr>
+{{range $g.Pictures}}<tr class="{{if .Delete}}delete{{else}}keep{{end}}">
+<td>{{if .Thumbnail}}<img src="{{.Thumbnail}}">{{end}}</td>
+<td>{{.Title}}</td><td>{{.Checksumpicture}}</td><td>{{.Width}}x{{.Height}}</td>
+<td>{{printf "%.2f" .Quality}}</td><td>{{.Tags}}</td><td>{{.Albums}}</td>
+<td>{{if .Delete}}delete{{else}}keep{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`
+
+// ReviewPicture picture of a duplicate group with the proposed decision
+type ReviewPicture struct {
+	Checksumpicture string       `json:"checksumpicture"`
+	Title           string       `json:"title"`
+	Width           int          `json:"width"`
+	Height          int          `json:"height"`
+	Quality         float64      `json:"quality"`
+	Tags            string       `json:"tags,omitempty"`
+	Albums          string       `json:"albums,omitempty"`
+	Delete          bool         `json:"delete"`
+	Thumbnail       template.URL `json:"-"`
+}
+
+// checksumRegexp MD5 checksum of a picture as stored in the database
+var checksumRegexp = regexp.MustCompile(`^[0-9A-F]{32}$`)
+
+// ReviewToolBursts tool of review groups proposed by bursts, these frames
+// are only marked deleted when applied
+const ReviewToolBursts = "bursts"
+
+// ReviewGroup duplicate group and the picture to keep
+type ReviewGroup struct {
+	Key      string           `json:"key"`
+	Tool     string           `json:"tool,omitempty"`
+	Keep     string           `json:"keep"`
+	Rule     string           `json:"rule"`
+	Pictures []*ReviewPicture `json:"pictures"`
+}
+
+// DuplicateReview all duplicate groups proposed by hashclean, it is written
+// as HTML report and as decisions file which can be edited and applied
+type DuplicateReview struct {
+	Created time.Time      `json:"created"`
+	Groups  []*ReviewGroup `json:"groups"`
+	tool    string
+}
+
+type reviewDetail struct {
+	Checksumpicture string
+	Thumbnail       []byte
+	Albums          string
+}
+
+// add the proposed decision of a duplicate group
+func (review *DuplicateReview) add(key string, decision *RetentionDecision, picturesByHash []*PictureByHash) {
+	group := &ReviewGroup{Key: key, Tool: review.tool, Keep: decision.Keep.Checksumpicture, Rule: decision.Rule}
+	for _, pbh := range picturesByHash {
+		group.Pictures = append(group.Pictures, &ReviewPicture{Checksumpicture: pbh.Checksumpicture,
+			Title: pbh.Title, Width: pbh.Width, Height: pbh.Height, Quality: pbh.Quality,
+			Tags: pbh.Tags, Delete: pbh.delete})
+	}
+	review.Groups = append(review.Groups, group)
+}
+
+// write the HTML report and the decisions file if the file names are given
+func (review *DuplicateReview) write(report, decisions string) error {
+	if decisions != "" {
+		err := review.WriteDecisions(decisions)
+		if err != nil {
+			return err
+		}
+		services.ServerMessage("Wrote %d decisions to %s", len(review.Groups), decisions)
+	}
+	if report != "" {
+		err := review.readDetails()
+		if err != nil {
+			return err
+		}
+		err = review.WriteReport(report)
+		if err != nil {
+			return err
+		}
+		services.ServerMessage("Wrote review report of %d groups to %s", len(review.Groups), report)
+	}
+	return nil
+}
+
+// readDetails read thumbnails and albums of all pictures shown in the report
+func (review *DuplicateReview) readDetails() error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	for _, group := range review.Groups {
+		pictures := make(map[string]*ReviewPicture)
+		checksums := make([]string, 0, len(group.Pictures))
+		for _, p := range group.Pictures {
+			pictures[p.Checksumpicture] = p
+			checksums = append(checksums, p.Checksumpicture)
+		}
+		sqlCmd, err := templateSql(readReviewDetails, checksums)
+		if err != nil {
+			return err
+		}
+		query := &common.Query{
+			TableName:  "pictures",
+			DataStruct: &reviewDetail{},
+			Search:     sqlCmd,
+		}
+		err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+			d := result.Data.(*reviewDetail)
+			if p, ok := pictures[d.Checksumpicture]; ok {
+				p.Albums = d.Albums
+				if len(d.Thumbnail) > 0 {
+					p.Thumbnail = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(d.Thumbnail))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Log.Errorf("Error reading review details: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteReport write the self-contained HTML report with embedded thumbnails
+func (review *DuplicateReview) WriteReport(fileName string) error {
+	t := template.Must(template.New("report").Funcs(template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(reviewReport))
+	f, err := os.Create(fileName)
+	if err != nil {
+		fmt.Println("Error creating report:", err)
+		return err
+	}
+	defer f.Close()
+	return t.Execute(f, review)
+}
+
+// WriteDecisions write the decisions file
+func (review *DuplicateReview) WriteDecisions(fileName string) error {
+	data, err := json.MarshalIndent(review, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// ReadDecisions read a decisions file written by the review
+func ReadDecisions(fileName string) (*DuplicateReview, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	review := &DuplicateReview{}
+	err = json.Unmarshal(data, review)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing decisions %s: %v", fileName, err)
+	}
+	// the checksums are used in SQL statements
+	for _, group := range review.Groups {
+		if group.Keep != "" && !checksumRegexp.MatchString(group.Keep) {
+			return nil, fmt.Errorf("invalid checksum '%s' of group %s in decisions %s", group.Keep, group.Key, fileName)
+		}
+		for _, p := range group.Pictures {
+			if !checksumRegexp.MatchString(p.Checksumpicture) {
+				return nil, fmt.Errorf("invalid checksum '%s' of group %s in decisions %s", p.Checksumpicture, group.Key, fileName)
+			}
+		}
+	}
+	return review, nil
+}
+
+// applyGroup transfer the reviewed decisions to the current pictures of
+// the group, protected pictures are never deleted. The picture to keep and
+// the tags of the group are returned.
+func (group *ReviewGroup) applyGroup(picturesByHash []*PictureByHash, policy *RetentionPolicy) (*PictureByHash, map[string]bool, error) {
+	current := make(map[string]*PictureByHash)
+	for _, pbh := range picturesByHash {
+		current[pbh.Checksumpicture] = pbh
+	}
+	var keep *PictureByHash
+	tagMap := make(map[string]bool)
+	for _, p := range group.Pictures {
+		pbh, ok := current[p.Checksumpicture]
+		if !ok {
+			return nil, nil, fmt.Errorf("picture %s not found or already marked deleted", p.Checksumpicture)
+		}
+		pbh.delete = p.Delete
+		if pbh.delete && policy.Protected(pbh) {
+			fmt.Printf("Picture %s is protected and not deleted\n", pbh.Checksumpicture)
+			pbh.delete = false
+		}
+		if !p.Delete && (keep == nil || p.Checksumpicture == group.Keep) {
+			keep = pbh
+		}
+		if policy.MergeTags {
+			policy.mergeTags(tagMap, pbh)
+		}
+	}
+	if keep == nil {
+		return nil, nil, fmt.Errorf("no picture kept")
+	}
+	return keep, tagMap, nil
+}
+
+// ApplyDecisions mark the pictures deleted as decided in the reviewed
+// decisions file. Groups changed since the review are skipped. Burst
+// frames are only marked deleted, references of duplicates are moved onto
+// the kept picture.
+func ApplyDecisions(fileName string, policy *RetentionPolicy, commit bool) error {
+	if policy == nil {
+		policy = DefaultRetentionPolicy
+	}
+	review, err := ReadDecisions(fileName)
+	if err != nil {
+		fmt.Println("Error reading decisions:", err)
+		return err
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	applied := 0
+	for i, group := range review.Groups {
+		checksums := make([]string, 0, len(group.Pictures))
+		for _, p := range group.Pictures {
+			checksums = append(checksums, p.Checksumpicture)
+		}
+		sqlCmd, err := templateSql(readPictureByChecksums, checksums)
+		if err != nil {
+			return err
+		}
+		picturesByHash, err := readPicturesByHash(id, sqlCmd)
+		if err != nil {
+			return err
+		}
+		keep, tagMap, err := group.applyGroup(picturesByHash, policy)
+		if err != nil {
+			fmt.Printf("Skip %d.Group %s: %v\n", i+1, group.Key, err)
+			continue
+		}
+		fmt.Printf("Apply %d.Group %s keep %s\n", i+1, group.Key, keep.Checksumpicture)
+		if group.Tool == ReviewToolBursts {
+			err = markBurstDelete(commit, fmt.Sprintf("reviewed burst frame of %s in %s", keep.Checksumpicture, group.Key),
+				picturesByHash)
+		} else {
+			err = cleanUpPictures(commit, fmt.Sprintf("reviewed duplicate of %s in %s", keep.Checksumpicture, group.Key),
+				tagMap, keep, picturesByHash)
+		}
+		if err != nil {
+			fmt.Println("Error cleanup pictures:", err)
+			return err
+		}
+		applied++
+	}
+	services.ServerMessage("Applied %d of %d groups commit=%v", applied, len(review.Groups), commit)
+	return nil
+}
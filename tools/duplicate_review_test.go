@@ -0,0 +1,118 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	checksumA = strings.Repeat("A", 32)
+	checksumB = strings.Repeat("B", 32)
+	checksumC = strings.Repeat("C", 32)
+)
+
+func testDuplicateGroup() []*PictureByHash {
+	return []*PictureByHash{
+		{Checksumpicture: checksumA, Title: "a.jpg", Width: 800, Height: 600, Tags: "'holiday'"},
+		{Checksumpicture: checksumB, Title: "b.jpg", Width: 1600, Height: 1200, Quality: 0.5},
+		{Checksumpicture: checksumC, Title: "c.jpg", Width: 1600, Height: 1200, Quality: 0.8, Tags: "'bitgarten','family'"},
+	}
+}
+
+func TestDuplicateReview(t *testing.T) {
+	pictures := testDuplicateGroup()
+	decision := DefaultRetentionPolicy.Decide(pictures)
+	review := &DuplicateReview{Created: time.Now()}
+	review.add("123", decision, pictures)
+	assert.Len(t, review.Groups, 1)
+	assert.Equal(t, checksumC, review.Groups[0].Keep)
+	assert.Equal(t, RuleProtected, review.Groups[0].Rule)
+
+	dir := t.TempDir()
+	decisions := filepath.Join(dir, "decisions.json")
+	assert.NoError(t, review.WriteDecisions(decisions))
+	report := filepath.Join(dir, "review.html")
+	assert.NoError(t, review.WriteReport(report))
+	html, err := os.ReadFile(report)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(html), "1600x1200"))
+	assert.True(t, strings.Contains(string(html), "1. Group 123"))
+
+	read, err := ReadDecisions(decisions)
+	assert.NoError(t, err)
+	assert.Len(t, read.Groups[0].Pictures, 3)
+	assert.Empty(t, read.Groups[0].Tool)
+	// reviewer decides to keep A instead of C
+	for _, p := range read.Groups[0].Pictures {
+		p.Delete = p.Checksumpicture != checksumA
+	}
+	group := testDuplicateGroup()
+	keep, tagMap, err := read.Groups[0].applyGroup(group, DefaultRetentionPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, checksumA, keep.Checksumpicture)
+	assert.Len(t, tagMap, 2)
+	assert.True(t, group[1].delete)
+	// protected picture is kept even if the reviewer deletes it
+	assert.False(t, group[2].delete)
+
+	for _, p := range read.Groups[0].Pictures {
+		p.Delete = p.Checksumpicture != checksumC
+	}
+	keep, _, err = read.Groups[0].applyGroup(testDuplicateGroup(), DefaultRetentionPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, checksumC, keep.Checksumpicture)
+	for _, p := range read.Groups[0].Pictures {
+		p.Delete = true
+	}
+	_, _, err = read.Groups[0].applyGroup(testDuplicateGroup(), DefaultRetentionPolicy)
+	assert.Error(t, err)
+	_, _, err = read.Groups[0].applyGroup(testDuplicateGroup()[:2], DefaultRetentionPolicy)
+	assert.Error(t, err)
+}
+
+func TestBurstReviewTool(t *testing.T) {
+	pictures := testDuplicateGroup()
+	review := &DuplicateReview{Created: time.Now(), tool: ReviewToolBursts}
+	review.add("burst", DefaultRetentionPolicy.Decide(pictures), pictures)
+	decisions := filepath.Join(t.TempDir(), "bursts.json")
+	assert.NoError(t, review.WriteDecisions(decisions))
+	read, err := ReadDecisions(decisions)
+	if assert.NoError(t, err) {
+		assert.Equal(t, ReviewToolBursts, read.Groups[0].Tool)
+	}
+}
+
+func TestReadDecisionsChecksum(t *testing.T) {
+	decisions := filepath.Join(t.TempDir(), "decisions.json")
+	for _, checksum := range []string{"A' OR '1'='1", strings.ToLower(checksumA), checksumA[1:]} {
+		review := &DuplicateReview{Groups: []*ReviewGroup{{Key: "1", Keep: checksumA,
+			Pictures: []*ReviewPicture{{Checksumpicture: checksumA}, {Checksumpicture: checksum}}}}}
+		assert.NoError(t, review.WriteDecisions(decisions))
+		_, err := ReadDecisions(decisions)
+		assert.Error(t, err, checksum)
+	}
+}
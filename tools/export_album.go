@@ -0,0 +1,532 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+	"github.com/tknie/services"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// AlbumManifestName name of the manifest written into each album folder
+const AlbumManifestName = "album.json"
+
+const manifestTimeFormat = "2006-01-02T15:04:05"
+
+const readAlbumExport = `
+SELECT ap."index"::int4 AS "index", COALESCE(ap.name, '') AS name, COALESCE(ap.description, '') AS description,
+  COALESCE(ap.skiptime, 0)::int4 AS skiptime, p.checksumpicture, p.sha256checksum,
+  COALESCE(p.title, '') AS title, COALESCE(p.mimetype, '') AS mimetype,
+  COALESCE(p.width, 0) AS width, COALESCE(p.height, 0) AS height,
+  COALESCE(p.exifmodel, '') AS exifmodel, COALESCE(p.exifmake, '') AS exifmake,
+  COALESCE(to_char(p.exiforigtime, 'YYYY-MM-DD"T"HH24:MI:SS'), '') AS exiforigtime,
+  COALESCE(to_char(p.exiftaken, 'YYYY-MM-DD"T"HH24:MI:SS'), '') AS exiftaken,
+  COALESCE(p.exiforientation, '') AS exiforientation, p.gpslatitude, p.gpslongitude,
+  COALESCE(p.picopt, '') AS picopt,
+  COALESCE(( SELECT string_agg(pt.tagname::text, ','::text ORDER BY pt.tagname::text)
+           FROM picturetags pt
+          WHERE pt.checksumpicture::text = p.checksumpicture::text), '') AS tags
+  FROM albumpictures ap, pictures p
+  WHERE ap.checksumpicture::text = p.checksumpicture::text AND ap.albumid = $1
+  ORDER BY ap."index"
+`
+
+// AlbumManifest album information written as album.json into the album
+// folder, it contains everything needed to re-import the album
+type AlbumManifest struct {
+	Title         string                  `json:"title"`
+	Description   string                  `json:"description,omitempty"`
+	Key           string                  `json:"key,omitempty"`
+	Directory     string                  `json:"directory,omitempty"`
+	Type          string                  `json:"type,omitempty"`
+	Option        string                  `json:"option,omitempty"`
+	ThumbnailHash string                  `json:"thumbnailhash,omitempty"`
+	Published     time.Time               `json:"published"`
+	Exported      time.Time               `json:"exported"`
+	Pictures      []*AlbumManifestPicture `json:"pictures"`
+}
+
+// AlbumManifestPicture album entry with the exported file name
+type AlbumManifestPicture struct {
+	Index           int           `json:"index"`
+	File            string        `json:"file"`
+	Name            string        `json:"name,omitempty"`
+	Description     string        `json:"description,omitempty"`
+	Title           string        `json:"title"`
+	Checksumpicture string        `json:"checksumpicture"`
+	Sha256checksum  string        `json:"sha256checksum"`
+	Mimetype        string        `json:"mimetype"`
+	Size            int64         `json:"size,omitempty"`
+	Width           int           `json:"width"`
+	Height          int           `json:"height"`
+	Skiptime        int           `json:"skiptime,omitempty"`
+	Tags            []string      `json:"tags,omitempty"`
+	Exif            *ManifestExif `json:"exif,omitempty"`
+}
+
+// ManifestExif EXIF information of the picture
+type ManifestExif struct {
+	Model       string  `json:"model,omitempty"`
+	Make        string  `json:"make,omitempty"`
+	OrigTime    string  `json:"origtime,omitempty"`
+	Taken       string  `json:"taken,omitempty"`
+	Orientation string  `json:"orientation,omitempty"`
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+}
+
+type albumExportPicture struct {
+	Index           int
+	Name            string
+	Description     string
+	Skiptime        int
+	Checksumpicture string
+	Sha256checksum  string
+	Title           string
+	Mimetype        string
+	Width           int
+	Height          int
+	Exifmodel       string
+	Exifmake        string
+	Exiforigtime    string
+	Exiftaken       string
+	Exiforientation string
+	Gpslatitude     float64
+	Gpslongitude    float64
+	Picopt          string
+	Tags            string
+}
+
+// manifestPicture album entry of the manifest with the file name ordered
+// by album index
+func (ep *albumExportPicture) manifestPicture() *AlbumManifestPicture {
+	mp := &AlbumManifestPicture{Index: ep.Index, Name: ep.Name, Description: ep.Description,
+		Title: ep.Title, Checksumpicture: ep.Checksumpicture, Sha256checksum: ep.Sha256checksum,
+		Mimetype: ep.Mimetype, Width: ep.Width, Height: ep.Height, Skiptime: ep.Skiptime,
+		File: fmt.Sprintf("%04d-%s", ep.Index, safeFileName(ep.Title))}
+	if ep.Tags != "" {
+		mp.Tags = strings.Split(ep.Tags, ",")
+	}
+	exif := &ManifestExif{Model: ep.Exifmodel, Make: ep.Exifmake, OrigTime: ep.Exiforigtime,
+		Taken: ep.Exiftaken, Orientation: ep.Exiforientation,
+		Latitude: ep.Gpslatitude, Longitude: ep.Gpslongitude}
+	if *exif != (ManifestExif{}) {
+		mp.Exif = exif
+	}
+	return mp
+}
+
+// privacy decide the privacy filter of the album picture by its tags and
+// all albums of the picture like the plain export, the GPS position of the
+// entry is filtered
+func (ep *albumExportPicture) privacy(id common.RegDbID) (*PrivacyDecision, error) {
+	decision, err := exportParameter.Privacy.readPrivacyDecision(id, ep.Checksumpicture)
+	if err != nil {
+		return nil, err
+	}
+	ep.Gpslatitude, ep.Gpslongitude = decision.Coordinates(ep.Gpslatitude, ep.Gpslongitude)
+	return decision, nil
+}
+
+// safeFileName replace all characters not allowed in file names, trailing
+// dots and spaces are removed
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// ExportAlbums write each album into a folder with the pictures ordered and
+// named by the album index and the album.json manifest
+func ExportAlbums(parameter *ExportMediaParameter) error {
+	if parameter.Directory == "" {
+		parameter.Directory = "./"
+	}
+	exportParameter = parameter
+	di, err := sql.DatabaseConnect()
+	if err != nil {
+		return err
+	}
+	titles := []string{parameter.Album}
+	if parameter.AllAlbums {
+		titles, err = di.ListAlbums()
+		if err != nil {
+			fmt.Println("List albums error:", err)
+			return err
+		}
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+	var archive *MediaArchive
+	if parameter.Archive != "" {
+		archive, err = OpenMediaArchive(parameter.Archive, parameter.ArchiveFormat)
+		if err != nil {
+			fmt.Println("Error creating archive:", err)
+			return err
+		}
+		defer archive.Close()
+	}
+
+	for _, title := range titles {
+		found, err := di.CheckAlbum(&sql.Albums{Title: title})
+		if err != nil {
+			return err
+		}
+		if found == 0 {
+			fmt.Println("Album not found:", title)
+			if !parameter.AllAlbums {
+				return fmt.Errorf("album %s not found", title)
+			}
+			continue
+		}
+		album, err := di.ReadAlbum(title)
+		if err != nil {
+			fmt.Println("Error reading album:", err)
+			return err
+		}
+		if archive != nil {
+			err = exportAlbum(id, album, safeFileName(album.Title), archive)
+		} else {
+			err = exportAlbum(id, album, filepath.Join(parameter.Directory, safeFileName(album.Title)), nil)
+		}
+		if err != nil {
+			fmt.Println("Error exporting album:", title, err)
+			return err
+		}
+	}
+	if archive != nil {
+		return archive.Close()
+	}
+	return nil
+}
+
+// exportAlbum write the media of all album pictures and the manifest into
+// the directory or, if given, into the directory of the archive
+func exportAlbum(id common.RegDbID, album *sql.Albums, directory string, archive *MediaArchive) error {
+	manifest := &AlbumManifest{Title: album.Title, Description: album.Description,
+		Key: album.Key, Directory: album.Directory, Type: album.Type, Option: album.Option,
+		ThumbnailHash: album.ThumbnailHash, Published: album.Published, Exported: time.Now()}
+	pictures := make([]*albumExportPicture, 0)
+	query := &common.Query{
+		TableName:  "albumpictures",
+		DataStruct: &albumExportPicture{},
+		Search:     readAlbumExport,
+		Parameters: []any{album.Id},
+	}
+	err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		ep := &albumExportPicture{}
+		*ep = *result.Data.(*albumExportPicture)
+		pictures = append(pictures, ep)
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Error reading album pictures: %v", err)
+		return err
+	}
+	if archive != nil {
+		services.ServerMessage("Archive album %s with %d pictures", album.Title, len(pictures))
+		return archiveAlbum(id, archive, manifest, pictures, directory)
+	}
+	err = os.MkdirAll(directory, 0755)
+	if err != nil {
+		return err
+	}
+	services.ServerMessage("Export album %s with %d pictures to %s", album.Title, len(pictures), directory)
+	for _, ep := range pictures {
+		decision, err := ep.privacy(id)
+		if err != nil {
+			fmt.Printf("Error reading privacy of %s: %v\n", ep.Checksumpicture, err)
+			atomic.AddUint64(&statCount.errors, 1)
+			continue
+		}
+		mp := ep.manifestPicture()
+		fileName := filepath.Join(directory, mp.File)
+		err = exportAlbumMedia(id, ep, mp, fileName, decision)
+		if err != nil {
+			fmt.Printf("Error exporting %s: %v\n", ep.Checksumpicture, err)
+			atomic.AddUint64(&statCount.errors, 1)
+			continue
+		}
+		if exportParameter.Sidecar {
+			writeExportSidecar(fileName, albumXmpData(ep))
+		}
+		manifest.Pictures = append(manifest.Pictures, mp)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(directory, AlbumManifestName), data, 0644)
+}
+
+// albumCopy check if the album picture is exported as filtered or
+// embedded copy instead of the stored media
+func (ep *albumExportPicture) albumCopy(decision *PrivacyDecision) bool {
+	return decision.Active() || (exportParameter.Embed && isJPEGType(ep.Mimetype))
+}
+
+// albumCopyMedia read the media of the album picture filtered by the
+// privacy decision and with the album metadata embedded into JPEGs
+func albumCopyMedia(id common.RegDbID, ep *albumExportPicture, decision *PrivacyDecision) ([]byte, error) {
+	var media []byte
+	var err error
+	if decision.Active() {
+		media, err = privacyMedia(id, ep.Checksumpicture, ep.Sha256checksum, ep.Picopt, nil, decision)
+	} else {
+		media, err = verifiedMedia(id, ep.Checksumpicture, ep.Sha256checksum, ep.Picopt, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if exportParameter.Embed && isJPEG(media) {
+		return EmbedJPEG(media, albumXmpData(ep))
+	}
+	return media, nil
+}
+
+// exportAlbumMedia write the media of the picture, an existing file with
+// the same checksum is kept. Media filtered by the privacy profile or with
+// embedded metadata is always written, the manifest entry gets the
+// checksums and the size of the written file.
+func exportAlbumMedia(id common.RegDbID, ep *albumExportPicture, mp *AlbumManifestPicture,
+	fileName string, decision *PrivacyDecision) error {
+	atomic.AddUint64(&statCount.processed, 1)
+	if ep.albumCopy(decision) {
+		media, err := albumCopyMedia(id, ep, decision)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fileName, media, 0644)
+		if err != nil {
+			return err
+		}
+		mp.Checksumpicture, mp.Sha256checksum = store.CreateMd5(media), store.CreateSHA(media)
+		mp.Size = int64(len(media))
+		atomic.AddUint64(&statCount.wrote, 1)
+		log.Log.Debugf("Write album media copy %s", fileName)
+		return nil
+	}
+	if verifyMediaFile(fileName, ep.Checksumpicture, ep.Sha256checksum) == nil {
+		atomic.AddUint64(&statCount.found, 1)
+		return setManifestSize(mp, fileName)
+	}
+	switch ep.Picopt {
+	case "webstore":
+		err := downloadMedia(ep.Checksumpicture, ep.Sha256checksum, fileName)
+		if err != nil {
+			return err
+		}
+	default:
+		media, err := readMedia(id, ep.Checksumpicture)
+		if err != nil {
+			return err
+		}
+		if md5 := store.CreateMd5(media); md5 != ep.Checksumpicture {
+			return fmt.Errorf("checksum of media fails %s != %s", md5, ep.Checksumpicture)
+		}
+		if sha := store.CreateSHA(media); sha != ep.Sha256checksum {
+			return fmt.Errorf("SHA-256 checksum of media fails %s != %s", sha, ep.Sha256checksum)
+		}
+		err = os.WriteFile(fileName, media, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	atomic.AddUint64(&statCount.wrote, 1)
+	log.Log.Debugf("Write album media file %s", fileName)
+	return setManifestSize(mp, fileName)
+}
+
+// setManifestSize set the size of the exported file in the manifest entry
+func setManifestSize(mp *AlbumManifestPicture, fileName string) error {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	mp.Size = info.Size()
+	return nil
+}
+
+// readMedia read the media of the picture stored in the database
+func readMedia(id common.RegDbID, checksum string) ([]byte, error) {
+	var media []byte
+	query := &common.Query{
+		TableName: "pictures",
+		Fields:    []string{"media"},
+		Search:    "checksumpicture='" + checksum + "'",
+	}
+	_, err := id.Query(query, func(search *common.Query, result *common.Result) error {
+		media = result.Rows[0].([]byte)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// ReadAlbumManifest read the manifest of an exported album folder
+func ReadAlbumManifest(directory string) (*AlbumManifest, error) {
+	data, err := os.ReadFile(filepath.Join(directory, AlbumManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &AlbumManifest{}
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing album manifest in %s: %v", directory, err)
+	}
+	return manifest, nil
+}
+
+// ImportAlbum re-import an exported album folder. All pictures not in the
+// database are loaded, afterwards the album, the album entries and the tags
+// are written as given in the manifest.
+func ImportAlbum(directory string) error {
+	manifest, err := ReadAlbumManifest(directory)
+	if err != nil {
+		fmt.Println("Error reading album manifest:", err)
+		return err
+	}
+	err = PicLoad(&PicLoadParameter{NrThreadReader: 2, NrThreadStorer: 2, MaxBlobSize: MaxBlobSize,
+		Filter: ".*/" + AlbumManifestName, Directories: []string{directory}})
+	if err != nil {
+		return err
+	}
+	sql.WaitStored()
+
+	di, err := sql.DatabaseConnect()
+	if err != nil {
+		return err
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	album := &sql.Albums{Title: manifest.Title, Description: manifest.Description, Key: manifest.Key,
+		Directory: manifest.Directory, Type: manifest.Type, Option: manifest.Option,
+		ThumbnailHash: manifest.ThumbnailHash, Published: manifest.Published}
+	for _, mp := range manifest.Pictures {
+		found, err := di.CheckPicture(mp.Checksumpicture)
+		if err != nil {
+			return err
+		}
+		if !found {
+			fmt.Printf("Picture %s of %s not loaded, skip album entry %d\n", mp.Checksumpicture, mp.File, mp.Index)
+			continue
+		}
+		err = importPictureMetadata(id, mp)
+		if err != nil {
+			fmt.Printf("Error importing metadata of %s: %v\n", mp.Checksumpicture, err)
+			return err
+		}
+		album.Pictures = append(album.Pictures, &sql.AlbumPictures{Index: uint64(mp.Index),
+			Name: mp.Name, Description: mp.Description, ChecksumPicture: mp.Checksumpicture,
+			MimeType: mp.Mimetype, SkipTime: uint64(mp.Skiptime),
+			Height: uint64(mp.Height), Width: uint64(mp.Width)})
+	}
+	err = di.WriteAlbum(album)
+	if err != nil {
+		fmt.Println("Error writing album:", err)
+		return err
+	}
+	for _, ap := range album.Pictures {
+		err = di.WriteAlbumPictures(ap)
+		if err != nil {
+			fmt.Println("Error writing album pictures:", err)
+			return err
+		}
+	}
+	services.ServerMessage("Imported album %s with %d pictures", album.Title, len(album.Pictures))
+	return nil
+}
+
+// importPictureMetadata restore the title of a newly loaded picture, which
+// is loaded with the exported file name, and insert the missing tags
+func importPictureMetadata(id common.RegDbID, mp *AlbumManifestPicture) error {
+	_, _, err := id.Update("pictures", &common.Entries{
+		Fields: []string{"title"},
+		Update: []string{"checksumpicture='" + mp.Checksumpicture + "'", "title=" + quoteTag(mp.File)},
+		Values: [][]any{{mp.Title}},
+	})
+	if err != nil {
+		return err
+	}
+	if len(mp.Tags) == 0 {
+		return nil
+	}
+	tags := make(map[string]bool)
+	query := &common.Query{
+		TableName: "picturetags",
+		Fields:    []string{"tagname"},
+		Search:    "checksumpicture='" + mp.Checksumpicture + "'",
+	}
+	_, err = id.Query(query, func(search *common.Query, result *common.Result) error {
+		tags[result.Rows[0].(string)] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	list := make([][]any, 0)
+	for _, t := range mp.Tags {
+		if !tags[t] {
+			list = append(list, []any{t, mp.Checksumpicture})
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	_, err = id.Insert("picturetags", &common.Entries{
+		Fields: []string{"tagname", "checksumpicture"},
+		Values: list,
+	})
+	return err
+}
@@ -0,0 +1,99 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tknie/bitgartentools/store"
+)
+
+func TestSafeFileName(t *testing.T) {
+	assert.Equal(t, "Summer 2024", safeFileName(" Summer 2024 "))
+	assert.Equal(t, "a_b_c_", safeFileName("a/b:c?"))
+	assert.Equal(t, "_", safeFileName(""))
+}
+
+func TestAlbumManifest(t *testing.T) {
+	ep := &albumExportPicture{Index: 3, Name: "IMG_1234", Description: "At the beach",
+		Checksumpicture: "ABC", Sha256checksum: "DEF", Title: "IMG_1234.HEIC", Mimetype: "image/heic",
+		Width: 4032, Height: 3024, Exifmodel: "iPhone 12", Exiforigtime: "2024-07-01T10:11:12",
+		Tags: "beach,holiday"}
+	mp := ep.manifestPicture()
+	assert.Equal(t, "0003-IMG_1234.HEIC", mp.File)
+	assert.Equal(t, []string{"beach", "holiday"}, mp.Tags)
+	assert.Equal(t, "iPhone 12", mp.Exif.Model)
+	assert.Nil(t, (&albumExportPicture{Title: "a.jpg"}).manifestPicture().Exif)
+
+	directory := t.TempDir()
+	manifest := &AlbumManifest{Title: "Summer", Description: "Holiday", Pictures: []*AlbumManifestPicture{mp}}
+	data, err := json.Marshal(manifest)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(directory, AlbumManifestName), data, 0644))
+	read, err := ReadAlbumManifest(directory)
+	assert.NoError(t, err)
+	assert.Equal(t, manifest.Title, read.Title)
+	assert.Equal(t, mp, read.Pictures[0])
+}
+
+func TestVerifyMediaFile(t *testing.T) {
+	data := []byte("bitgarten media")
+	fileName := filepath.Join(t.TempDir(), "media.jpg")
+	assert.NoError(t, os.WriteFile(fileName, data, 0644))
+	md5sum := store.CreateMd5(data)
+	sha := store.CreateSHA(data)
+	assert.NoError(t, verifyMediaFile(fileName, md5sum, sha))
+	assert.NoError(t, verifyMediaFile(fileName, md5sum, ""))
+	assert.Error(t, verifyMediaFile(fileName, md5sum, store.CreateSHA([]byte("other"))))
+	assert.Error(t, verifyMediaFile(fileName, store.CreateMd5([]byte("other")), sha))
+	assert.Error(t, verifyMediaFile(fileName+".missing", md5sum, sha))
+}
+
+func TestExportAlbumMediaSize(t *testing.T) {
+	data := []byte("bitgarten media")
+	fileName := filepath.Join(t.TempDir(), "media.jpg")
+	assert.NoError(t, os.WriteFile(fileName, data, 0644))
+	ep := &albumExportPicture{Title: "media.jpg", Checksumpicture: store.CreateMd5(data),
+		Sha256checksum: store.CreateSHA(data)}
+	mp := ep.manifestPicture()
+	defer func(p *ExportMediaParameter) { exportParameter = p }(exportParameter)
+	exportParameter = &ExportMediaParameter{}
+	// the existing file is kept and its size recorded
+	assert.NoError(t, exportAlbumMedia(0, ep, mp, fileName, &PrivacyDecision{GPS: PrivacyKeep}))
+	assert.Equal(t, int64(len(data)), mp.Size)
+	assert.Equal(t, ep.Checksumpicture, mp.Checksumpicture)
+}
+
+func TestAlbumCopy(t *testing.T) {
+	defer func(p *ExportMediaParameter) { exportParameter = p }(exportParameter)
+	exportParameter = &ExportMediaParameter{}
+	jpeg := &albumExportPicture{Mimetype: "image/jpeg"}
+	heic := &albumExportPicture{Mimetype: "image/heic"}
+	keep := &PrivacyDecision{GPS: PrivacyKeep}
+	assert.False(t, jpeg.albumCopy(keep))
+	assert.True(t, heic.albumCopy(&PrivacyDecision{GPS: PrivacyStrip}))
+	exportParameter.Embed = true
+	assert.True(t, jpeg.albumCopy(keep))
+	assert.False(t, heic.albumCopy(keep))
+}
@@ -0,0 +1,459 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+	"github.com/tknie/services"
+)
+
+// ArchiveManifestName name of the optional manifest written as last entry
+// into the archive
+const ArchiveManifestName = "manifest.json"
+
+// ArchiveManifest all media files written into the archive
+type ArchiveManifest struct {
+	Exported time.Time              `json:"exported"`
+	Files    []*ArchiveManifestFile `json:"files"`
+}
+
+// ArchiveManifestFile archive entry of a picture
+type ArchiveManifestFile struct {
+	File            string    `json:"file"`
+	Title           string    `json:"title"`
+	Checksumpicture string    `json:"checksumpicture"`
+	Sha256checksum  string    `json:"sha256checksum,omitempty"`
+	Mimetype        string    `json:"mimetype,omitempty"`
+	OrigTime        time.Time `json:"origtime"`
+	Size            int64     `json:"size"`
+}
+
+// MediaArchive ZIP or TAR archive the media is streamed into. The entries
+// are written sequentially, a TAR entry of unknown size is buffered in memory
+// because the TAR header needs the size in front of the data.
+type MediaArchive struct {
+	out     io.Writer
+	closer  io.Closer
+	zip     *zip.Writer
+	tar     *tar.Writer
+	pending *tar.Header
+	buffer  bytes.Buffer
+	closed  bool
+}
+
+// archiveFormat archive format given or derived from the archive name,
+// stdout is written as TAR
+func archiveFormat(name, format string) (string, error) {
+	if format == "" {
+		switch {
+		case name == "-":
+			format = "tar"
+		default:
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+		}
+	}
+	switch format {
+	case "zip", "tar":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown archive format '%s' of %s, use zip or tar", format, name)
+}
+
+// OpenMediaArchive create the archive file, with name '-' the archive is
+// written to stdout and all messages are redirected to stderr
+func OpenMediaArchive(name, format string) (*MediaArchive, error) {
+	format, err := archiveFormat(name, format)
+	if err != nil {
+		return nil, err
+	}
+	archive := &MediaArchive{}
+	if name == "-" {
+		archive.out = os.Stdout
+		os.Stdout = os.Stderr
+	} else {
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		archive.out = f
+		archive.closer = f
+	}
+	archive.init(format)
+	return archive, nil
+}
+
+func (archive *MediaArchive) init(format string) {
+	switch format {
+	case "zip":
+		archive.zip = zip.NewWriter(archive.out)
+	default:
+		archive.tar = tar.NewWriter(archive.out)
+	}
+}
+
+// Create add a new entry to the archive and return the writer of the entry
+// data, a negative size means the size is not known in advance
+func (archive *MediaArchive) Create(name string, modTime time.Time, size int64) (io.Writer, error) {
+	err := archive.flush()
+	if err != nil {
+		return nil, err
+	}
+	name = filepath.ToSlash(name)
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if archive.zip != nil {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+		header.SetMode(0644)
+		return archive.zip.CreateHeader(header)
+	}
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644,
+		ModTime: modTime, Size: size, Format: tar.FormatPAX}
+	if size < 0 {
+		archive.pending = header
+		archive.buffer.Reset()
+		return &archive.buffer, nil
+	}
+	err = archive.tar.WriteHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	return archive.tar, nil
+}
+
+// flush write the buffered TAR entry
+func (archive *MediaArchive) flush() error {
+	if archive.pending == nil {
+		return nil
+	}
+	header := archive.pending
+	archive.pending = nil
+	header.Size = int64(archive.buffer.Len())
+	err := archive.tar.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = archive.buffer.WriteTo(archive.tar)
+	return err
+}
+
+// WriteFile add an entry with the given data
+func (archive *MediaArchive) WriteFile(name string, modTime time.Time, data []byte) error {
+	w, err := archive.Create(name, modTime, int64(len(data)))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Close finish the archive and close the archive file, closing a closed
+// archive does nothing
+func (archive *MediaArchive) Close() error {
+	if archive.closed {
+		return nil
+	}
+	archive.closed = true
+	var err error
+	if archive.zip != nil {
+		err = archive.zip.Close()
+	} else {
+		err = archive.flush()
+		if err == nil {
+			err = archive.tar.Close()
+		}
+	}
+	if archive.closer != nil {
+		if cerr := archive.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// errArchiveBroken a streamed archive entry could not be completed, the
+// archive can not be continued
+var errArchiveBroken = errors.New("archive entry incomplete")
+
+// webstore access of the archive, replaced in tests
+var (
+	webstoreMediaSize = sql.RestMediaSize
+	webstoreDownload  = sql.DownloadToWriter
+)
+
+// archiveMedia write the media into the archive. The checksums are verified
+// before the entry is written. Webstore media is streamed into a ZIP archive
+// or, if the size is known, into a TAR archive; a failed download or a wrong
+// checksum breaks the archive then. Webstore media of unknown size is
+// downloaded into a temporary file and verified before the TAR entry is
+// created.
+func archiveMedia(archive *MediaArchive, name string, modTime time.Time,
+	checksum, sha256sum, picopt string, media []byte) (int64, error) {
+	if picopt == "webstore" {
+		if archive.zip != nil {
+			// ZIP entries need no size in front of the data
+			return archiveStream(archive, name, modTime, checksum, sha256sum, -1)
+		}
+		size, err := webstoreMediaSize(checksum)
+		if err != nil {
+			atomic.AddUint64(&statCount.downloadErrors, 1)
+			return 0, err
+		}
+		if size >= 0 {
+			return archiveStream(archive, name, modTime, checksum, sha256sum, size)
+		}
+		return archiveTempFile(archive, name, modTime, checksum, sha256sum)
+	}
+	mh := newMediaHash()
+	mh.Write(media)
+	err := mh.verify(checksum, sha256sum)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(media)), archive.WriteFile(name, modTime, media)
+}
+
+// archiveStream stream the webstore media into the archive entry, a
+// negative size is only possible for ZIP entries. The checksums are verified
+// while streaming, a written entry with wrong content breaks the archive.
+func archiveStream(archive *MediaArchive, name string, modTime time.Time,
+	checksum, sha256sum string, size int64) (int64, error) {
+	w, err := archive.Create(name, modTime, size)
+	if err != nil {
+		return 0, err
+	}
+	mh := newMediaHash()
+	n, err := webstoreDownload(checksum, io.MultiWriter(w, mh))
+	if err == nil && size >= 0 && n != size {
+		err = fmt.Errorf("size %d differs from %d", n, size)
+	}
+	if err != nil {
+		atomic.AddUint64(&statCount.downloadErrors, 1)
+		return n, fmt.Errorf("%w: %s: %v", errArchiveBroken, name, err)
+	}
+	atomic.AddUint64(&statCount.downloaded, 1)
+	err = mh.verify(checksum, sha256sum)
+	if err != nil {
+		return n, fmt.Errorf("%w: %s written with wrong content: %v", errArchiveBroken, name, err)
+	}
+	return n, nil
+}
+
+// archiveTempFile download the webstore media of unknown size into a
+// temporary file, the verified file is copied into the TAR entry
+func archiveTempFile(archive *MediaArchive, name string, modTime time.Time,
+	checksum, sha256sum string) (int64, error) {
+	f, err := os.CreateTemp("", "bitgarten-archive-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		f.Close()
+		removeTempMedia(f.Name())
+	}()
+	mh := newMediaHash()
+	n, err := webstoreDownload(checksum, io.MultiWriter(f, mh))
+	if err != nil {
+		atomic.AddUint64(&statCount.downloadErrors, 1)
+		return 0, err
+	}
+	atomic.AddUint64(&statCount.downloaded, 1)
+	err = mh.verify(checksum, sha256sum)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	w, err := archive.Create(name, modTime, n)
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.CopyN(w, f, n)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", errArchiveBroken, name, err)
+	}
+	return n, nil
+}
+
+// exportMediaArchive stream all pictures of the query into the archive
+func exportMediaArchive(id common.RegDbID, q *common.Query, parameter *ExportMediaParameter) error {
+	archive, err := OpenMediaArchive(parameter.Archive, parameter.ArchiveFormat)
+	if err != nil {
+		fmt.Println("Error creating archive:", err)
+		return err
+	}
+	run := q.FctParameter.(*exportRun)
+	run.archive = archive
+	if parameter.Manifest {
+		run.manifest = &ArchiveManifest{Exported: time.Now()}
+	}
+	_, err = id.Query(q, writeArchiveMedia)
+	if err != nil {
+		archive.Close()
+		log.Log.Errorf("Error archive query: %v", err)
+		fmt.Println("Error exporting media query ...:", err)
+		return err
+	}
+	if run.manifest != nil {
+		data, err := json.MarshalIndent(run.manifest, "", "  ")
+		if err == nil {
+			err = archive.WriteFile(ArchiveManifestName, run.manifest.Exported, data)
+		}
+		if err != nil {
+			archive.Close()
+			return err
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		fmt.Println("Error closing archive:", err)
+		return err
+	}
+	services.ServerMessage("Exported %d media files into archive %s", statCount.wrote, parameter.Archive)
+	return nil
+}
+
+// writeArchiveMedia write the picture into the archive, the archive is
+// written sequentially in the query callback
+func writeArchiveMedia(search *common.Query, result *common.Result) error {
+	run := search.FctParameter.(*exportRun)
+	pic := &store.Pictures{}
+	*pic = *result.Data.(*store.Pictures)
+	atomic.AddUint64(&statCount.processed, 1)
+	name, err := run.path.exportFileName(run.id, "", pic)
+	if err != nil {
+		fmt.Printf("Error export path of %s: %v\n", pic.ChecksumPicture, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return nil
+	}
+	name = filepath.ToSlash(name)
+	x, decision, err := exportMetadata(run.id, pic)
+	if err != nil {
+		fmt.Printf("Error reading metadata of %s: %v\n", pic.ChecksumPicture, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return nil
+	}
+	err = exportCopy(run.id, pic, x, decision)
+	if err != nil {
+		fmt.Printf("Error filtering %s: %v\n", name, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return nil
+	}
+	// the manifest records the checksums of the filtered or embedded copy
+	entry := &ArchiveManifestFile{File: name, Title: pic.Title, Checksumpicture: pic.ChecksumPicture,
+		Sha256checksum: pic.ChecksumPictureSHA, Mimetype: pic.MIMEType, OrigTime: pic.ExifOrigTime}
+	entry.Size, err = archiveMedia(run.archive, name, pic.ExifOrigTime,
+		pic.ChecksumPicture, pic.ChecksumPictureSHA, pic.PicOpt, pic.Media)
+	if err != nil {
+		fmt.Printf("Error archiving %s: %v\n", name, err)
+		log.Log.Infof("Error archiving %s: %v", name, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		if errors.Is(err, errArchiveBroken) {
+			return err
+		}
+		return nil
+	}
+	atomic.AddUint64(&statCount.wrote, 1)
+	if exportParameter.Sidecar {
+		err = run.archive.WriteFile(name+SidecarExtension, pic.ExifOrigTime, x.Packet())
+		if err != nil {
+			fmt.Printf("Error archiving sidecar of %s: %v\n", name, err)
+			atomic.AddUint64(&statCount.errors, 1)
+		}
+	}
+	if run.manifest != nil {
+		run.manifest.Files = append(run.manifest.Files, entry)
+	}
+	return nil
+}
+
+// archiveAlbum write the album pictures and the album.json manifest into
+// the album folder of the archive
+func archiveAlbum(id common.RegDbID, archive *MediaArchive, manifest *AlbumManifest,
+	pictures []*albumExportPicture, directory string) error {
+	for _, ep := range pictures {
+		decision, err := ep.privacy(id)
+		if err != nil {
+			fmt.Printf("Error reading privacy of %s: %v\n", ep.Checksumpicture, err)
+			atomic.AddUint64(&statCount.errors, 1)
+			continue
+		}
+		mp := ep.manifestPicture()
+		atomic.AddUint64(&statCount.processed, 1)
+		checksum, sha256sum, picopt := ep.Checksumpicture, ep.Sha256checksum, ep.Picopt
+		var media []byte
+		switch {
+		case ep.albumCopy(decision):
+			media, err = albumCopyMedia(id, ep, decision)
+			checksum, sha256sum, picopt = store.CreateMd5(media), store.CreateSHA(media), "sqlstore"
+		case picopt != "webstore":
+			media, err = readMedia(id, checksum)
+		}
+		if err != nil {
+			fmt.Printf("Error exporting %s: %v\n", ep.Checksumpicture, err)
+			atomic.AddUint64(&statCount.errors, 1)
+			continue
+		}
+		origTime, _ := time.Parse(manifestTimeFormat, ep.Exiforigtime)
+		name := path.Join(directory, mp.File)
+		size, err := archiveMedia(archive, name, origTime, checksum, sha256sum, picopt, media)
+		if err != nil {
+			fmt.Printf("Error exporting %s: %v\n", ep.Checksumpicture, err)
+			atomic.AddUint64(&statCount.errors, 1)
+			if errors.Is(err, errArchiveBroken) {
+				return err
+			}
+			continue
+		}
+		atomic.AddUint64(&statCount.wrote, 1)
+		mp.Checksumpicture, mp.Sha256checksum, mp.Size = checksum, sha256sum, size
+		if exportParameter.Sidecar {
+			err = archive.WriteFile(name+SidecarExtension, origTime, albumXmpData(ep).Packet())
+			if err != nil {
+				return err
+			}
+		}
+		manifest.Pictures = append(manifest.Pictures, mp)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return archive.WriteFile(path.Join(directory, AlbumManifestName), manifest.Exported, data)
+}
@@ -0,0 +1,193 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tknie/bitgartentools/store"
+)
+
+func TestArchiveFormat(t *testing.T) {
+	f, err := archiveFormat("out.zip", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", f)
+	f, err = archiveFormat("out.TAR", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "tar", f)
+	f, err = archiveFormat("-", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "tar", f)
+	f, err = archiveFormat("-", "zip")
+	assert.NoError(t, err)
+	assert.Equal(t, "zip", f)
+	_, err = archiveFormat("out.rar", "")
+	assert.Error(t, err)
+}
+
+func TestMediaArchiveTar(t *testing.T) {
+	var out bytes.Buffer
+	archive := &MediaArchive{out: &out}
+	archive.init("tar")
+	modTime := time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)
+	media := []byte("picture data")
+	_, err := archiveMedia(archive, "2024/IMG.JPG", modTime, store.CreateMd5(media), store.CreateSHA(media), "", media)
+	assert.NoError(t, err)
+	w, err := archive.Create("video.mov", modTime, -1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write([]byte("streamed"))
+	assert.NoError(t, archive.Close())
+	assert.NoError(t, archive.Close())
+
+	tr := tar.NewReader(&out)
+	header, err := tr.Next()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "2024/IMG.JPG", header.Name)
+	assert.True(t, modTime.Equal(header.ModTime))
+	data, _ := io.ReadAll(tr)
+	assert.Equal(t, media, data)
+	header, err = tr.Next()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "video.mov", header.Name)
+	assert.Equal(t, int64(8), header.Size)
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMediaArchiveZip(t *testing.T) {
+	var out bytes.Buffer
+	archive := &MediaArchive{out: &out}
+	archive.init("zip")
+	modTime := time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)
+	media := []byte("picture data")
+	_, err := archiveMedia(archive, "IMG.JPG", modTime, store.CreateMd5(media), "", "", media)
+	assert.NoError(t, err)
+	_, err = archiveMedia(archive, "BAD.JPG", modTime, "0000", "", "", media)
+	assert.Error(t, err)
+	assert.NoError(t, archive.WriteFile(ArchiveManifestName, modTime, []byte("{}")))
+	assert.NoError(t, archive.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, zr.File, 2) {
+		assert.Equal(t, "IMG.JPG", zr.File[0].Name)
+		assert.True(t, modTime.Equal(zr.File[0].Modified))
+		assert.Equal(t, ArchiveManifestName, zr.File[1].Name)
+	}
+}
+
+func TestArchiveWebstoreMedia(t *testing.T) {
+	media := []byte("webstore picture data")
+	checksum := store.CreateMd5(media)
+	size := int64(len(media))
+	var downloadErr error
+	defer func(s func(string) (int64, error), d func(string, io.Writer) (int64, error)) {
+		webstoreMediaSize, webstoreDownload = s, d
+	}(webstoreMediaSize, webstoreDownload)
+	webstoreMediaSize = func(string) (int64, error) { return size, nil }
+	webstoreDownload = func(md5 string, w io.Writer) (int64, error) {
+		if downloadErr != nil {
+			n, _ := w.Write(media[:4])
+			return int64(n), downloadErr
+		}
+		n, err := w.Write(media)
+		return int64(n), err
+	}
+	modTime := time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)
+
+	// ZIP entries are streamed and verified while writing
+	var out bytes.Buffer
+	archive := &MediaArchive{out: &out}
+	archive.init("zip")
+	webstoreMediaSize = func(string) (int64, error) { return -1, fmt.Errorf("no size") }
+	n, err := archiveMedia(archive, "IMG.JPG", modTime, checksum, "", "webstore", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, size, n)
+	_, err = archiveMedia(archive, "BAD.JPG", modTime, "0000", "", "webstore", nil)
+	assert.True(t, errors.Is(err, errArchiveBroken))
+	downloadErr = io.ErrUnexpectedEOF
+	_, err = archiveMedia(archive, "FAIL.JPG", modTime, checksum, "", "webstore", nil)
+	assert.True(t, errors.Is(err, errArchiveBroken))
+	downloadErr = nil
+	assert.NoError(t, archive.Close())
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if assert.NoError(t, err) && assert.Len(t, zr.File, 3) {
+		assert.Equal(t, "IMG.JPG", zr.File[0].Name)
+		assert.Equal(t, uint64(size), zr.File[0].UncompressedSize64)
+	}
+	webstoreMediaSize = func(string) (int64, error) { return size, nil }
+
+	// TAR entries of known size are streamed
+	out.Reset()
+	archive = &MediaArchive{out: &out}
+	archive.init("tar")
+	n, err = archiveMedia(archive, "IMG.JPG", modTime, checksum, "", "webstore", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, size, n)
+	assert.Nil(t, archive.pending)
+	// unknown size is downloaded into a temporary file and verified
+	webstoreMediaSize = func(string) (int64, error) { return -1, nil }
+	_, err = archiveMedia(archive, "BAD.JPG", modTime, "0000", "", "webstore", nil)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, errArchiveBroken))
+	n, err = archiveMedia(archive, "TMP.JPG", modTime, checksum, "", "webstore", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, size, n)
+	assert.Nil(t, archive.pending)
+	assert.NoError(t, archive.Close())
+	tr := tar.NewReader(&out)
+	for _, name := range []string{"IMG.JPG", "TMP.JPG"} {
+		header, err := tr.Next()
+		if assert.NoError(t, err) {
+			assert.Equal(t, name, header.Name)
+			assert.Equal(t, size, header.Size)
+		}
+		data, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		assert.Equal(t, media, data)
+	}
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// a failed streamed download breaks the archive
+	out.Reset()
+	archive = &MediaArchive{out: &out}
+	archive.init("tar")
+	webstoreMediaSize = func(string) (int64, error) { return size, nil }
+	downloadErr = io.ErrUnexpectedEOF
+	_, err = archiveMedia(archive, "FAIL.JPG", modTime, checksum, "", "webstore", nil)
+	assert.True(t, errors.Is(err, errArchiveBroken))
+}
@@ -20,9 +20,14 @@
 package tools
 
 import (
+	"crypto/md5"
+	"crypto/sha256"
 	"fmt"
+	"hash"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -41,26 +46,64 @@ type ExportMediaParameter struct {
 	Directory  string
 	Limit      int
 	MarkDelete bool
+	Album      string
+	AllAlbums  bool
+	// PathTemplate text/template of the file path in the export directory
+	PathTemplate string
+	// Archive ZIP or TAR archive file instead of a directory, '-' is stdout
+	Archive       string
+	ArchiveFormat string
+	Manifest      bool
+	// Mirror keep a manifest in the directory and only write new pictures
+	Mirror       bool
+	Prune        bool
+	Quarantine   string
+	VerifySample int
+	// Sidecar write XMP sidecars, Embed embed XMP and IPTC into JPEG copies
+	Sidecar bool
+	Embed   bool
+	// Privacy profile filtering GPS and serial numbers of the exported copies
+	Privacy *PrivacyProfile
 }
 
 type stat struct {
-	wrote     uint64
-	processed uint64
-	found     uint64
-	errors    uint64
-	dberror   uint64
+	wrote          uint64
+	processed      uint64
+	found          uint64
+	errors         uint64
+	dberror        uint64
+	downloaded     uint64
+	downloadErrors uint64
 }
 
 var statCount = &stat{}
 var exportParameter *ExportMediaParameter
 
-var picChannel chan *store.Pictures
+// exportFile picture and the file name it is written to
+type exportFile struct {
+	pic      *store.Pictures
+	filename string
+	mirror   *exportMirror
+	sidecar  *XmpData
+	// rewrite filtered or embedded copies replace an existing file
+	rewrite bool
+}
+
+type exportRun struct {
+	id       common.RegDbID
+	path     *ExportPath
+	archive  *MediaArchive
+	manifest *ArchiveManifest
+	mirror   *exportMirror
+}
+
+var picChannel chan *exportFile
 var stop = make(chan bool)
 
 var wgWrite sync.WaitGroup
 
 func StartExport(workers int) {
-	picChannel = make(chan *store.Pictures, workers)
+	picChannel = make(chan *exportFile, workers)
 
 	for range workers {
 		go writerMediaFile()
@@ -72,12 +115,35 @@ func ExportMedia(parameter *ExportMediaParameter) error {
 		parameter.Directory = "./"
 	}
 	exportParameter = parameter
+	if parameter.Mirror && parameter.Archive != "" {
+		return fmt.Errorf("mirror export is not possible into an archive")
+	}
+	if parameter.Mirror && parameter.Embed {
+		return fmt.Errorf("mirror export cannot embed metadata, use XMP sidecars")
+	}
+	if parameter.Mirror && parameter.Privacy != nil {
+		return fmt.Errorf("mirror export cannot apply a privacy profile")
+	}
+	if parameter.Mirror && (parameter.Prune || parameter.Quarantine != "") && parameter.Limit != 0 {
+		return fmt.Errorf("pruning the mirror needs the complete export, use limit 0")
+	}
+	exportPath, err := NewExportPath(parameter.PathTemplate)
+	if err != nil {
+		fmt.Println("Error export path:", err)
+		return err
+	}
 	id, err := sql.DatabaseHandler()
 	if err != nil {
 		fmt.Println("Error connect ...:", err)
 		return err
 	}
 	defer id.FreeHandler()
+	wid, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer wid.FreeHandler()
 
 	limit := "ALL"
 	if parameter.Limit > 0 {
@@ -91,18 +157,39 @@ func ExportMedia(parameter *ExportMediaParameter) error {
 		DataStruct:   &store.Pictures{},
 		Search:       search,
 		Limit:        limit,
-		FctParameter: parameter,
-		Fields: []string{"MIMEType", "title", "exiforigtime",
-			"checksumpicture", "Media", "PicOpt"},
+		Order:        []string{"checksumpicture"},
+		FctParameter: &exportRun{id: wid, path: exportPath},
+		Fields: []string{"MIMEType", "title", "exiforigtime", "exifmodel", "exifmake",
+			"gpslatitude", "gpslongitude", "checksumpicture", "sha256checksum", "Media", "PicOpt"},
+	}
+	var mirror *exportMirror
+	if parameter.Mirror {
+		mirror, err = newExportMirror(parameter)
+		if err != nil {
+			fmt.Println("Error reading mirror manifest:", err)
+			return err
+		}
+		mirror.verifySample()
+		// media is read only for pictures not in the mirror
+		q.Fields = slices.DeleteFunc(q.Fields, func(f string) bool { return f == "Media" })
+		q.FctParameter.(*exportRun).mirror = mirror
 	}
 	outStat := func() {
 		fmt.Println("Export progess....")
 		fmt.Println("  Processed :", statCount.processed)
 		fmt.Println("  Found     :", statCount.found)
 		fmt.Println("  Wrote     :", statCount.wrote)
+		fmt.Println("  Downloaded:", statCount.downloaded)
+		fmt.Println("  Download errors:", statCount.downloadErrors)
+		fmt.Println("  Errors    :", statCount.errors)
 		fmt.Println("  DB errors :", statCount.dberror)
 	}
 	bitgartentools.Schedule(outStat, 60*time.Second)
+	if parameter.Archive != "" {
+		err = exportMediaArchive(id, q, parameter)
+		outStat()
+		return err
+	}
 	log.Log.Debugf("Call batch ...")
 	_, err = id.Query(q, writeMediaFile)
 	if err != nil {
@@ -114,40 +201,167 @@ func ExportMedia(parameter *ExportMediaParameter) error {
 	stop <- true
 	log.Log.Debugf("Call batch done ...")
 	outStat()
+	if mirror != nil {
+		return mirror.finish(parameter.Limit == 0)
+	}
 	return nil
 }
 
+// writeMediaFile resolve the file name of the picture and queue it to the
+// writers. The pictures are read in checksum order, so colliding paths are
+// resolved the same way in each export.
 func writeMediaFile(search *common.Query, result *common.Result) error {
+	run := search.FctParameter.(*exportRun)
 	pic := result.Data.(*store.Pictures)
 	p := &store.Pictures{}
 	*p = *pic
+	if run.mirror != nil {
+		// pictures with errors are not pruned
+		run.mirror.markSeen(p.ChecksumPicture)
+	}
+	filename, err := run.path.exportFileName(run.id, exportParameter.Directory, p)
+	if err != nil {
+		fmt.Printf("Error export path of %s: %v\n", p.ChecksumPicture, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return nil
+	}
+	x, decision, err := exportMetadata(run.id, p)
+	if err != nil {
+		fmt.Printf("Error reading metadata of %s: %v\n", p.ChecksumPicture, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return nil
+	}
+	if run.mirror != nil {
+		if !run.mirror.need(p.ChecksumPicture, filename) {
+			if exportParameter.Sidecar {
+				writeExportSidecar(filename, x)
+			}
+			return nil
+		}
+		if p.PicOpt != "webstore" {
+			p.Media, err = readMedia(run.id, p.ChecksumPicture)
+			if err != nil {
+				fmt.Printf("Error reading media of %s: %v\n", p.ChecksumPicture, err)
+				atomic.AddUint64(&statCount.errors, 1)
+				return nil
+			}
+		}
+	}
+	err = exportCopy(run.id, p, x, decision)
+	if err != nil {
+		fmt.Printf("Error filtering %s: %v\n", filename, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return nil
+	}
+	file := &exportFile{pic: p, filename: filename, mirror: run.mirror,
+		rewrite: decision.Active() || exportParameter.Embed}
+	if exportParameter.Sidecar {
+		file.sidecar = x
+	}
 	wgWrite.Add(1)
-	picChannel <- p
+	picChannel <- file
 	return nil
 }
 
 func writerMediaFile() {
 	for {
 		select {
-		case pic := <-picChannel:
-			writerMedia(pic)
+		case file := <-picChannel:
+			if writerMedia(file.pic, file.filename, file.rewrite) {
+				if file.mirror != nil {
+					file.mirror.add(file.pic, file.filename)
+				}
+				if file.sidecar != nil {
+					writeExportSidecar(file.filename, file.sidecar)
+				}
+			}
+			wgWrite.Done()
 		case <-stop:
 			return
 		}
 	}
 }
 
-func writerMedia(pic *store.Pictures) {
-	defer wgWrite.Done()
+// exportMetadata read the XMP metadata and the privacy decision of the
+// picture, the GPS position of the metadata is already filtered
+func exportMetadata(id common.RegDbID, p *store.Pictures) (*XmpData, *PrivacyDecision, error) {
+	var x *XmpData
+	var err error
+	if exportParameter.Sidecar || exportParameter.Embed {
+		x, err = readXmpData(id, p)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	decision, err := exportParameter.Privacy.readPrivacyDecision(id, p.ChecksumPicture)
+	if err != nil {
+		return nil, nil, err
+	}
+	decision.filterXmp(x)
+	return x, decision, nil
+}
+
+// exportCopy apply the privacy decision and embed the metadata into the
+// media of the picture copy, webstore JPEGs are downloaded into memory to
+// embed the metadata
+func exportCopy(id common.RegDbID, p *store.Pictures, x *XmpData, decision *PrivacyDecision) error {
+	if decision.Active() {
+		media, err := privacyMedia(id, p.ChecksumPicture, p.ChecksumPictureSHA, p.PicOpt, p.Media, decision)
+		if err != nil {
+			return err
+		}
+		setCopyMedia(p, media)
+	}
+	if !exportParameter.Embed {
+		return nil
+	}
+	if p.PicOpt == "webstore" {
+		if !isJPEGType(p.MIMEType) {
+			return nil
+		}
+		// webstore JPEGs are downloaded to embed the metadata
+		media, err := verifiedMedia(id, p.ChecksumPicture, p.ChecksumPictureSHA, p.PicOpt, nil)
+		if err != nil {
+			return err
+		}
+		p.Media = media
+		p.PicOpt = "sqlstore"
+	}
+	if isJPEG(p.Media) {
+		return embedCopy(p, x)
+	}
+	return nil
+}
+
+// setCopyMedia replace the media of the picture copy, the checksums are
+// updated to the written data
+func setCopyMedia(p *store.Pictures, media []byte) {
+	p.Media = media
+	p.PicOpt = "sqlstore"
+	p.ChecksumPicture = store.CreateMd5(media)
+	p.ChecksumPictureSHA = store.CreateSHA(media)
+}
+
+// writeExportSidecar write the XMP sidecar next to the media file
+func writeExportSidecar(filename string, x *XmpData) {
+	err := writeSidecar(filename, x)
+	if err != nil {
+		fmt.Printf("Error writing sidecar of %s: %v\n", filename, err)
+		atomic.AddUint64(&statCount.errors, 1)
+	}
+}
 
+// writerMedia write the media file or check the existing file, returns
+// true if the file is correct. With rewrite an existing file of other
+// content is replaced by the filtered or embedded copy.
+func writerMedia(pic *store.Pictures, filename string, rewrite bool) bool {
 	atomic.AddUint64(&statCount.processed, 1)
-	filename := fmt.Sprintf("%s/%s/%c/%s/%s-%s", exportParameter.Directory,
-		pic.ExifOrigTime.Format(exportTimeFormat), pic.Title[0], pic.Title,
-		pic.ChecksumPicture, pic.Title)
 	dirname := filepath.Dir(filename)
 	if pic.PicOpt == "webstore" {
-		fmt.Printf("Skip webstore %s\n", filename)
-		return
+		return writerWebstoreMedia(pic, filename, dirname)
+	}
+	if rewrite {
+		return rewriteMedia(pic, filename, dirname)
 	}
 	log.Log.Debugf("Create directory: %s", dirname)
 	if stat, err := os.Stat(filename); err == nil {
@@ -156,14 +370,14 @@ func writerMedia(pic *store.Pictures) {
 			fmt.Printf("Size test of filename fails %s -> %d != %d\n", filename, stat.Size(), len(pic.Media))
 			log.Log.Infof("Size test of filename fails %s -> %d != %d", filename, stat.Size(), len(pic.Media))
 			atomic.AddUint64(&statCount.errors, 1)
-			return
+			return false
 		}
 		data, err := os.ReadFile(filename)
 		if err != nil {
 			fmt.Printf("Read of filename fails %s: %v\n", filename, err)
 			log.Log.Infof("Read of filename fails %s: %v", filename, err)
 			atomic.AddUint64(&statCount.errors, 1)
-			return
+			return false
 		}
 		md5 := store.CreateMd5(data)
 		if md5 != pic.ChecksumPicture {
@@ -175,9 +389,10 @@ func writerMedia(pic *store.Pictures) {
 				fmt.Println("Compare of filename fails", filename, md5, "!=", pic.ChecksumPicture)
 				log.Log.Infof("Compare of filename fails %s %s != %s", filename, md5, pic.ChecksumPicture)
 				atomic.AddUint64(&statCount.errors, 1)
-				return
+				return false
 			}
 			atomic.AddUint64(&statCount.dberror, 1)
+			return false
 		} else {
 			atomic.AddUint64(&statCount.found, 1)
 		}
@@ -187,7 +402,13 @@ func writerMedia(pic *store.Pictures) {
 			fmt.Println("Compare of pic data fails", filename, md5, "!=", pic.ChecksumPicture)
 			log.Log.Infof("Compare of pic data fails %s %s != %s", filename, md5, pic.ChecksumPicture)
 			atomic.AddUint64(&statCount.errors, 1)
-			return
+			return false
+		}
+		if pic.ChecksumPictureSHA != "" && store.CreateSHA(pic.Media) != pic.ChecksumPictureSHA {
+			fmt.Println("Compare of pic data SHA-256 fails", filename, pic.ChecksumPictureSHA)
+			log.Log.Infof("Compare of pic data SHA-256 fails %s %s", filename, pic.ChecksumPictureSHA)
+			atomic.AddUint64(&statCount.errors, 1)
+			return false
 		}
 		if _, err := os.Stat(dirname); os.IsNotExist(err) {
 			os.MkdirAll(dirname, 0700)
@@ -201,5 +422,118 @@ func writerMedia(pic *store.Pictures) {
 			os.Exit(1)
 		}
 	}
+	return true
+}
 
+// rewriteMedia replace the file by the copy through a temporary file, an
+// existing file with correct checksums is kept
+func rewriteMedia(pic *store.Pictures, filename, dirname string) bool {
+	if verifyMediaFile(filename, pic.ChecksumPicture, pic.ChecksumPictureSHA) == nil {
+		atomic.AddUint64(&statCount.found, 1)
+		return true
+	}
+	if _, err := os.Stat(dirname); os.IsNotExist(err) {
+		os.MkdirAll(dirname, 0700)
+	}
+	tmpName := filename + ".tmp"
+	err := os.WriteFile(tmpName, pic.Media, 0644)
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		removeTempMedia(tmpName)
+		fmt.Printf("Error writing Media file %s: %v\n", filename, err)
+		log.Log.Infof("Error writing Media file %s: %v", filename, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return false
+	}
+	atomic.AddUint64(&statCount.wrote, 1)
+	log.Log.Debugf("Rewrite Media file %s", filename)
+	return true
+}
+
+// writerWebstoreMedia download the media stored in the webstore, an existing
+// file with correct checksums is kept
+func writerWebstoreMedia(pic *store.Pictures, filename, dirname string) bool {
+	if _, err := os.Stat(filename); err == nil {
+		err = verifyMediaFile(filename, pic.ChecksumPicture, pic.ChecksumPictureSHA)
+		if err == nil {
+			atomic.AddUint64(&statCount.found, 1)
+			return true
+		}
+		fmt.Printf("Existing file %s is downloaded again: %v\n", filename, err)
+	}
+	if _, err := os.Stat(dirname); os.IsNotExist(err) {
+		os.MkdirAll(dirname, 0700)
+	}
+	err := downloadMedia(pic.ChecksumPicture, pic.ChecksumPictureSHA, filename)
+	if err != nil {
+		fmt.Printf("Error downloading webstore media %s: %v\n", filename, err)
+		log.Log.Infof("Error downloading webstore media %s: %v", filename, err)
+		atomic.AddUint64(&statCount.errors, 1)
+		return false
+	}
+	atomic.AddUint64(&statCount.wrote, 1)
+	log.Log.Debugf("Write webstore media file %s", filename)
+	return true
+}
+
+// downloadMedia download the media out of the webstore streaming into the
+// file, the file is only kept if the MD5 and SHA-256 checksums are correct
+func downloadMedia(checksum, sha256sum, fileName string) error {
+	tmpName := fileName + ".download"
+	err := sql.DownloadToTitle(checksum, tmpName)
+	if err == nil {
+		err = verifyMediaFile(tmpName, checksum, sha256sum)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, fileName)
+	}
+	if err != nil {
+		removeTempMedia(tmpName)
+		atomic.AddUint64(&statCount.downloadErrors, 1)
+		return err
+	}
+	atomic.AddUint64(&statCount.downloaded, 1)
+	return nil
+}
+
+// verifyMediaFile stream the file through MD5 and SHA-256 and compare the
+// checksums, an empty SHA-256 checksum is not checked
+func verifyMediaFile(fileName, checksum, sha256sum string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mh := newMediaHash()
+	_, err = io.Copy(mh, f)
+	if err != nil {
+		return err
+	}
+	return mh.verify(checksum, sha256sum)
+}
+
+// mediaHash calculate MD5 and SHA-256 of the media written to it
+type mediaHash struct {
+	md5 hash.Hash
+	sha hash.Hash
+	io.Writer
+}
+
+func newMediaHash() *mediaHash {
+	mh := &mediaHash{md5: md5.New(), sha: sha256.New()}
+	mh.Writer = io.MultiWriter(mh.md5, mh.sha)
+	return mh
+}
+
+// verify compare the checksums, an empty SHA-256 checksum is not checked
+func (mh *mediaHash) verify(checksum, sha256sum string) error {
+	if md5sum := fmt.Sprintf("%X", mh.md5.Sum(nil)); md5sum != checksum {
+		return fmt.Errorf("MD5 checksum fails %s != %s", md5sum, checksum)
+	}
+	if sha := fmt.Sprintf("%X", mh.sha.Sum(nil)); sha256sum != "" && sha != sha256sum {
+		return fmt.Errorf("SHA-256 checksum fails %s != %s", sha, sha256sum)
+	}
+	return nil
 }
@@ -0,0 +1,47 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tknie/bitgartentools/store"
+)
+
+func TestWriterMediaRewrite(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "2024", "media.jpg")
+	original := []byte("original media with GPS")
+	pic := &store.Pictures{Media: original, ChecksumPicture: store.CreateMd5(original), PicOpt: "sqlstore"}
+	assert.True(t, writerMedia(pic, fileName, false))
+
+	filtered := &store.Pictures{PicOpt: "sqlstore"}
+	setCopyMedia(filtered, []byte("filtered media"))
+	// without rewrite an existing file of other content is an error
+	assert.False(t, writerMedia(filtered, fileName, false))
+	assert.True(t, writerMedia(filtered, fileName, true))
+	data, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, filtered.Media, data)
+	assert.NoFileExists(t, fileName+".tmp")
+	assert.True(t, writerMedia(filtered, fileName, true))
+}
@@ -0,0 +1,301 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tknie/bitgartentools/store"
+	"github.com/tknie/log"
+	"github.com/tknie/services"
+)
+
+// MirrorManifestName manifest of the mirror in the export directory
+const MirrorManifestName = ".bitgarten-mirror.json"
+
+// DefaultVerifySample number of mirror files verified in each run
+const DefaultVerifySample = 50
+
+// MirrorManifest all files of the mirror by checksum of the picture
+type MirrorManifest struct {
+	Updated time.Time              `json:"updated"`
+	Files   map[string]*MirrorFile `json:"files"`
+}
+
+// MirrorFile mirror file of a picture, the path is relative to the export
+// directory
+type MirrorFile struct {
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	Sha256checksum string    `json:"sha256checksum,omitempty"`
+	Written        time.Time `json:"written"`
+	Verified       time.Time `json:"verified"`
+}
+
+type exportMirror struct {
+	directory    string
+	parameter    *ExportMediaParameter
+	lock         sync.Mutex
+	manifest     *MirrorManifest
+	seen         map[string]bool
+	skipped      uint64
+	moved        uint64
+	verified     uint64
+	verifyErrors uint64
+	removed      uint64
+	pruned       uint64
+}
+
+// LoadMirrorManifest read the mirror manifest of the directory, a missing
+// manifest starts an empty mirror
+func LoadMirrorManifest(directory string) (*MirrorManifest, error) {
+	manifest := &MirrorManifest{Files: make(map[string]*MirrorFile)}
+	data, err := os.ReadFile(filepath.Join(directory, MirrorManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mirror manifest in %s: %v", directory, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]*MirrorFile)
+	}
+	return manifest, nil
+}
+
+// write the manifest into a temporary file renamed afterwards, so an
+// interrupted run keeps the last manifest
+func (manifest *MirrorManifest) write(directory string) error {
+	manifest.Updated = time.Now()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fileName := filepath.Join(directory, MirrorManifestName)
+	err = os.WriteFile(fileName+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(fileName+".tmp", fileName)
+}
+
+// sample checksums of the n files verified longest ago, the sample rotates
+// through the mirror with each run
+func (manifest *MirrorManifest) sample(n int) []string {
+	checksums := make([]string, 0, len(manifest.Files))
+	for checksum := range manifest.Files {
+		checksums = append(checksums, checksum)
+	}
+	sort.Slice(checksums, func(i, j int) bool {
+		vi := manifest.Files[checksums[i]].Verified
+		vj := manifest.Files[checksums[j]].Verified
+		if !vi.Equal(vj) {
+			return vi.Before(vj)
+		}
+		return checksums[i] < checksums[j]
+	})
+	if n < len(checksums) {
+		checksums = checksums[:n]
+	}
+	return checksums
+}
+
+func newExportMirror(parameter *ExportMediaParameter) (*exportMirror, error) {
+	manifest, err := LoadMirrorManifest(parameter.Directory)
+	if err != nil {
+		return nil, err
+	}
+	return &exportMirror{directory: parameter.Directory, parameter: parameter,
+		manifest: manifest, seen: make(map[string]bool)}, nil
+}
+
+func (mirror *exportMirror) fileName(p string) string {
+	return filepath.Join(mirror.directory, filepath.FromSlash(p))
+}
+
+func (mirror *exportMirror) relative(fileName string) string {
+	rel, err := filepath.Rel(mirror.directory, fileName)
+	if err != nil {
+		return filepath.ToSlash(fileName)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// verifySample verify the checksums of the sample, a corrupt or missing file
+// is removed out of the manifest and written again in this run
+func (mirror *exportMirror) verifySample() {
+	for _, checksum := range mirror.manifest.sample(mirror.parameter.VerifySample) {
+		entry := mirror.manifest.Files[checksum]
+		fileName := mirror.fileName(entry.Path)
+		err := verifyMediaFile(fileName, checksum, entry.Sha256checksum)
+		if err == nil {
+			entry.Verified = time.Now()
+			mirror.verified++
+			continue
+		}
+		fmt.Printf("Verify of mirror file %s fails: %v\n", entry.Path, err)
+		log.Log.Infof("Verify of mirror file %s fails: %v", entry.Path, err)
+		mirror.verifyErrors++
+		if _, serr := os.Stat(fileName); serr == nil {
+			err = mirror.removeFile(entry.Path)
+			if err != nil {
+				fmt.Printf("Error removing corrupt mirror file %s: %v\n", entry.Path, err)
+				continue
+			}
+		}
+		delete(mirror.manifest.Files, checksum)
+	}
+}
+
+// markSeen mark the picture as still existing, the mirror file is not pruned
+func (mirror *exportMirror) markSeen(checksum string) {
+	mirror.lock.Lock()
+	defer mirror.lock.Unlock()
+	mirror.seen[checksum] = true
+}
+
+// need check if the picture needs to be written. A file moved by a changed
+// path template is renamed instead of written again.
+func (mirror *exportMirror) need(checksum, fileName string) bool {
+	mirror.lock.Lock()
+	defer mirror.lock.Unlock()
+	mirror.seen[checksum] = true
+	entry, ok := mirror.manifest.Files[checksum]
+	if !ok {
+		return true
+	}
+	rel := mirror.relative(fileName)
+	if entry.Path != rel {
+		if _, err := os.Stat(fileName); err == nil {
+			return true
+		}
+		err := os.MkdirAll(filepath.Dir(fileName), 0700)
+		if err == nil {
+			err = os.Rename(mirror.fileName(entry.Path), fileName)
+		}
+		if err != nil {
+			log.Log.Debugf("Rename of mirror file %s failed: %v", entry.Path, err)
+			return true
+		}
+		os.Rename(mirror.fileName(entry.Path)+SidecarExtension, fileName+SidecarExtension)
+		entry.Path = rel
+		mirror.moved++
+	}
+	stat, err := os.Stat(fileName)
+	if err != nil || stat.Size() != entry.Size {
+		return true
+	}
+	mirror.skipped++
+	return false
+}
+
+// add record the written or checked file in the manifest
+func (mirror *exportMirror) add(pic *store.Pictures, fileName string) {
+	stat, err := os.Stat(fileName)
+	if err != nil {
+		fmt.Printf("Error adding mirror file %s: %v\n", fileName, err)
+		return
+	}
+	mirror.lock.Lock()
+	defer mirror.lock.Unlock()
+	now := time.Now()
+	mirror.manifest.Files[pic.ChecksumPicture] = &MirrorFile{Path: mirror.relative(fileName),
+		Size: stat.Size(), Sha256checksum: pic.ChecksumPictureSHA, Written: now, Verified: now}
+}
+
+// removedFiles checksums of the manifest not exported in this run, these
+// pictures are deleted or marked deleted in the database
+func (mirror *exportMirror) removedFiles() []string {
+	removed := make([]string, 0)
+	for checksum := range mirror.manifest.Files {
+		if !mirror.seen[checksum] {
+			removed = append(removed, checksum)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// removeFile move the file and its XMP sidecar into the quarantine
+// directory or delete them
+func (mirror *exportMirror) removeFile(p string) error {
+	if _, err := os.Stat(mirror.fileName(p + SidecarExtension)); err == nil {
+		err = mirror.moveFile(p + SidecarExtension)
+		if err != nil {
+			return err
+		}
+	}
+	return mirror.moveFile(p)
+}
+
+func (mirror *exportMirror) moveFile(p string) error {
+	if mirror.parameter.Quarantine == "" {
+		return os.Remove(mirror.fileName(p))
+	}
+	target := filepath.Join(mirror.parameter.Quarantine, filepath.FromSlash(p))
+	err := os.MkdirAll(filepath.Dir(target), 0700)
+	if err != nil {
+		return err
+	}
+	return os.Rename(mirror.fileName(p), target)
+}
+
+// finish prune or quarantine the files of removed pictures and write the
+// manifest. Removed pictures are only detected if all pictures are read.
+func (mirror *exportMirror) finish(complete bool) error {
+	if complete {
+		for _, checksum := range mirror.removedFiles() {
+			entry := mirror.manifest.Files[checksum]
+			mirror.removed++
+			if !mirror.parameter.Prune && mirror.parameter.Quarantine == "" {
+				fmt.Printf("Mirror file %s of removed picture %s kept\n", entry.Path, checksum)
+				continue
+			}
+			err := mirror.removeFile(entry.Path)
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Error pruning mirror file %s: %v\n", entry.Path, err)
+				continue
+			}
+			delete(mirror.manifest.Files, checksum)
+			mirror.pruned++
+		}
+	} else {
+		fmt.Println("Export is limited, removed pictures are not checked")
+	}
+	err := mirror.manifest.write(mirror.directory)
+	if err != nil {
+		fmt.Println("Error writing mirror manifest:", err)
+		return err
+	}
+	services.ServerMessage("Mirror %s: %d files, %d unchanged, %d moved, %d verified, %d verify errors, %d removed, %d pruned",
+		mirror.directory, len(mirror.manifest.Files), mirror.skipped, mirror.moved,
+		mirror.verified, mirror.verifyErrors, mirror.removed, mirror.pruned)
+	return nil
+}
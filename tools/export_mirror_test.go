@@ -0,0 +1,120 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tknie/bitgartentools/store"
+)
+
+func TestMirrorManifestSample(t *testing.T) {
+	now := time.Now()
+	manifest := &MirrorManifest{Files: map[string]*MirrorFile{
+		"A": {Verified: now},
+		"B": {Verified: now.Add(-2 * time.Hour)},
+		"C": {},
+		"D": {Verified: now.Add(-time.Hour)},
+	}}
+	assert.Equal(t, []string{"C", "B"}, manifest.sample(2))
+	assert.Equal(t, []string{"C", "B", "D", "A"}, manifest.sample(10))
+	assert.Empty(t, manifest.sample(0))
+}
+
+func TestMirrorManifestWrite(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := LoadMirrorManifest(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, manifest.Files)
+	manifest.Files["A"] = &MirrorFile{Path: "2024/a.jpg", Size: 4}
+	assert.NoError(t, manifest.write(dir))
+	manifest, err = LoadMirrorManifest(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "2024/a.jpg", manifest.Files["A"].Path)
+	assert.Equal(t, int64(4), manifest.Files["A"].Size)
+}
+
+func TestExportMirror(t *testing.T) {
+	dir := t.TempDir()
+	quarantine := filepath.Join(t.TempDir(), "removed")
+	mirror, err := newExportMirror(&ExportMediaParameter{Directory: dir, Quarantine: quarantine, VerifySample: 10})
+	if !assert.NoError(t, err) {
+		return
+	}
+	media := []byte("data")
+	pic := &store.Pictures{ChecksumPicture: store.CreateMd5(media), ChecksumPictureSHA: store.CreateSHA(media)}
+	fileName := filepath.Join(dir, "2024", "a.jpg")
+	assert.True(t, mirror.need(pic.ChecksumPicture, fileName))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0700))
+	assert.NoError(t, os.WriteFile(fileName, media, 0644))
+	mirror.add(pic, fileName)
+	assert.Equal(t, "2024/a.jpg", mirror.manifest.Files[pic.ChecksumPicture].Path)
+	assert.False(t, mirror.need(pic.ChecksumPicture, fileName))
+
+	// changed path template renames the file
+	moved := filepath.Join(dir, "b", "a.jpg")
+	assert.False(t, mirror.need(pic.ChecksumPicture, moved))
+	assert.FileExists(t, moved)
+	assert.Equal(t, "b/a.jpg", mirror.manifest.Files[pic.ChecksumPicture].Path)
+
+	mirror.verifySample()
+	assert.Equal(t, uint64(1), mirror.verified)
+
+	mirror.manifest.Files["GONE"] = &MirrorFile{Path: "gone.jpg", Size: 4}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "gone.jpg"), media, 0644))
+	// a picture failing in the export is seen and not pruned
+	mirror.manifest.Files["FAILED"] = &MirrorFile{Path: "failed.jpg", Size: 4}
+	mirror.markSeen("FAILED")
+	assert.Equal(t, []string{"GONE"}, mirror.removedFiles())
+	assert.NoError(t, mirror.finish(true))
+	assert.FileExists(t, filepath.Join(quarantine, "gone.jpg"))
+	assert.NotContains(t, mirror.manifest.Files, "GONE")
+	assert.Contains(t, mirror.manifest.Files, "FAILED")
+	assert.FileExists(t, filepath.Join(dir, MirrorManifestName))
+}
+
+func TestExportMirrorCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	mirror, err := newExportMirror(&ExportMediaParameter{Directory: dir, VerifySample: 10})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("bad"), 0644))
+	mirror.manifest.Files["A"] = &MirrorFile{Path: "a.jpg", Size: 3}
+	mirror.verifySample()
+	assert.Equal(t, uint64(1), mirror.verifyErrors)
+	assert.NoFileExists(t, filepath.Join(dir, "a.jpg"))
+	assert.True(t, mirror.need("A", filepath.Join(dir, "a.jpg")))
+}
+
+func TestExportMirrorPruneLimit(t *testing.T) {
+	err := ExportMedia(&ExportMediaParameter{Directory: t.TempDir(), Mirror: true, Prune: true, Limit: 10})
+	assert.Error(t, err)
+	err = ExportMedia(&ExportMediaParameter{Directory: t.TempDir(), Mirror: true, Quarantine: "q", Limit: 10})
+	assert.Error(t, err)
+}
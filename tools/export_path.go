@@ -0,0 +1,193 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+)
+
+// DefaultExportPath path template of the export, the layout of the
+// former fixed export
+const DefaultExportPath = "{{.Date}}/{{.Initial}}/{{.Title}}/{{.Checksum}}-{{.Title}}"
+
+const readExportInfo = `
+SELECT COALESCE(( SELECT min(a.title::text) FROM albumpictures ap, albums a
+          WHERE ap.albumid = a.id AND ap.checksumpicture::text = p.checksumpicture::text), '') AS album,
+  COALESCE(( SELECT string_agg(pt.tagname::text, ','::text ORDER BY pt.tagname::text) FROM picturetags pt
+          WHERE pt.checksumpicture::text = p.checksumpicture::text), '') AS tags
+  FROM pictures p WHERE p.checksumpicture = $1
+`
+
+// ExportPathData fields usable in the export path template, all fields
+// are sanitised and never contain a path separator
+type ExportPathData struct {
+	Year     string
+	Month    string
+	Day      string
+	Date     string
+	Camera   string
+	Make     string
+	Album    string
+	Tags     string
+	Tag      string
+	Checksum string
+	Title    string
+	Name     string
+	Ext      string
+	Initial  string
+}
+
+type exportInfo struct {
+	Album string
+	Tags  string
+}
+
+// ExportPath path template and all paths used by the export. Colliding
+// paths of different pictures get the checksum appended.
+type ExportPath struct {
+	template *template.Template
+	needInfo bool
+	lock     sync.Mutex
+	used     map[string]string
+}
+
+// NewExportPath parse the path template
+func NewExportPath(pattern string) (*ExportPath, error) {
+	if pattern == "" {
+		pattern = DefaultExportPath
+	}
+	t, err := template.New("path").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing export path %s: %v", pattern, err)
+	}
+	ep := &ExportPath{template: t, used: make(map[string]string),
+		needInfo: strings.Contains(pattern, ".Album") || strings.Contains(pattern, ".Tag")}
+	_, err = ep.Path(newExportPathData(&store.Pictures{Title: "IMG_0001.JPG", ChecksumPicture: "0"}, "album", "tag"))
+	if err != nil {
+		return nil, fmt.Errorf("error in export path %s: %v", pattern, err)
+	}
+	return ep, nil
+}
+
+// newExportPathData sanitised template fields of the picture, album and
+// tags are only given if the template needs them
+func newExportPathData(pic *store.Pictures, album, tags string) *ExportPathData {
+	title := safeFileName(pic.Title)
+	ext := path.Ext(title)
+	data := &ExportPathData{
+		Year:     pic.ExifOrigTime.Format("2006"),
+		Month:    pic.ExifOrigTime.Format("01"),
+		Day:      pic.ExifOrigTime.Format("02"),
+		Date:     pic.ExifOrigTime.Format(exportTimeFormat),
+		Camera:   safeFileName(pic.ExifModel),
+		Make:     safeFileName(pic.ExifMake),
+		Album:    safeFileName(album),
+		Tags:     safeFileName(strings.ReplaceAll(tags, ",", "-")),
+		Tag:      safeFileName(strings.Split(tags, ",")[0]),
+		Checksum: pic.ChecksumPicture,
+		Title:    title,
+		Name:     strings.TrimSuffix(title, ext),
+		Ext:      strings.ToLower(strings.TrimPrefix(ext, ".")),
+		Initial:  string([]rune(title)[0]),
+	}
+	if data.Name == "" {
+		data.Name = "_"
+	}
+	return data
+}
+
+// Path render the template and sanitise each path element
+func (ep *ExportPath) Path(data *ExportPathData) (string, error) {
+	var buffer bytes.Buffer
+	err := ep.template.Execute(&buffer, data)
+	if err != nil {
+		return "", err
+	}
+	elements := strings.Split(filepath.ToSlash(buffer.String()), "/")
+	result := make([]string, 0, len(elements))
+	for _, e := range elements {
+		if e == "" {
+			continue
+		}
+		result = append(result, safeFileName(e))
+	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("export path is empty")
+	}
+	return strings.Join(result, "/"), nil
+}
+
+// reserve register the path for the picture, if the path is used by another
+// picture the checksum is appended to the file name. Paths are compared case
+// insensitive because of case insensitive file systems like macOS or Windows.
+func (ep *ExportPath) reserve(p, checksum string) string {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	candidates := []string{p, checksumPath(p, checksum[:min(8, len(checksum))]), checksumPath(p, checksum)}
+	for _, c := range candidates {
+		key := strings.ToLower(c)
+		if used, ok := ep.used[key]; !ok || used == checksum {
+			ep.used[key] = checksum
+			return c
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// checksumPath append the checksum to the file name before the extension
+func checksumPath(p, checksum string) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + "-" + checksum + ext
+}
+
+// exportFileName file name of the picture in the export directory
+func (ep *ExportPath) exportFileName(id common.RegDbID, directory string, pic *store.Pictures) (string, error) {
+	info := &exportInfo{}
+	if ep.needInfo {
+		query := &common.Query{
+			TableName:  "pictures",
+			DataStruct: &exportInfo{},
+			Search:     readExportInfo,
+			Parameters: []any{pic.ChecksumPicture},
+		}
+		err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+			*info = *result.Data.(*exportInfo)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	p, err := ep.Path(newExportPathData(pic, info.Album, info.Tags))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(directory, filepath.FromSlash(ep.reserve(p, pic.ChecksumPicture))), nil
+}
@@ -0,0 +1,88 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tknie/bitgartentools/store"
+)
+
+func TestExportPathDefault(t *testing.T) {
+	ep, err := NewExportPath("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	pic := &store.Pictures{Title: "IMG_1234.HEIC", ChecksumPicture: "ABCDEF0123456789",
+		ExifOrigTime: time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)}
+	p, err := ep.Path(newExportPathData(pic, "", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, pic.ExifOrigTime.Format(exportTimeFormat)+"/I/IMG_1234.HEIC/ABCDEF0123456789-IMG_1234.HEIC", p)
+}
+
+func TestExportPathFields(t *testing.T) {
+	ep, err := NewExportPath("{{.Year}}/{{.Month}}/{{.Camera}}/{{.Album}}/{{.Tag}}/{{.Name}}.{{.Ext}}")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, ep.needInfo)
+	pic := &store.Pictures{Title: "a/b.JPG", ChecksumPicture: "ABC", ExifModel: "iPhone 12/Pro",
+		ExifOrigTime: time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)}
+	p, err := ep.Path(newExportPathData(pic, "Summer: 2024", "beach,family"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2024/07/iPhone 12_Pro/Summer_ 2024/beach/a_b.jpg", p)
+}
+
+func TestExportPathEmptyTitle(t *testing.T) {
+	ep, err := NewExportPath("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, ep.needInfo)
+	pic := &store.Pictures{ChecksumPicture: "ABC"}
+	p, err := ep.Path(newExportPathData(pic, "", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, pic.ExifOrigTime.Format(exportTimeFormat)+"/_/_/ABC-_", p)
+}
+
+func TestExportPathCollision(t *testing.T) {
+	ep, err := NewExportPath("{{.Title}}")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "IMG.JPG", ep.reserve("IMG.JPG", "ABCDEF0123"))
+	assert.Equal(t, "IMG.JPG", ep.reserve("IMG.JPG", "ABCDEF0123"))
+	assert.Equal(t, "IMG-12345678.JPG", ep.reserve("IMG.JPG", "1234567890"))
+	assert.Equal(t, "IMG-1234567899.JPG", ep.reserve("IMG.JPG", "1234567899"))
+	// case insensitive file systems see the same file
+	assert.Equal(t, "img-FEDCBA98.jpg", ep.reserve("img.jpg", "FEDCBA9876"))
+	assert.Equal(t, "img.JPG", ep.reserve("img.JPG", "ABCDEF0123"))
+}
+
+func TestExportPathInvalid(t *testing.T) {
+	_, err := NewExportPath("{{.Title")
+	assert.Error(t, err)
+	_, err = NewExportPath("{{.Unknown}}")
+	assert.Error(t, err)
+	_, err = NewExportPath("{{/* */}}")
+	assert.Error(t, err)
+}
@@ -0,0 +1,388 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tknie/bitgartentools/store"
+	"github.com/tknie/flynn/common"
+)
+
+// RatingTagPrefix tags like 'rating:4' are written as XMP rating and not
+// as keyword
+const RatingTagPrefix = "rating:"
+
+// SidecarExtension extension appended to the media file name of the sidecar
+const SidecarExtension = ".xmp"
+
+const readXmpInfo = `
+SELECT COALESCE(( SELECT string_agg(pt.tagname::text, ','::text ORDER BY pt.tagname::text) FROM picturetags pt
+          WHERE pt.checksumpicture::text = p.checksumpicture::text), '') AS tags,
+  COALESCE(( SELECT ap.description FROM albumpictures ap, albums a
+          WHERE ap.albumid = a.id AND ap.checksumpicture::text = p.checksumpicture::text
+          AND COALESCE(ap.description, '') <> '' ORDER BY a.title LIMIT 1), '') AS caption,
+  COALESCE(( SELECT ap.name FROM albumpictures ap, albums a
+          WHERE ap.albumid = a.id AND ap.checksumpicture::text = p.checksumpicture::text
+          AND COALESCE(ap.name, '') <> '' ORDER BY a.title LIMIT 1), '') AS name
+  FROM pictures p WHERE p.checksumpicture = $1
+`
+
+const (
+	xmpHeader  = "http://ns.adobe.com/xap/1.0/\x00"
+	iptcHeader = "Photoshop 3.0\x00"
+	jpegSOS    = 0xda
+	jpegAPP0   = 0xe0
+	jpegAPP1   = 0xe1
+	jpegAPP13  = 0xed
+	// iptcResourceID Photoshop image resource of the IPTC-NAA record
+	iptcResourceID = 0x0404
+)
+
+// XmpData metadata of the database written into XMP sidecars or embedded
+// into JPEG copies
+type XmpData struct {
+	Title       string
+	Caption     string
+	Keywords    []string
+	Rating      int
+	Latitude    float64
+	Longitude   float64
+	CaptureTime time.Time
+}
+
+type xmpInfo struct {
+	Tags    string
+	Caption string
+	Name    string
+}
+
+// newXmpData metadata of the picture, GPS 0,0 is taken as unknown position
+func newXmpData(title, caption, tags string, latitude, longitude float64, captureTime time.Time) *XmpData {
+	x := &XmpData{Title: title, Caption: caption, CaptureTime: captureTime}
+	if latitude != 0 || longitude != 0 {
+		x.Latitude = latitude
+		x.Longitude = longitude
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if r, ok := strings.CutPrefix(tag, RatingTagPrefix); ok {
+			if rating, err := strconv.Atoi(r); err == nil && rating >= 0 && rating <= 5 {
+				x.Rating = rating
+				continue
+			}
+		}
+		x.Keywords = append(x.Keywords, tag)
+	}
+	return x
+}
+
+// readXmpData read tags, album caption and album name of the picture
+func readXmpData(id common.RegDbID, pic *store.Pictures) (*XmpData, error) {
+	info := &xmpInfo{}
+	query := &common.Query{
+		TableName:  "pictures",
+		DataStruct: &xmpInfo{},
+		Search:     readXmpInfo,
+		Parameters: []any{pic.ChecksumPicture},
+	}
+	err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		*info = *result.Data.(*xmpInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newXmpData(info.Name, info.Caption, info.Tags, pic.GPSlatitude, pic.GPSlongitude, pic.ExifOrigTime), nil
+}
+
+// albumXmpData metadata of the album picture with the album description
+// as caption
+func albumXmpData(ep *albumExportPicture) *XmpData {
+	captureTime, _ := time.Parse(manifestTimeFormat, ep.Exiforigtime)
+	return newXmpData(ep.Name, ep.Description, ep.Tags, ep.Gpslatitude, ep.Gpslongitude, captureTime)
+}
+
+func xmlEscape(s string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
+
+// xmpCoordinate GPS coordinate in XMP format 'DDD,MM.mmmmmmK'
+func xmpCoordinate(value float64, positive, negative string) string {
+	ref := positive
+	if value < 0 {
+		ref = negative
+		value = -value
+	}
+	degree := math.Floor(value)
+	return fmt.Sprintf("%d,%.6f%s", int(degree), (value-degree)*60, ref)
+}
+
+// Packet XMP packet readable by digiKam, darktable and Lightroom
+func (x *XmpData) Packet() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buffer.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buffer.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	buffer.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	buffer.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	buffer.WriteString("    xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	buffer.WriteString("    xmlns:exif=\"http://ns.adobe.com/exif/1.0/\"\n")
+	buffer.WriteString("    xmlns:photoshop=\"http://ns.adobe.com/photoshop/1.0/\"\n")
+	buffer.WriteString("    xmlns:digiKam=\"http://www.digikam.org/ns/1.0/\"")
+	if x.Rating > 0 {
+		fmt.Fprintf(&buffer, "\n    xmp:Rating=\"%d\"", x.Rating)
+	}
+	if !x.CaptureTime.IsZero() {
+		t := x.CaptureTime.Format(manifestTimeFormat)
+		fmt.Fprintf(&buffer, "\n    exif:DateTimeOriginal=\"%s\"\n    photoshop:DateCreated=\"%s\"", t, t)
+	}
+	if x.Latitude != 0 || x.Longitude != 0 {
+		fmt.Fprintf(&buffer, "\n    exif:GPSVersionID=\"2.2.0.0\"\n    exif:GPSLatitude=\"%s\"\n    exif:GPSLongitude=\"%s\"",
+			xmpCoordinate(x.Latitude, "N", "S"), xmpCoordinate(x.Longitude, "E", "W"))
+	}
+	buffer.WriteString(">\n")
+	if x.Title != "" {
+		fmt.Fprintf(&buffer, "   <dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlEscape(x.Title))
+	}
+	if x.Caption != "" {
+		fmt.Fprintf(&buffer, "   <dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", xmlEscape(x.Caption))
+	}
+	if len(x.Keywords) > 0 {
+		buffer.WriteString("   <dc:subject><rdf:Bag>\n")
+		for _, k := range x.Keywords {
+			fmt.Fprintf(&buffer, "    <rdf:li>%s</rdf:li>\n", xmlEscape(k))
+		}
+		buffer.WriteString("   </rdf:Bag></dc:subject>\n")
+		buffer.WriteString("   <digiKam:TagsList><rdf:Seq>\n")
+		for _, k := range x.Keywords {
+			fmt.Fprintf(&buffer, "    <rdf:li>%s</rdf:li>\n", xmlEscape(k))
+		}
+		buffer.WriteString("   </rdf:Seq></digiKam:TagsList>\n")
+	}
+	buffer.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>\n")
+	return buffer.Bytes()
+}
+
+// writeSidecar write the XMP sidecar of the media file, an unchanged
+// sidecar is not written again
+func writeSidecar(fileName string, x *XmpData) error {
+	sidecar := fileName + SidecarExtension
+	data := x.Packet()
+	if old, err := os.ReadFile(sidecar); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return os.WriteFile(sidecar, data, 0644)
+}
+
+// iptcDataset IPTC-IIM dataset, the data is truncated to the maximum length
+func iptcDataset(buffer *bytes.Buffer, record, dataset byte, data string, maxLength int) {
+	if len(data) > maxLength {
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		data = data[:cut]
+	}
+	buffer.Write([]byte{0x1c, record, dataset})
+	binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	buffer.WriteString(data)
+}
+
+// iptc IPTC-IIM data in UTF-8 of keywords, caption, title and capture time
+func (x *XmpData) iptc() []byte {
+	var buffer bytes.Buffer
+	iptcDataset(&buffer, 1, 90, "\x1b%G", 3)
+	iptcDataset(&buffer, 2, 0, "\x00\x04", 2)
+	if x.Title != "" {
+		iptcDataset(&buffer, 2, 5, x.Title, 64)
+	}
+	for _, k := range x.Keywords {
+		iptcDataset(&buffer, 2, 25, k, 64)
+	}
+	if !x.CaptureTime.IsZero() {
+		iptcDataset(&buffer, 2, 55, x.CaptureTime.Format("20060102"), 8)
+		iptcDataset(&buffer, 2, 60, x.CaptureTime.Format("150405"), 11)
+	}
+	if x.Caption != "" {
+		iptcDataset(&buffer, 2, 120, x.Caption, 2000)
+	}
+	return buffer.Bytes()
+}
+
+// jpegSegment APP segment with marker, the length includes the length field
+func jpegSegment(marker byte, header string, data []byte) ([]byte, error) {
+	length := 2 + len(header) + len(data)
+	if length > math.MaxUint16 {
+		return nil, fmt.Errorf("JPEG segment too large: %d", length)
+	}
+	segment := []byte{0xff, marker, byte(length >> 8), byte(length)}
+	segment = append(segment, header...)
+	return append(segment, data...), nil
+}
+
+// isJPEG check the JPEG start of image marker
+func isJPEG(media []byte) bool {
+	return len(media) > 3 && media[0] == 0xff && media[1] == 0xd8 && media[2] == 0xff
+}
+
+// isJPEGType check the MIME type of JPEG media
+func isJPEGType(mimeType string) bool {
+	switch strings.ToLower(mimeType) {
+	case "image/jpeg", "image/jpg":
+		return true
+	}
+	return false
+}
+
+// EmbedJPEG copy of the JPEG with XMP and IPTC segments of the metadata.
+// Existing XMP and IPTC segments are replaced, the new segments are placed
+// behind the JFIF and EXIF segments.
+func EmbedJPEG(media []byte, x *XmpData) ([]byte, error) {
+	if !isJPEG(media) {
+		return nil, fmt.Errorf("media is not a JPEG")
+	}
+	xmpSegment, err := jpegSegment(jpegAPP1, xmpHeader, x.Packet())
+	if err != nil {
+		return nil, err
+	}
+	var resource bytes.Buffer
+	resource.Write(photoshopResources(media))
+	iptc := x.iptc()
+	resource.WriteString("8BIM")
+	binary.Write(&resource, binary.BigEndian, uint16(iptcResourceID))
+	resource.Write([]byte{0x00, 0x00})
+	binary.Write(&resource, binary.BigEndian, uint32(len(iptc)))
+	resource.Write(iptc)
+	if len(iptc)%2 == 1 {
+		resource.WriteByte(0)
+	}
+	iptcSegment, err := jpegSegment(jpegAPP13, iptcHeader, resource.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.Write(media[:2])
+	inserted := false
+	pos := 2
+	for pos+4 <= len(media) && media[pos] == 0xff {
+		marker := media[pos+1]
+		if marker == jpegSOS {
+			break
+		}
+		length := int(media[pos+2])<<8 | int(media[pos+3])
+		end := pos + 2 + length
+		if length < 2 || end > len(media) {
+			return nil, fmt.Errorf("invalid JPEG segment at %d", pos)
+		}
+		data := media[pos+4 : end]
+		leading := marker == jpegAPP0 || (marker == jpegAPP1 && bytes.HasPrefix(data, []byte("Exif\x00")))
+		if !leading && !inserted {
+			out.Write(xmpSegment)
+			out.Write(iptcSegment)
+			inserted = true
+		}
+		switch {
+		case marker == jpegAPP1 && bytes.HasPrefix(data, []byte(xmpHeader)):
+		case marker == jpegAPP13 && bytes.HasPrefix(data, []byte(iptcHeader)):
+			// other Photoshop resources are kept in the new APP13 segment
+		default:
+			out.Write(media[pos:end])
+		}
+		pos = end
+	}
+	if !inserted {
+		out.Write(xmpSegment)
+		out.Write(iptcSegment)
+	}
+	out.Write(media[pos:])
+	return out.Bytes(), nil
+}
+
+// photoshopResources all image resources of the Photoshop APP13 segments
+// except the IPTC-NAA resource, which is replaced by the new IPTC data
+func photoshopResources(media []byte) []byte {
+	var kept bytes.Buffer
+	pos := 2
+	for pos+4 <= len(media) && media[pos] == 0xff && media[pos+1] != jpegSOS {
+		length := int(media[pos+2])<<8 | int(media[pos+3])
+		end := pos + 2 + length
+		if length < 2 || end > len(media) {
+			break
+		}
+		data := media[pos+4 : end]
+		if media[pos+1] == jpegAPP13 && bytes.HasPrefix(data, []byte(iptcHeader)) {
+			data = data[len(iptcHeader):]
+			for len(data) >= 12 && bytes.HasPrefix(data, []byte("8BIM")) {
+				id := binary.BigEndian.Uint16(data[4:6])
+				// name is a Pascal string padded to even length
+				nameLength := int(data[6]) + 1
+				nameLength += nameLength % 2
+				if 6+nameLength+4 > len(data) {
+					break
+				}
+				size := int(binary.BigEndian.Uint32(data[6+nameLength:]))
+				blockLength := 6 + nameLength + 4 + size + size%2
+				if blockLength > len(data) {
+					break
+				}
+				if id != iptcResourceID {
+					kept.Write(data[:blockLength])
+				}
+				data = data[blockLength:]
+			}
+		}
+		pos = end
+	}
+	return kept.Bytes()
+}
+
+// embedCopy verify the media of the picture copy and replace it by the
+// JPEG with embedded metadata, the checksums of the copy are updated to the
+// written data
+func embedCopy(pic *store.Pictures, x *XmpData) error {
+	mh := newMediaHash()
+	mh.Write(pic.Media)
+	err := mh.verify(pic.ChecksumPicture, pic.ChecksumPictureSHA)
+	if err != nil {
+		return err
+	}
+	media, err := EmbedJPEG(pic.Media, x)
+	if err != nil {
+		return err
+	}
+	setCopyMedia(pic, media)
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tknie/bitgartentools/store"
+)
+
+func TestNewXmpData(t *testing.T) {
+	x := newXmpData("Beach", "At the beach", "family,rating:4,rating:x", 0, 0, time.Time{})
+	assert.Equal(t, []string{"family", "rating:x"}, x.Keywords)
+	assert.Equal(t, 4, x.Rating)
+	assert.Equal(t, 0.0, x.Latitude)
+	assert.Nil(t, newXmpData("", "", "", 0, 0, time.Time{}).Keywords)
+}
+
+func TestXmpCoordinate(t *testing.T) {
+	assert.Equal(t, "49,52.272000N", xmpCoordinate(49.8712, "N", "S"))
+	assert.Equal(t, "8,30.000000W", xmpCoordinate(-8.5, "E", "W"))
+}
+
+func TestXmpPacket(t *testing.T) {
+	x := newXmpData("Tom & Jerry", "<caption>", "beach,rating:5", 49.8712, 8.65,
+		time.Date(2024, 7, 14, 10, 30, 0, 0, time.UTC))
+	packet := x.Packet()
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	assert.Contains(t, string(packet), `xmp:Rating="5"`)
+	assert.Contains(t, string(packet), `exif:DateTimeOriginal="2024-07-14T10:30:00"`)
+	assert.Contains(t, string(packet), `exif:GPSLatitude="49,52.272000N"`)
+	assert.Contains(t, string(packet), "<rdf:li>beach</rdf:li>")
+	assert.Contains(t, string(packet), "Tom &amp; Jerry")
+	assert.Contains(t, string(packet), "&lt;caption&gt;")
+}
+
+func TestWriteSidecar(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "IMG.JPG")
+	x := newXmpData("", "", "beach", 0, 0, time.Time{})
+	assert.NoError(t, writeSidecar(fileName, x))
+	data, err := os.ReadFile(fileName + SidecarExtension)
+	assert.NoError(t, err)
+	assert.Equal(t, x.Packet(), data)
+}
+
+func TestEmbedJPEG(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buffer, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	media := buffer.Bytes()
+	x := newXmpData("Beach", "At the beach", "family", 0, 0, time.Date(2024, 7, 14, 10, 30, 0, 0, time.UTC))
+
+	embedded, err := EmbedJPEG(media, x)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, bytes.Count(embedded, []byte(xmpHeader)))
+	assert.Equal(t, 1, bytes.Count(embedded, []byte(iptcHeader)))
+	assert.True(t, bytes.Contains(embedded, []byte("\x1c\x02\x19\x00\x06family")))
+	_, err = jpeg.Decode(bytes.NewReader(embedded))
+	assert.NoError(t, err)
+
+	// embedding again replaces the segments
+	again, err := EmbedJPEG(embedded, newXmpData("", "", "other", 0, 0, time.Time{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, bytes.Count(again, []byte(xmpHeader)))
+	assert.False(t, bytes.Contains(again, []byte("family")))
+	_, err = jpeg.Decode(bytes.NewReader(again))
+	assert.NoError(t, err)
+
+	_, err = EmbedJPEG([]byte("no jpeg"), x)
+	assert.Error(t, err)
+}
+
+func TestEmbedJPEGKeepsResources(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buffer, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	media := buffer.Bytes()
+	// resolution info resource 0x03ed with an odd data length
+	other := []byte("8BIM\x03\xed\x00\x00\x00\x00\x00\x03abc\x00")
+	segment, err := jpegSegment(jpegAPP13, iptcHeader, other)
+	if !assert.NoError(t, err) {
+		return
+	}
+	withResource := append(append(append([]byte{}, media[:2]...), segment...), media[2:]...)
+
+	embedded, err := EmbedJPEG(withResource, newXmpData("", "", "family", 0, 0, time.Time{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, bytes.Count(embedded, []byte(iptcHeader)))
+	assert.True(t, bytes.Contains(embedded, other))
+	assert.True(t, bytes.Contains(embedded, []byte("family")))
+
+	again, err := EmbedJPEG(embedded, newXmpData("", "", "other", 0, 0, time.Time{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, bytes.Count(again, []byte(iptcHeader)))
+	assert.Equal(t, 1, bytes.Count(again, other))
+	assert.False(t, bytes.Contains(again, []byte("family")))
+	_, err = jpeg.Decode(bytes.NewReader(again))
+	assert.NoError(t, err)
+}
+
+func TestIptcDatasetRuneBoundary(t *testing.T) {
+	var buffer bytes.Buffer
+	iptcDataset(&buffer, 2, 5, "Gärten", 2)
+	data := buffer.Bytes()[5:]
+	assert.Equal(t, "G", string(data))
+
+	buffer.Reset()
+	iptcDataset(&buffer, 2, 5, "Gärten", 3)
+	data = buffer.Bytes()[5:]
+	assert.True(t, utf8.Valid(data))
+	assert.Equal(t, "Gä", string(data))
+}
+
+func TestIsJPEGType(t *testing.T) {
+	assert.True(t, isJPEGType("image/jpeg"))
+	assert.True(t, isJPEGType("Image/JPG"))
+	assert.False(t, isJPEGType("image/heic"))
+}
+
+func TestEmbedCopy(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buffer, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	media := buffer.Bytes()
+	pic := &store.Pictures{Media: media, ChecksumPicture: store.CreateMd5(media)}
+	assert.NoError(t, embedCopy(pic, newXmpData("", "", "beach", 0, 0, time.Time{})))
+	assert.Equal(t, store.CreateMd5(pic.Media), pic.ChecksumPicture)
+	assert.Equal(t, store.CreateSHA(pic.Media), pic.ChecksumPictureSHA)
+	assert.NotEqual(t, store.CreateMd5(media), pic.ChecksumPicture)
+
+	bad := &store.Pictures{Media: media, ChecksumPicture: "0000"}
+	assert.Error(t, embedCopy(bad, newXmpData("", "", "", 0, 0, time.Time{})))
+}
@@ -27,6 +27,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/tknie/bitgartentools/sql"
 	"github.com/tknie/services"
@@ -56,7 +57,7 @@ const readHashs = `
 SELECT count(perceptionhash) AS count,
 perceptionhash
    FROM picturehash p
-  WHERE (EXISTS ( SELECT 1
+  WHERE p.kind = {{.Kind}} AND (EXISTS ( SELECT 1
            FROM pictures pp
           WHERE pp.checksumpicture::text = p.checksumpicture::text AND pp.markdelete = false))
   GROUP BY perceptionhash
@@ -67,12 +68,14 @@ perceptionhash
 
 const readPictureByHashs = `
 select checksumpicture, title, height, width, Exifxdimension, Exifydimension,
+COALESCE(quality, 0) AS quality, COALESCE(exifmodel, '') AS exifmodel, COALESCE(picopt, '') AS picopt, COALESCE(mimetype, '') AS mimetype,
+( SELECT count(*)::int4 FROM albumpictures ap WHERE ap.checksumpicture::text = p.checksumpicture::text) AS albums,
 ( SELECT string_agg(DISTINCT (''''::text || pt.tagname::text) || ''''::text, ','::text) AS string_agg
            FROM picturetags pt
           WHERE pt.checksumpicture::text = p.checksumpicture::text) AS tags
 from pictures p where markdelete = false and EXISTS ( SELECT 1
 	FROM picturehash pp
-   WHERE pp.perceptionhash = {{.}} AND pp.checksumpicture::text = p.checksumpicture::text );
+   WHERE pp.perceptionhash = {{.Hash}} AND pp.kind = {{.Kind}} AND pp.checksumpicture::text = p.checksumpicture::text );
 `
 
 const readHEIC = `
@@ -84,11 +87,18 @@ SELECT checksumpicture, title from pictures where markdelete = false
 `
 
 type HashCleanParameter struct {
-	Limit    int
-	MinCount int
-	Title    string
-	Commit   bool
-	Json     bool
+	Limit       int
+	MinCount    int
+	Distance    int
+	Video       bool
+	Orientation bool
+	Title       string
+	Report      string
+	Decisions   string
+	Policy      *RetentionPolicy
+	Commit      bool
+	Json        bool
+	review      *DuplicateReview
 }
 
 type heicCheck struct {
@@ -100,6 +110,36 @@ func HashClean(parameter *HashCleanParameter) error {
 	if !parameter.Json {
 		services.ServerMessage("Query database entries for one week not hashed commit=%v", parameter.Commit)
 	}
+	if parameter.Policy == nil {
+		parameter.Policy = DefaultRetentionPolicy
+	}
+	if parameter.Report != "" || parameter.Decisions != "" {
+		// review mode only proposes the decisions
+		parameter.Commit = false
+		parameter.review = &DuplicateReview{Created: time.Now()}
+	}
+	err := parameter.cleanDuplicates()
+	if err != nil {
+		return err
+	}
+	if parameter.review != nil {
+		return parameter.review.write(parameter.Report, parameter.Decisions)
+	}
+	return nil
+}
+
+// cleanDuplicates search groups of duplicates dependent on the mode and
+// resolve each group
+func (parameter *HashCleanParameter) cleanDuplicates() error {
+	if parameter.Video {
+		return parameter.videoDuplicateClean()
+	}
+	if parameter.Orientation {
+		return parameter.orientationDuplicateClean()
+	}
+	if parameter.Distance > 0 {
+		return parameter.nearDuplicateClean()
+	}
 	hashList, err := parameter.queryHash()
 	if err != nil {
 		fmt.Println("Error query max hash:", err)
@@ -111,7 +151,7 @@ func HashClean(parameter *HashCleanParameter) error {
 			break
 		}
 		fmt.Printf("Working on %d.Hash %s\n", i+1, h)
-		err = queryPictureByHash(h, parameter.Commit)
+		err = parameter.queryPictureByHash(h)
 		if err != nil {
 			fmt.Println("Error query max hash:", err)
 			return err
@@ -131,7 +171,8 @@ func (parameter *HashCleanParameter) queryHash() ([]string, error) {
 	sql, err := templateSql(readHashs, struct {
 		Limit int
 		Count int
-	}{parameter.Limit, parameter.MinCount})
+		Kind  int
+	}{parameter.Limit, parameter.MinCount, StandardHashKind})
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +213,11 @@ type PictureByHash struct {
 	Width           int
 	Exifxdimension  int
 	Exifydimension  int
+	Quality         float64
+	Exifmodel       string
+	Picopt          string
+	Mimetype        string
+	Albums          int
 	Tags            string
 	delete          bool `flynn:":ignore"`
 }
@@ -187,7 +233,7 @@ func templateSql(t string, p any) (string, error) {
 	return sql.String(), nil
 }
 
-func queryPictureByHash(hash string, commit bool) error {
+func (parameter *HashCleanParameter) queryPictureByHash(hash string) error {
 	id, err := sql.DatabaseHandler()
 	if err != nil {
 		fmt.Println("POSTGRES error", err)
@@ -195,11 +241,23 @@ func queryPictureByHash(hash string, commit bool) error {
 	}
 	defer id.FreeHandler()
 
-	sqlCmd, err := templateSql(readPictureByHashs, hash)
+	sqlCmd, err := templateSql(readPictureByHashs, struct {
+		Hash string
+		Kind int
+	}{hash, StandardHashKind})
 	if err != nil {
 		return err
 	}
 
+	picturesByHash, err := readPicturesByHash(id, sqlCmd)
+	if err != nil {
+		return err
+	}
+	return parameter.resolvePictures(hash, picturesByHash)
+}
+
+// readPicturesByHash read all pictures of a group of same or similar pictures
+func readPicturesByHash(id common.RegDbID, sqlCmd string) ([]*PictureByHash, error) {
 	picturesByHash := make([]*PictureByHash, 0)
 
 	query := &common.Query{
@@ -209,7 +267,7 @@ func queryPictureByHash(hash string, commit bool) error {
 		Search:     sqlCmd,
 	}
 	counter := uint64(0)
-	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+	err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
 		ph := result.Data.(*PictureByHash)
 		log.Log.Debugf("Picture found: %#v", ph)
 		newPH := &PictureByHash{}
@@ -220,77 +278,37 @@ func queryPictureByHash(hash string, commit bool) error {
 	})
 	if err != nil {
 		fmt.Println("Error query ...:", err)
-		return err
+		return nil, err
 	}
+	log.Log.Debugf("Picture by hash end: %v -> %d", err, counter)
+	return picturesByHash, nil
+}
 
-	sort.SliceStable(picturesByHash, func(x, y int) bool {
-		return picturesByHash[x].Width > picturesByHash[y].Width
-	})
-	services.ServerMessage("Found %d picture hash entries", len(picturesByHash))
-	var firstFound *PictureByHash
-	tagMap := make(map[string]bool)
-	for _, pbh := range picturesByHash {
-		if strings.HasSuffix(strings.ToLower(pbh.Title), ".heic") {
-			services.ServerMessage("HEIC found : %s", pbh.Title)
-			if firstFound == nil {
-				firstFound = pbh
-			} else {
-				if firstFound.Width <= pbh.Width {
-					if !strings.Contains(firstFound.Tags, "'bitgarten'") {
-						firstFound.delete = true
-					}
-					firstFound = pbh
-				}
-			}
-		} else {
-			if !strings.Contains(pbh.Tags, "'bitgarten'") {
-				if firstFound == nil {
-					firstFound = pbh
-				} else {
-					pbh.delete = true
-				}
-				if pbh.Tags != "" {
-					tags := strings.Split(pbh.Tags, ",")
-					for _, t := range tags {
-						tagMap[t] = true
-					}
-				}
-			} else {
-				if firstFound != nil && firstFound.Width == pbh.Width {
-					firstFound.delete = true
-					firstFound = pbh
-				}
-				if firstFound == nil {
-					firstFound = pbh
-				}
-				if pbh.Tags != "" {
-					tags := strings.Split(pbh.Tags, ",")
-					for _, t := range tags {
-						if t != "'bitgarten'" {
-							tagMap[t] = true
-						}
-					}
-				}
-			}
-
-			log.Log.Debugf("Find picture hash %#v", pbh)
-		}
-	}
-	if firstFound == nil {
+// resolvePictures select the picture to keep out of the group of same
+// pictures with the retention policy, all others are marked to be deleted.
+// In review mode the decision is only recorded.
+func (parameter *HashCleanParameter) resolvePictures(hash string, picturesByHash []*PictureByHash) error {
+	decision := parameter.Policy.Decide(picturesByHash)
+	if decision == nil {
 		services.ServerMessage("No first found out of %d", len(picturesByHash))
-	} else {
-		services.ServerMessage("Start cleanup for %s entries=%d", hash, len(picturesByHash))
-		err = cleanUpPictures(commit, tagMap, firstFound, picturesByHash)
-		if err != nil {
-			fmt.Println("Error cleanup pictures:", err)
-			return err
-		}
+		return nil
+	}
+	services.ServerMessage("Group %s keeps %s decided by rule '%s'", hash, decision.Keep.Checksumpicture, decision.Rule)
+	if parameter.review != nil {
+		parameter.review.add(hash, decision, picturesByHash)
+		return nil
+	}
+	services.ServerMessage("Start cleanup for %s entries=%d", hash, len(picturesByHash))
+	err := cleanUpPictures(parameter.Commit, fmt.Sprintf("duplicate of %s in %s, rule %s", decision.Keep.Checksumpicture, hash, decision.Rule),
+		decision.TagMap, decision.Keep, picturesByHash)
+	if err != nil {
+		fmt.Println("Error cleanup pictures:", err)
+		return err
 	}
-	log.Log.Debugf("Picture by hash end: %v -> %d", err, counter)
 	return nil
 }
 
-func cleanUpPictures(commit bool, tagMap map[string]bool, firstFound *PictureByHash, picturesByHash []*PictureByHash) error {
+func cleanUpPictures(commit bool, reason string, tagMap map[string]bool, firstFound *PictureByHash, picturesByHash []*PictureByHash) error {
 	id, err := sql.DatabaseHandler()
 	if err != nil {
 		fmt.Println("POSTGRES error", err)
@@ -303,61 +321,25 @@ func cleanUpPictures(commit bool, tagMap map[string]bool, firstFound *PictureByH
 		return err
 	}
 
-	newTags := KeysString(tagMap)
-	log.Log.Debugf("First: %#v -> %#v", firstFound, newTags)
-	if newTags != firstFound.Tags {
-		oldTagMap := KeysMap(firstFound.Tags)
-		newTagMap := KeysMap(newTags)
-		changed := false
-		for k := range newTagMap {
-			if k != "" {
-				if !oldTagMap[k] {
-					oldTagMap[k] = true
-					changed = true
-
-					fmt.Printf("Insert tag for %s to <%s> (%s)\n", firstFound.Checksumpicture, k, strings.Trim(k, "'"))
-					input := &common.Entries{
-						Fields: []string{"tagname", "checksumpicture"},
-						Update: []string{"checksumpicture='" + firstFound.Checksumpicture + "'"},
-						Values: [][]any{{strings.Trim(k, "'"), firstFound.Checksumpicture}},
-					}
-					_, err := id.Insert("picturetags", input)
-					if err != nil {
-						log.Log.Debugf("Error insert tag name %s for %s", k, firstFound.Checksumpicture)
-						return err
-					}
-				}
-			}
-		}
-		if changed {
-			fmt.Println("Updated tag for", firstFound.Checksumpicture, "to", newTags, "from", firstFound.Tags)
-		}
-	}
-
+	keepTags := KeysMap(firstFound.Tags)
 	for _, pbh := range picturesByHash {
 		log.Log.Debugf("Cleanup picture %#v", pbh)
 		if pbh.delete {
-			if pbh.Tags != "" {
-				fmt.Println("Need to delete all tags for -> ", pbh.Checksumpicture, "tags=", pbh.Tags)
-				log.Log.Debugf("Need to delete all tags for -> %s", pbh.Checksumpicture)
-				dr, err := id.Delete("picturetags", &common.Entries{Criteria: "checksumpicture='" + pbh.Checksumpicture + "'"})
-				if err != nil {
-					return err
-				}
-				if dr == 0 {
-					fmt.Printf("error deleting picture tags for %s: no entry deleted\n", pbh.Checksumpicture)
-				}
-				log.Log.Debugf("%d entries deleted", dr)
-
+			moved, err := moveReferences(id, tagMap, keepTags, firstFound, pbh)
+			if err != nil {
+				id.Rollback()
+				return err
 			}
 			services.ServerMessage("Need to mark delete -> %v", pbh.Checksumpicture)
-			ra, err := markPictureDelete(id, pbh.Checksumpicture)
+			ra, err := markDuplicateDelete(id, pbh.Checksumpicture, reason, pbh.Tags, moved)
 			if err != nil {
 				log.Log.Infof("Error setting mark picture delete: %v", err)
-				return nil
+				id.Rollback()
+				return err
 			}
 			if ra != 1 {
 				log.Log.Infof("Error setting mark picture delete, update count wrong: %v", ra)
+				id.Rollback()
 				return fmt.Errorf("incorrect update mark delete of %s: %d", pbh.Checksumpicture, ra)
 			}
 			services.ServerMessage("Mark delete -> %v", pbh.Checksumpicture)
@@ -365,6 +347,25 @@ func cleanUpPictures(commit bool, tagMap map[string]bool, firstFound *PictureByH
 		}
 	}
 
+	// tags of pictures which are kept, e.g. protected pictures, are copied
+	for k := range tagMap {
+		if k == "" || keepTags[k] {
+			continue
+		}
+		keepTags[k] = true
+		fmt.Printf("Insert tag for %s to <%s> (%s)\n", firstFound.Checksumpicture, k, strings.Trim(k, "'"))
+		input := &common.Entries{
+			Fields: []string{"tagname", "checksumpicture"},
+			Values: [][]any{{strings.Trim(k, "'"), firstFound.Checksumpicture}},
+		}
+		_, err := id.Insert("picturetags", input)
+		if err != nil {
+			log.Log.Debugf("Error insert tag name %s for %s", k, firstFound.Checksumpicture)
+			id.Rollback()
+			return err
+		}
+	}
+
 	if commit {
 		services.ServerMessage("Commiting delete...")
 		err = id.Commit()
@@ -410,20 +411,6 @@ func HeicClean(parameter *HashCleanParameter) error {
 	return err
 }
 
-func markPictureDelete(id common.RegDbID, checksumpicture string) (int64, error) {
-	services.ServerMessage("Need to mark delete -> %v", checksumpicture)
-	input := &common.Entries{
-		Fields: []string{"markdelete"},
-		Update: []string{"checksumpicture='" + checksumpicture + "'"},
-		Values: [][]any{{true}},
-	}
-	_, ra, err := id.Update("pictures", input)
-	if err != nil {
-		return 0, err
-	}
-	return ra, nil
-}
-
 // queryHEIC search for all HEIC images in database
 func (parameter *HashCleanParameter) queryHEIC() error {
 	id, err := sql.DatabaseHandler()
@@ -511,7 +498,7 @@ func (parameter *HashCleanParameter) queryHEIC() error {
 			if tags == 0 && parameter.Commit {
 				// No tags found and commit then mark delete
 				services.ServerMessage("Mark deleted: %s sub of %s", l.title, foundList[i-1].title)
-				ra, err := markPictureDelete(id, l.checksumpicture)
+				ra, err := markPictureDelete(id, l.checksumpicture, "HEIC sub picture of "+foundList[i-1].title, "")
 				if err != nil || ra != 1 {
 					fmt.Println(ra, " pictures marked deleted: %v", err)
 					return err
@@ -574,7 +561,7 @@ func reducePictures(id common.RegDbID, title string) error {
 		log.Log.Debugf("check %s <%s> tags=%d", c.title, c.checksumpicture, t)
 		if t == 0 {
 			fmt.Printf("Mark deleted: %s\n", c.title)
-			ra, err := markPictureDelete(id, c.checksumpicture)
+			ra, err := markPictureDelete(id, c.checksumpicture, "more pictures of "+title, "")
 			if err != nil || ra != 1 {
 				fmt.Println(ra, " pictures marked deleted: %v", err)
 				return err
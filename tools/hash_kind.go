@@ -0,0 +1,274 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/corona10/goimagehash"
+	"github.com/nfnt/resize"
+)
+
+// Hash algorithms recorded in the kind column of picturehash
+const (
+	AverageAlgorithm = iota + 1
+	PerceptionAlgorithm
+	DifferenceAlgorithm
+	WaveletAlgorithm
+	OrientationAlgorithm
+)
+
+// kindFactor the kind is algorithm * kindFactor + bit size
+const kindFactor = 100000
+
+// StandardHashKind 64-bit perception hash row containing average and
+// difference hash as well, used by hashclean
+var StandardHashKind = HashKind(PerceptionAlgorithm, 64)
+
+// HashRevision revision of the hash generation, increase it if the image
+// preparation or one of the hash algorithms change
+const HashRevision = 1
+
+const hashLibrary = "github.com/corona10/goimagehash"
+
+// HashVersion version recorded with each hash, hashes with another
+// version are stale and re-hashed
+var HashVersion = hashVersion()
+
+var hashFamilyRegexp = regexp.MustCompile(`^([a-zA-Z]+?)(\d*)$`)
+
+// HashFamily hash algorithm with bit size
+type HashFamily struct {
+	Name      string
+	Algorithm int
+	Bits      int
+}
+
+// hashVersion combine the hash revision and the version of the hash library
+func hashVersion() string {
+	library := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == hashLibrary {
+				library = dep.Version
+				if dep.Replace != nil {
+					library = dep.Replace.Version
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("%d/goimagehash@%s", HashRevision, library)
+}
+
+// HashKind kind of the hash out of algorithm and bit size
+func HashKind(algorithm, bits int) int {
+	return algorithm*kindFactor + bits
+}
+
+// Kind kind column value of the hash family
+func (family *HashFamily) Kind() int {
+	return HashKind(family.Algorithm, family.Bits)
+}
+
+// Standard hash family containing average, perception and difference hash
+func (family *HashFamily) Standard() bool {
+	return family.Kind() == StandardHashKind
+}
+
+func (family *HashFamily) String() string {
+	if family.Bits == 64 {
+		return family.Name
+	}
+	return family.Name + strconv.Itoa(family.Bits)
+}
+
+// ParseHashSet parse comma separated list of hash families like
+// 'perceptHash,waveletHash,perceptHash256'. Without bit size suffix
+// the 64-bit hash is used.
+func ParseHashSet(hashSet string) ([]*HashFamily, error) {
+	families := make([]*HashFamily, 0)
+	kinds := make(map[int]bool)
+	for _, h := range strings.Split(hashSet, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		m := hashFamilyRegexp.FindStringSubmatch(h)
+		if m == nil {
+			return nil, fmt.Errorf("incorrect hash parameter given: %s not in %v", h, Hashes)
+		}
+		algorithm := 0
+		for i, n := range Hashes {
+			if n == m[1] {
+				algorithm = i + 1
+			}
+		}
+		if algorithm == 0 {
+			return nil, fmt.Errorf("incorrect hash parameter given: %s not in %v", h, Hashes)
+		}
+		b := 64
+		if m[2] != "" {
+			b, _ = strconv.Atoi(m[2])
+		}
+		if b < 64 || bits.OnesCount(uint(b)) != 1 {
+			return nil, fmt.Errorf("hash bit size of %s need to be a power of 2 starting with 64", h)
+		}
+		if algorithm == WaveletAlgorithm && bits.TrailingZeros(uint(b))%2 != 0 {
+			return nil, fmt.Errorf("wavelet hash bit size of %s need to be a square", h)
+		}
+		if algorithm == OrientationAlgorithm && b != 64 {
+			return nil, fmt.Errorf("orientation hash %s is only available with 64 bit", h)
+		}
+		family := &HashFamily{Name: m[1], Algorithm: algorithm, Bits: b}
+		if !kinds[family.Kind()] {
+			kinds[family.Kind()] = true
+			families = append(families, family)
+		}
+	}
+	if len(families) == 0 {
+		return nil, fmt.Errorf("no hash type given")
+	}
+	return families, nil
+}
+
+// extSize width and height of the extended hash with the bit size
+func extSize(b int) (int, int) {
+	width := 1 << ((bits.TrailingZeros(uint(b)) + 1) / 2)
+	return width, b / width
+}
+
+// Compute calculate the hash of the image, hashes with more than 64 bit
+// contain multiple words
+func (family *HashFamily) Compute(img image.Image) ([]uint64, error) {
+	if family.Algorithm == OrientationAlgorithm {
+		return OrientationHashes(img)
+	}
+	if family.Algorithm == WaveletAlgorithm {
+		width, _ := extSize(family.Bits)
+		return WaveletHash(img, width), nil
+	}
+	if family.Bits == 64 {
+		var h *goimagehash.ImageHash
+		var err error
+		switch family.Algorithm {
+		case AverageAlgorithm:
+			h, err = goimagehash.AverageHash(img)
+		case PerceptionAlgorithm:
+			h, err = goimagehash.PerceptionHash(img)
+		case DifferenceAlgorithm:
+			h, err = goimagehash.DifferenceHash(img)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []uint64{h.GetHash()}, nil
+	}
+	width, height := extSize(family.Bits)
+	var h *goimagehash.ExtImageHash
+	var err error
+	switch family.Algorithm {
+	case AverageAlgorithm:
+		h, err = goimagehash.ExtAverageHash(img, width, height)
+	case PerceptionAlgorithm:
+		h, err = goimagehash.ExtPerceptionHash(img, width, height)
+	case DifferenceAlgorithm:
+		h, err = goimagehash.ExtDifferenceHash(img, width, height)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.GetHash(), nil
+}
+
+// HashHex hexadecimal representation of multi-word hashes
+func HashHex(words []uint64) string {
+	var b strings.Builder
+	for _, w := range words {
+		fmt.Fprintf(&b, "%016x", w)
+	}
+	return b.String()
+}
+
+// WaveletHash Haar wavelet hash with hashSize*hashSize bits. The grayscale
+// image is transformed three levels and the low frequency band is compared
+// against its median.
+func WaveletHash(img image.Image, hashSize int) []uint64 {
+	const levels = 3
+	size := hashSize << levels
+	small := resize.Resize(uint(size), uint(size), img, resize.Bilinear)
+	b := small.Bounds()
+	pixels := make([]float64, size*size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			r, g, bl, _ := small.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			pixels[y*size+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 65535
+		}
+	}
+	n := size
+	tmp := make([]float64, size)
+	for l := 0; l < levels; l++ {
+		half := n / 2
+		// rows
+		for y := 0; y < n; y++ {
+			for x := 0; x < half; x++ {
+				a := pixels[y*size+2*x]
+				c := pixels[y*size+2*x+1]
+				tmp[x] = (a + c) / math.Sqrt2
+				tmp[half+x] = (a - c) / math.Sqrt2
+			}
+			copy(pixels[y*size:y*size+n], tmp[:n])
+		}
+		// columns
+		for x := 0; x < n; x++ {
+			for y := 0; y < half; y++ {
+				a := pixels[2*y*size+x]
+				c := pixels[(2*y+1)*size+x]
+				tmp[y] = (a + c) / math.Sqrt2
+				tmp[half+y] = (a - c) / math.Sqrt2
+			}
+			for y := 0; y < n; y++ {
+				pixels[y*size+x] = tmp[y]
+			}
+		}
+		n = half
+	}
+	ll := make([]float64, 0, hashSize*hashSize)
+	for y := 0; y < hashSize; y++ {
+		ll = append(ll, pixels[y*size:y*size+hashSize]...)
+	}
+	sorted := make([]float64, len(ll))
+	copy(sorted, ll)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	words := make([]uint64, (len(ll)+63)/64)
+	for i, v := range ll {
+		if v > median {
+			words[i/64] |= 1 << (63 - uint(i%64))
+		}
+	}
+	return words
+}
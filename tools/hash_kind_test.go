@@ -0,0 +1,76 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHashSet(t *testing.T) {
+	families, err := ParseHashSet("perceptHash, waveletHash,perceptHash256,perceptHash")
+	assert.NoError(t, err)
+	if assert.Len(t, families, 3) {
+		assert.Equal(t, StandardHashKind, families[0].Kind())
+		assert.True(t, families[0].Standard())
+		assert.Equal(t, 400064, families[1].Kind())
+		assert.Equal(t, 200256, families[2].Kind())
+		assert.Equal(t, "perceptHash256", families[2].String())
+	}
+	_, err = ParseHashSet("unknownHash")
+	assert.Error(t, err)
+	_, err = ParseHashSet("averageHash100")
+	assert.Error(t, err)
+	_, err = ParseHashSet("waveletHash128")
+	assert.Error(t, err)
+	_, err = ParseHashSet("orientHash256")
+	assert.Error(t, err)
+}
+
+func TestWaveletHash(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 100; x++ {
+			if x >= 50 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	h := WaveletHash(img, 8)
+	assert.Len(t, h, 1)
+	// right half of every row is bright
+	assert.Equal(t, uint64(0x0F0F0F0F0F0F0F0F), h[0])
+	family := &HashFamily{Name: "waveletHash", Algorithm: WaveletAlgorithm, Bits: 256}
+	words, err := family.Compute(img)
+	assert.NoError(t, err)
+	assert.Len(t, words, 4)
+	family = &HashFamily{Name: "perceptHash", Algorithm: PerceptionAlgorithm, Bits: 256}
+	words, err = family.Compute(img)
+	assert.NoError(t, err)
+	assert.Len(t, words, 4)
+	assert.Len(t, HashHex(words), 64)
+}
+
+func TestHashVersion(t *testing.T) {
+	assert.Regexp(t, `^1/goimagehash@v\d+\.\d+\.\d+`, HashVersion)
+}
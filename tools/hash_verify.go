@@ -0,0 +1,142 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// VerifyHashDistance maximal Hamming distance per 64 bit for which the
+// media and thumbnail hash are accepted as equal
+const VerifyHashDistance = 4
+
+// HashVerifyStat distance statistic of one hash family
+type HashVerifyStat struct {
+	Family   *HashFamily
+	Count    int
+	Sum      int
+	Max      int
+	Exceeded int
+}
+
+// hashDistance Hamming distance of two hashes of the same family, multi-word
+// hashes are compared word by word
+func hashDistance(a, b *hashData) int {
+	if a.Exthash == "" || b.Exthash == "" {
+		return HammingDistance(a.Hash, b.Hash)
+	}
+	wa, erra := parseHashHex(a.Exthash)
+	wb, errb := parseHashHex(b.Exthash)
+	if erra != nil || errb != nil || len(wa) != len(wb) {
+		return HammingDistance(a.Hash, b.Hash)
+	}
+	d := 0
+	for i := range wa {
+		d += HammingDistance(wa[i], wb[i])
+	}
+	return d
+}
+
+// add the distance of one picture
+func (stat *HashVerifyStat) add(distance int) {
+	stat.Count++
+	stat.Sum += distance
+	stat.Max = max(stat.Max, distance)
+	if distance > VerifyHashDistance*stat.Family.Bits/64 {
+		stat.Exceeded++
+	}
+}
+
+// Agree all sampled hashes are within the accepted distance
+func (stat *HashVerifyStat) Agree() bool {
+	return stat.Count > 0 && stat.Exceeded == 0
+}
+
+func (stat *HashVerifyStat) String() string {
+	mean := 0.0
+	if stat.Count > 0 {
+		mean = float64(stat.Sum) / float64(stat.Count)
+	}
+	return fmt.Sprintf("%-16s samples=%d mean distance=%.2f max distance=%d exceeded=%d agree=%v",
+		stat.Family.String(), stat.Count, mean, stat.Max, stat.Exceeded, stat.Agree())
+}
+
+// VerifyHashSource hash a random sample of pictures from the full media and
+// from the thumbnail and compare the hashes of all families
+func VerifyHashSource(parameter *ImageHashParameter) ([]*HashVerifyStat, error) {
+	families, err := ParseHashSet(parameter.HashType)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]*HashVerifyStat, 0, len(families))
+	for _, f := range families {
+		stats = append(stats, &HashVerifyStat{Family: f})
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		return nil, fmt.Errorf("POSTGRES error: %v", err)
+	}
+	defer id.FreeHandler()
+
+	query := &common.Query{
+		TableName:  "pictures",
+		Fields:     []string{"ChecksumPicture", "title", "mimetype", "media", "thumbnail"},
+		DataStruct: &store.Pictures{},
+		Search:     "markdelete = false AND mimetype LIKE 'image/%' AND thumbnail IS NOT NULL",
+		Order:      []string{"random()"},
+		Limit:      strconv.Itoa(parameter.Verify),
+	}
+	_, err = id.Query(query, func(search *common.Query, result *common.Result) error {
+		p := result.Data.(*store.Pictures)
+		full, err := hashMedia(p, bytes.NewBuffer(p.Media), families)
+		if err != nil {
+			log.Log.Errorf("Error generating hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
+			return nil
+		}
+		thumb, err := hashJpeg(bytes.NewBuffer(p.Thumbnail), families)
+		if err != nil {
+			log.Log.Errorf("Error generating thumbnail hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
+			return nil
+		}
+		for i, stat := range stats {
+			d := hashDistance(full[i], thumb[i])
+			stat.add(d)
+			if !parameter.Json {
+				fmt.Printf("%s/%s %s distance=%d\n", p.Title, p.ChecksumPicture, stat.Family, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query error: %v", err)
+	}
+	for _, stat := range stats {
+		hashOutput(nil, stat.String())
+	}
+	return stats, nil
+}
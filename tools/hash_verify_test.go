@@ -0,0 +1,72 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"os"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashDistance(t *testing.T) {
+	a := &hashData{Hash: 0xF0}
+	b := &hashData{Hash: 0xF3}
+	assert.Equal(t, 2, hashDistance(a, b))
+	a.Exthash = HashHex([]uint64{0x1, 0x0})
+	b.Exthash = HashHex([]uint64{0x0, 0x7})
+	assert.Equal(t, 4, hashDistance(a, b))
+
+	stat := &HashVerifyStat{Family: &HashFamily{Name: "perceptHash", Algorithm: PerceptionAlgorithm, Bits: 64}}
+	assert.False(t, stat.Agree())
+	stat.add(2)
+	stat.add(4)
+	assert.True(t, stat.Agree())
+	stat.add(5)
+	assert.False(t, stat.Agree())
+	assert.Equal(t, 5, stat.Max)
+	assert.Equal(t, 1, stat.Exceeded)
+}
+
+func TestThumbnailHash(t *testing.T) {
+	data, err := os.ReadFile("../testimg/IMG_1098.jpg")
+	if !assert.NoError(t, err) {
+		return
+	}
+	families, err := ParseHashSet("perceptHash,waveletHash")
+	assert.NoError(t, err)
+	full, err := hashJpeg(bytes.NewBuffer(data), families)
+	assert.NoError(t, err)
+
+	// thumbnail like generated by the picture load
+	img, _, err := image.Decode(bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, resize.Resize(200, 0, img, resize.Lanczos3), nil))
+	thumb, err := hashJpeg(&buf, families)
+	assert.NoError(t, err)
+	for i := range families {
+		assert.LessOrEqual(t, hashDistance(full[i], thumb[i]), VerifyHashDistance)
+	}
+}
@@ -142,7 +142,8 @@ func (parameter *HeicThumbParameter) storeThumb(pic *store.Pictures) error {
 		Fields: []string{"exif", "Thumbnail",
 			"exifmodel", "exifmake", "exiftaken", "exiforigtime",
 			"exifxdimension", "exifydimension", "exiforientation",
-			"GPScoordinates", "GPSlatitude", "GPSlongitude"},
+			"GPScoordinates", "GPSlatitude", "GPSlongitude",
+			"blurhash", "averagecolor", "dominantcolors", "quality"},
 		DataStruct: pic,
 		Values:     [][]any{{pic}},
 		Update:     []string{"checksumpicture='" + pic.ChecksumPicture + "'"},
@@ -208,12 +209,7 @@ func (parameter *HeicThumbParameter) searchSimilarEntries(title string) {
 			case ".jpeg", ".heic":
 				fmt.Println("  Deleting ", pic.Title, pic.ChecksumPicture, pic.MIMEType, pic.ExifOrigTime)
 				if parameter.Commit {
-					update := &common.Entries{
-						Fields: []string{"markdelete"},
-						Values: [][]any{{true}},
-						Update: []string{"checksumpicture='" + pic.ChecksumPicture + "'"},
-					}
-					_, n, err := did.Update("pictures", update)
+					n, err := markPictureDelete(did, pic.ChecksumPicture, "similar entry of "+title, "")
 					if err != nil {
 						fmt.Println("Error mark delete", n, ":", err)
 						fmt.Println("Pic:", pic.ChecksumPicture)
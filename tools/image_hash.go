@@ -26,13 +26,16 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
-	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 
 	"github.com/tknie/bitgartentools/sql"
 	"github.com/tknie/bitgartentools/store"
+	"github.com/tknie/services"
 
 	"github.com/corona10/goimagehash"
 	"github.com/tknie/flynn/common"
@@ -41,13 +44,16 @@ import (
 )
 
 var DefaultHash = 1
-var Hashes = []string{"averageHash", "perceptHash", "diffHash", "waveletHash"}
+var Hashes = []string{"averageHash", "perceptHash", "diffHash", "waveletHash", "orientHash"}
 
 const searchHash = `{{if not .Deleted -}} markdelete = false AND {{end}}
-mimetype LIKE 'image/%' {{.Filter}}
-AND NOT EXISTS(SELECT 1 FROM picturehash ph 
-	WHERE ph.checksumpicture = tn.checksumpicture 
-	{{if not .All -}}AND ph.updated_at < current_date + interval '1 week'{{end}})`
+mimetype LIKE 'image/%' {{.Filter}} {{if .Thumbnail -}} AND thumbnail IS NOT NULL {{end}}
+{{- if not .All}}
+AND ({{range $i, $k := .Kinds}}{{if $i}} OR {{end}}NOT EXISTS(SELECT 1 FROM picturehash ph 
+	WHERE ph.checksumpicture = tn.checksumpicture AND ph.kind = {{$k}}
+	AND ph.version = '{{$.Version}}'
+	{{- if not $.Thumbnail}} AND (ph.source IS NULL OR ph.source <> 'thumbnail' OR tn.media IS NULL){{end}}){{end}})
+{{- end}}`
 
 type hashData struct {
 	Checksumpicture string
@@ -55,19 +61,55 @@ type hashData struct {
 	Averagehash     uint64
 	PerceptionHash  uint64
 	DifferenceHash  uint64
-	Kind            byte
+	Exthash         string
+	Kind            int
+	Algorithm       string
+	Version         string
+	Source          string
 }
 
+// Sources of the image the hashes are generated from
+const (
+	HashSourceMedia     = "media"
+	HashSourceThumbnail = "thumbnail"
+)
+
+// DefaultHashWorkers number of workers decoding and hashing pictures in parallel
+const DefaultHashWorkers = 4
+
+// DefaultHashBatch number of pictures inserted in one transaction
+const DefaultHashBatch = 100
+
+// statistic output interval of the image hash
+const hashStatInterval = 60 * time.Second
+
 type ImageHashParameter struct {
 	Limit     int
 	PreFilter string
 	Deleted   bool
 	All       bool
 	HashType  string
+	Source    string
+	Workers   int
+	BatchSize int
+	Verify    int
 	Commit    bool
 	Json      bool
 }
 
+type hashResult struct {
+	pic    *store.Pictures
+	hashes []*hashData
+}
+
+type hashStat struct {
+	start    time.Time
+	found    uint64
+	hashed   uint64
+	failed   uint64
+	inserted uint64
+}
+
 var hashOutput func(pic *store.Pictures, output string)
 var infoMap map[string]any
 
@@ -77,14 +119,42 @@ func InitHash(outFct func(pic *store.Pictures, output string),
 	infoMap = resultMap
 }
 
+func (stat *hashStat) String() string {
+	duration := time.Since(stat.start)
+	rate := float64(atomic.LoadUint64(&stat.hashed)) / duration.Seconds()
+	return fmt.Sprintf("Found %d pictures where %d pictures are hashed, %d failed and %d inserted in %v (%.1f pictures/s)",
+		atomic.LoadUint64(&stat.found), atomic.LoadUint64(&stat.hashed), atomic.LoadUint64(&stat.failed),
+		stat.inserted, duration.Round(time.Second), rate)
+}
+
+// ImageHash generate the hashes of all pictures. The pictures are decoded and
+// hashed by a pool of workers, the hashes are inserted by one writer in batches.
 func ImageHash(parameter *ImageHashParameter) error {
 
 	if parameter.PreFilter != "" {
 		parameter.PreFilter = fmt.Sprintf(" AND LOWER(title) LIKE '%s%%'", parameter.PreFilter)
 	}
+	if parameter.Workers <= 0 {
+		parameter.Workers = DefaultHashWorkers
+	}
+	if parameter.BatchSize <= 0 {
+		parameter.BatchSize = DefaultHashBatch
+	}
+	if parameter.Source == "" {
+		parameter.Source = HashSourceMedia
+	}
+	if parameter.Source != HashSourceMedia && parameter.Source != HashSourceThumbnail {
+		return fmt.Errorf("unknown hash source %s, valid are %s and %s", parameter.Source,
+			HashSourceMedia, HashSourceThumbnail)
+	}
 
-	if !slices.Contains(Hashes, parameter.HashType) {
-		return fmt.Errorf("incorrect hash parameter given: %s not in %v", parameter.HashType, Hashes)
+	families, err := ParseHashSet(parameter.HashType)
+	if err != nil {
+		return err
+	}
+	kinds := make([]int, 0, len(families))
+	for _, f := range families {
+		kinds = append(kinds, f.Kind())
 	}
 
 	// Prepare template
@@ -92,19 +162,30 @@ func ImageHash(parameter *ImageHashParameter) error {
 	t1 = template.Must(t1.Parse(searchHash))
 	var sqlCmd bytes.Buffer
 	t1.Execute(&sqlCmd, struct {
-		Deleted bool
-		All     bool
-		Filter  string
-	}{parameter.Deleted, parameter.All, parameter.PreFilter})
+		Deleted   bool
+		All       bool
+		Filter    string
+		Thumbnail bool
+		Kinds     []int
+		Version   string
+	}{parameter.Deleted, parameter.All, parameter.PreFilter,
+		parameter.Source == HashSourceThumbnail, kinds, HashVersion})
 
 	id, err := sql.DatabaseHandler()
 	if err != nil {
 		return fmt.Errorf("POSTGRES error: %v", err)
 	}
+	defer id.FreeHandler()
+	wid, err := sql.DatabaseHandler()
+	if err != nil {
+		return fmt.Errorf("POSTGRES error: %v", err)
+	}
+	defer wid.FreeHandler()
+
 	log.Log.Debugf("Execute query:\n%s\n", sqlCmd.String())
 	query := &common.Query{
 		TableName:  "pictures",
-		Fields:     []string{"ChecksumPicture", "title", "mimetype", "media"},
+		Fields:     []string{"ChecksumPicture", "title", "mimetype", parameter.Source},
 		DataStruct: &store.Pictures{},
 		Limit:      strconv.Itoa(parameter.Limit),
 		Search:     sqlCmd.String(),
@@ -112,159 +193,245 @@ func ImageHash(parameter *ImageHashParameter) error {
 	if parameter.Json {
 		fmt.Printf("\"Hash\":[")
 	}
-	counter := uint64(0)
-	processed := uint64(0)
-	_, err = id.Query(query, func(search *common.Query, result *common.Result) error {
-		counter++
-		p := result.Data.(*store.Pictures)
-		buffer := bytes.NewBuffer(p.Media)
-		var hd *hashData
-		switch strings.ToLower(p.MIMEType) {
-		case "image/heic":
-			hd, err = hashHeic(buffer)
-			if err != nil {
-				hashOutput(p, fmt.Sprintf("Error generating hash for %s/%s: %v\n", p.Title, p.ChecksumPicture, err))
-				log.Log.Errorf("Error generating hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
-				return nil
-			}
-		case "image/jpeg", "image/jpg":
-			hd, err = hashJpeg(buffer)
-			if err != nil {
-				hashOutput(p, fmt.Sprintf("Error generating hash for %s/%s: %v\n", p.Title, p.ChecksumPicture, err))
-				log.Log.Errorf("Error generating hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
-				return nil
-			}
-		case "image/png":
-			hd, err = hashPng(buffer)
-			if err != nil {
-				hashOutput(p, fmt.Sprintf("Error generating hash for %s/%s: %v\n", p.Title, p.ChecksumPicture, err))
-				log.Log.Errorf("Error generating hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
-				return nil
-			}
-		case "image/gif":
-			hd, err = hashGif(buffer)
-			if err != nil {
-				hashOutput(p, fmt.Sprintf("Error generating hash for %s/%s: %v\n", p.Title, p.ChecksumPicture, err))
-				log.Log.Errorf("Error generating hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
-				return nil
-			}
-		default:
-			hashOutput(p, fmt.Sprintf("Error unknown image format for %s/%s: %s\n", p.Title, p.ChecksumPicture, p.MIMEType))
-			log.Log.Errorf("Error unknown image format for %s/%s: %s\n", p.Title, p.ChecksumPicture, p.MIMEType)
-			return nil
-		}
-		hd.Checksumpicture = p.ChecksumPicture
-		hd.Hash = hd.PerceptionHash
-		if parameter.Json {
-			if counter > 1 {
-				fmt.Printf(",")
+	stat := &hashStat{start: time.Now()}
+	jobs := make(chan *store.Pictures, parameter.Workers*2)
+	results := make(chan *hashResult, parameter.Workers*2)
+	var wgHash sync.WaitGroup
+	for range parameter.Workers {
+		wgHash.Add(1)
+		go func() {
+			defer wgHash.Done()
+			for p := range jobs {
+				hd := hashPicture(p, families, parameter.Source)
+				if hd == nil {
+					atomic.AddUint64(&stat.failed, 1)
+					continue
+				}
+				atomic.AddUint64(&stat.hashed, 1)
+				// release media, only title and checksum are needed by the writer
+				p.Media = nil
+				p.Thumbnail = nil
+				results <- &hashResult{pic: p, hashes: hd}
 			}
-			fmt.Printf("{\"title\":\"%s\",\"checksumpicture\":\"%s\",\"hash\":\"%v\"}",
-				p.Title, hd.Checksumpicture, hd.PerceptionHash)
-		} else {
-			fmt.Printf("%s -> %s\n", p.Title, hd.Checksumpicture)
-		}
-		if parameter.Commit {
-			err = insertHash(p, hd)
-			if err == nil {
-				processed++
-			}
-		}
+		}()
+	}
+	go func() {
+		wgHash.Wait()
+		close(results)
+	}()
+	writerDone := make(chan bool)
+	go func() {
+		hashWriter(wid, parameter, stat, results)
+		writerDone <- true
+	}()
+
+	_, err = id.Query(query, func(search *common.Query, result *common.Result) error {
+		atomic.AddUint64(&stat.found, 1)
+		p := &store.Pictures{}
+		*p = *result.Data.(*store.Pictures)
+		jobs <- p
 		return nil
 	})
+	close(jobs)
+	<-writerDone
 	if err != nil {
 		return fmt.Errorf("query error: %v", err)
 	}
-	hashOutput(nil, fmt.Sprintf("Found %d pictures where %d pictures are hashed", counter, processed))
 	if parameter.Json {
 		fmt.Printf("],")
 	}
+	hashOutput(nil, stat.String())
 
 	return nil
 }
 
-func insertHash(p *store.Pictures, ph *hashData) error {
-	wid, err := sql.DatabaseHandler()
+// hashPicture decode the picture or its thumbnail and generate the hashes,
+// errors are reported and nil is returned
+func hashPicture(p *store.Pictures, families []*HashFamily, source string) []*hashData {
+	var hd []*hashData
+	var err error
+	switch {
+	case source == HashSourceThumbnail:
+		// thumbnails are always stored as JPEG
+		hd, err = hashJpeg(bytes.NewBuffer(p.Thumbnail), families)
+	default:
+		hd, err = hashMedia(p, bytes.NewBuffer(p.Media), families)
+	}
 	if err != nil {
-		fmt.Println("POSTGRES error", err)
+		hashOutput(p, fmt.Sprintf("Error generating hash for %s/%s: %v\n", p.Title, p.ChecksumPicture, err))
+		log.Log.Errorf("Error generating hash for %s/%s: %v", p.Title, p.ChecksumPicture, err)
 		return nil
 	}
-	insert := &common.Entries{
-		Fields:     []string{"checksumpicture", "hash", "averagehash", "perceptionHash", "differenceHash", "kind"},
-		DataStruct: ph,
-		// Values:     [][]any{{p.ChecksumPicture, h.GetHash(), byte(h.GetKind())}},
-		Values: [][]any{{ph}},
+	for _, h := range hd {
+		h.Checksumpicture = p.ChecksumPicture
+		h.Source = source
 	}
-	_, err = wid.Insert("picturehash", insert)
-	if err != nil {
-		fmt.Printf("Error inserting %s/%s: %v\n", p.Title, p.ChecksumPicture, err)
-		return err
+	return hd
+}
+
+// hashMedia decode the original media dependent on the MIME type
+func hashMedia(p *store.Pictures, buffer io.Reader, families []*HashFamily) ([]*hashData, error) {
+	switch strings.ToLower(p.MIMEType) {
+	case "image/heic":
+		return hashHeic(buffer, families)
+	case "image/jpeg", "image/jpg":
+		return hashJpeg(buffer, families)
+	case "image/png":
+		return hashPng(buffer, families)
+	case "image/gif":
+		return hashGif(buffer, families)
 	}
-	return nil
+	return nil, fmt.Errorf("unknown image format %s", p.MIMEType)
 }
 
-func hashHeic(f io.Reader) (*hashData, error) {
-	i, err := goheif.Decode(f)
-	if err != nil {
-		return nil, err
+// hashWriter output the hashed pictures and insert the hashes in batches,
+// it is the only user of the writer handle and the output
+func hashWriter(wid common.RegDbID, parameter *ImageHashParameter, stat *hashStat, results chan *hashResult) {
+	ticker := time.NewTicker(hashStatInterval)
+	defer ticker.Stop()
+	batch := make([]*hashResult, 0, parameter.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if parameter.Commit {
+			err := insertHash(wid, batch)
+			if err == nil {
+				stat.inserted += uint64(len(batch))
+			}
+		}
+		batch = batch[:0]
+	}
+	written := 0
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				flush()
+				return
+			}
+			written++
+			if parameter.Json {
+				if written > 1 {
+					fmt.Printf(",")
+				}
+				fmt.Printf("{\"title\":\"%s\",\"checksumpicture\":\"%s\",\"hash\":\"%v\"}",
+					r.pic.Title, r.pic.ChecksumPicture, r.hashes[0].Hash)
+			} else {
+				fmt.Printf("%s -> %s\n", r.pic.Title, r.pic.ChecksumPicture)
+			}
+			batch = append(batch, r)
+			if len(batch) >= parameter.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			if !parameter.Json {
+				services.ServerMessage("Progress: %s", stat.String())
+			}
+		}
 	}
-	return generateHash(i)
 }
 
-func generateHash(i image.Image) (*hashData, error) {
-	ph := &hashData{}
-	h, err := hash(i, Hashes[0])
+// insertHash replace the hashes of a batch of pictures in one transaction,
+// each hash family is one row identified by its kind
+func insertHash(wid common.RegDbID, batch []*hashResult) error {
+	keys := make([]string, 0, len(batch))
+	values := make([][]any, 0, len(batch))
+	for _, r := range batch {
+		for _, ph := range r.hashes {
+			keys = append(keys, fmt.Sprintf("('%s',%d)", ph.Checksumpicture, ph.Kind))
+			values = append(values, []any{ph})
+		}
+	}
+	err := wid.BeginTransaction()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	ph.Averagehash = h.GetHash()
-	h, err = hash(i, Hashes[1])
+	_, err = wid.Delete("picturehash", &common.Entries{
+		Criteria: fmt.Sprintf("(checksumpicture, kind) IN (%s)", strings.Join(keys, ","))})
 	if err != nil {
-		return nil, err
+		wid.Rollback()
+		fmt.Printf("Error removing old hashes of %d pictures: %v\n", len(batch), err)
+		return err
+	}
+	insert := &common.Entries{
+		Fields: []string{"checksumpicture", "hash", "averagehash", "perceptionHash", "differenceHash", "exthash", "kind",
+			"algorithm", "version", "source"},
+		DataStruct: &hashData{},
+		Values:     values,
 	}
-	ph.PerceptionHash = h.GetHash()
-	h, err = hash(i, Hashes[2])
+	_, err = wid.Insert("picturehash", insert)
+	if err != nil {
+		wid.Rollback()
+		fmt.Printf("Error inserting hashes of %d pictures: %v\n", len(batch), err)
+		return err
+	}
+	return wid.Commit()
+}
+
+func hashHeic(f io.Reader, families []*HashFamily) ([]*hashData, error) {
+	i, err := goheif.Decode(f)
 	if err != nil {
 		return nil, err
 	}
-	ph.DifferenceHash = h.GetHash()
-	return ph, nil
+	return generateHash(i, families)
 }
 
-func hash(i image.Image, hType string) (*goimagehash.ImageHash, error) {
-	switch hType {
-	case Hashes[0]:
-		return goimagehash.AverageHash(i)
-	case Hashes[1]:
-		return goimagehash.PerceptionHash(i)
-	case Hashes[2]:
-		return goimagehash.DifferenceHash(i)
-	case Hashes[3]:
-		fmt.Println("Wavelet not yet support by system")
+// generateHash calculate all hash families of the image. The standard
+// family contains average, perception and difference hash.
+func generateHash(i image.Image, families []*HashFamily) ([]*hashData, error) {
+	phs := make([]*hashData, 0, len(families))
+	for _, f := range families {
+		words, err := f.Compute(i)
+		if err != nil {
+			return nil, err
+		}
+		ph := &hashData{Hash: words[0], Kind: f.Kind(), Algorithm: f.String(), Version: HashVersion}
+		if len(words) > 1 {
+			ph.Exthash = HashHex(words)
+		}
+		if f.Algorithm == OrientationAlgorithm {
+			// rotation and mirror invariant descriptor
+			ph.Hash, _ = CanonicalHash(words)
+		}
+		if f.Standard() {
+			ph.PerceptionHash = words[0]
+			h, err := goimagehash.AverageHash(i)
+			if err != nil {
+				return nil, err
+			}
+			ph.Averagehash = h.GetHash()
+			h, err = goimagehash.DifferenceHash(i)
+			if err != nil {
+				return nil, err
+			}
+			ph.DifferenceHash = h.GetHash()
+		}
+		phs = append(phs, ph)
 	}
-	return nil, fmt.Errorf("unknown hashType")
+	return phs, nil
 }
 
-func hashJpeg(f io.Reader) (*hashData, error) {
+func hashJpeg(f io.Reader, families []*HashFamily) ([]*hashData, error) {
 	i, err := jpeg.Decode(f)
 	if err != nil {
 		return nil, err
 	}
-	return generateHash(i)
+	return generateHash(i, families)
 }
 
-func hashPng(f io.Reader) (*hashData, error) {
+func hashPng(f io.Reader, families []*HashFamily) ([]*hashData, error) {
 	i, err := png.Decode(f)
 	if err != nil {
 		return nil, err
 	}
-	return generateHash(i)
+	return generateHash(i, families)
 }
 
-func hashGif(f io.Reader) (*hashData, error) {
+func hashGif(f io.Reader, families []*HashFamily) ([]*hashData, error) {
 	i, err := gif.Decode(f)
 	if err != nil {
 		return nil, err
 	}
-	return generateHash(i)
+	return generateHash(i, families)
 }
@@ -0,0 +1,274 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tknie/bitgartentools"
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/services"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const readJournal = `
+SELECT id::text AS id, runid, checksumpicture, previous, COALESCE(tags, '') AS tags,
+  COALESCE(moved, '') AS moved
+  FROM markdeletejournal
+  WHERE restored IS NULL
+  {{if .RunID -}} AND runid = '{{.RunID}}' {{end}}
+  {{if .Checksums -}} AND checksumpicture IN ({{range $i, $c := .Checksums}}{{if $i}},{{end}}'{{$c}}'{{end}}) {{end}}
+  ORDER BY id DESC
+`
+
+const readJournalRuns = `
+SELECT runid, tool, count(*)::int4 AS pictures, count(restored)::int4 AS restored,
+  to_char(min(created), 'YYYY-MM-DD HH24:MI:SS') AS started
+  FROM markdeletejournal
+  GROUP BY runid, tool
+  ORDER BY min(created) DESC
+  {{if gt . 0 -}} LIMIT {{.}} {{end}}
+`
+
+type RestoreParameter struct {
+	RunID     string
+	Checksums []string
+	Commit    bool
+}
+
+// JournalEntry one mark delete change of a picture
+type JournalEntry struct {
+	Id              string
+	Runid           string
+	Checksumpicture string
+	Previous        bool
+	Tags            string
+	Moved           string
+}
+
+// JournalRun summary of all changes of one run
+type JournalRun struct {
+	Runid    string
+	Tool     string
+	Pictures int
+	Restored int
+	Started  string
+}
+
+// markPictureDelete mark the picture deleted and record the previous state,
+// the reason and the removed tags in the journal. The journal entry is
+// written in the transaction of the handler.
+func markPictureDelete(id common.RegDbID, checksumpicture, reason, tags string) (int64, error) {
+	return markDuplicateDelete(id, checksumpicture, reason, tags, nil)
+}
+
+// markDuplicateDelete mark the duplicate deleted like markPictureDelete,
+// the references moved onto the kept picture are recorded in the journal
+// as well
+func markDuplicateDelete(id common.RegDbID, checksumpicture, reason, tags string, moved *movedReferences) (int64, error) {
+	services.ServerMessage("Need to mark delete -> %v", checksumpicture)
+	previous := false
+	query := &common.Query{
+		TableName: "pictures",
+		Fields:    []string{"markdelete"},
+		Search:    "checksumpicture='" + checksumpicture + "'",
+	}
+	_, err := id.Query(query, func(search *common.Query, result *common.Result) error {
+		previous = result.Rows[0].(bool)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	input := &common.Entries{
+		Fields: []string{"markdelete"},
+		Update: []string{"checksumpicture='" + checksumpicture + "'"},
+		Values: [][]any{{true}},
+	}
+	_, ra, err := id.Update("pictures", input)
+	if err != nil {
+		return 0, err
+	}
+	journal := &common.Entries{
+		Fields: []string{"runid", "tool", "reason", "checksumpicture", "previous", "tags"},
+		Values: [][]any{{bitgartentools.RunID, bitgartentools.ToolName, reason, checksumpicture, previous, tags}},
+	}
+	if moved != nil {
+		m, err := json.Marshal(moved)
+		if err != nil {
+			return 0, err
+		}
+		journal.Fields = append(journal.Fields, "moved")
+		journal.Values[0] = append(journal.Values[0], string(m))
+	}
+	_, err = id.Insert("markdeletejournal", journal)
+	if err != nil {
+		log.Log.Errorf("Error writing mark delete journal of %s: %v", checksumpicture, err)
+		return 0, err
+	}
+	return ra, nil
+}
+
+// JournalRuns list the last runs recorded in the journal
+func JournalRuns(limit int) ([]*JournalRun, error) {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return nil, err
+	}
+	defer id.FreeHandler()
+
+	sqlCmd, err := templateSql(readJournalRuns, limit)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*JournalRun, 0)
+	query := &common.Query{
+		TableName:  "markdeletejournal",
+		DataStruct: &JournalRun{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		run := &JournalRun{}
+		*run = *result.Data.(*JournalRun)
+		runs = append(runs, run)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// splitTags split the quoted tag list of the journal
+func splitTags(tags string) []string {
+	list := make([]string, 0)
+	for _, t := range strings.Split(tags, ",") {
+		t = strings.Trim(t, "' ")
+		if t != "" {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// Restore undo the mark delete of a whole run or of selected pictures,
+// the previous state, the removed tags and the references moved onto the
+// kept picture are restored
+func Restore(parameter *RestoreParameter) error {
+	if parameter.RunID == "" && len(parameter.Checksums) == 0 {
+		return fmt.Errorf("run id or pictures to restore needed")
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	sqlCmd, err := templateSql(readJournal, parameter)
+	if err != nil {
+		return err
+	}
+	entries := make([]*JournalEntry, 0)
+	query := &common.Query{
+		TableName:  "markdeletejournal",
+		DataStruct: &JournalEntry{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		e := &JournalEntry{}
+		*e = *result.Data.(*JournalEntry)
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error reading journal:", err)
+		return err
+	}
+
+	err = id.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	// entries are in reverse order, the oldest previous state is restored last
+	for _, e := range entries {
+		fmt.Printf("Restore %s of run %s markdelete=%v tags=%s\n", e.Checksumpicture, e.Runid, e.Previous, e.Tags)
+		_, _, err = id.Update("pictures", &common.Entries{
+			Fields: []string{"markdelete"},
+			Update: []string{"checksumpicture='" + e.Checksumpicture + "'"},
+			Values: [][]any{{e.Previous}},
+		})
+		if err != nil {
+			id.Rollback()
+			fmt.Println("Error restoring picture:", err)
+			return err
+		}
+		tags := splitTags(e.Tags)
+		if len(tags) > 0 {
+			list := make([][]any, 0, len(tags))
+			for _, t := range tags {
+				list = append(list, []any{t, e.Checksumpicture})
+			}
+			_, err = id.Insert("picturetags", &common.Entries{
+				Fields: []string{"tagname", "checksumpicture"},
+				Values: list,
+			})
+			if err != nil {
+				id.Rollback()
+				fmt.Println("Error restoring tags:", err)
+				return err
+			}
+		}
+		if e.Moved != "" {
+			moved := &movedReferences{}
+			err = json.Unmarshal([]byte(e.Moved), moved)
+			if err == nil {
+				err = restoreReferences(id, e.Checksumpicture, moved)
+			}
+			if err != nil {
+				id.Rollback()
+				fmt.Println("Error restoring references:", err)
+				return err
+			}
+		}
+		_, _, err = id.Update("markdeletejournal", &common.Entries{
+			Fields: []string{"restored"},
+			Update: []string{"id = " + e.Id},
+			Values: [][]any{{time.Now()}},
+		})
+		if err != nil {
+			id.Rollback()
+			fmt.Println("Error updating journal:", err)
+			return err
+		}
+	}
+	if !parameter.Commit {
+		services.ServerMessage("Dry run, %d journal entries would be restored", len(entries))
+		return id.Rollback()
+	}
+	services.ServerMessage("Restored %d journal entries", len(entries))
+	return id.Commit()
+}
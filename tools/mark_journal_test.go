@@ -0,0 +1,58 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTags(t *testing.T) {
+	assert.Equal(t, []string{"holiday", "family"}, splitTags("'holiday','family'"))
+	assert.Len(t, splitTags(""), 0)
+}
+
+func TestJournalTemplate(t *testing.T) {
+	sqlCmd, err := templateSql(readJournal, &RestoreParameter{RunID: "hashclean-1", Checksums: []string{"A", "B"}})
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(sqlCmd, "runid = 'hashclean-1'"))
+	assert.True(t, strings.Contains(sqlCmd, "checksumpicture IN ('A','B')"))
+	sqlCmd, err = templateSql(readJournal, &RestoreParameter{RunID: "hashclean-1"})
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(sqlCmd, "checksumpicture IN"))
+}
+
+func TestMovedReferencesJournal(t *testing.T) {
+	moved := &movedReferences{Keep: "K",
+		Albums: []*movedAlbumEntry{{Albumid: 3, Index: 7, Name: "n", Height: 10, Width: 20},
+			{Albumid: 4, Index: 1, Removed: true}},
+		Thumbnails: []int{3},
+		Locations:  []*movedLocation{{Picturename: "a'b.jpg", Picturehost: "h", Picturedirectory: "/d"}}}
+	m, err := json.Marshal(moved)
+	assert.NoError(t, err)
+	restored := &movedReferences{}
+	assert.NoError(t, json.Unmarshal(m, restored))
+	assert.Equal(t, moved, restored)
+	assert.Equal(t, "'a''b.jpg'", sqlQuote(restored.Locations[0].Picturename))
+	assert.Equal(t, "'holiday'", quoteTag("'holiday'"))
+}
@@ -0,0 +1,293 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/services"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const readNearHashs = `
+SELECT ph.checksumpicture, ph.averagehash::text AS averagehash,
+  ph.perceptionhash::text AS perceptionhash, ph.differencehash::text AS differencehash
+  FROM picturehash ph
+  WHERE ph.kind = {{.}} AND ph.perceptionhash <> 0 AND EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = ph.checksumpicture::text AND pp.markdelete = false)
+`
+
+const readPictureByChecksums = `
+select checksumpicture, title, height, width, Exifxdimension, Exifydimension,
+COALESCE(quality, 0) AS quality, COALESCE(exifmodel, '') AS exifmodel, COALESCE(picopt, '') AS picopt, COALESCE(mimetype, '') AS mimetype,
+( SELECT count(*)::int4 FROM albumpictures ap WHERE ap.checksumpicture::text = p.checksumpicture::text) AS albums,
+( SELECT string_agg(DISTINCT (''''::text || pt.tagname::text) || ''''::text, ','::text) AS string_agg
+           FROM picturetags pt
+          WHERE pt.checksumpicture::text = p.checksumpicture::text) AS tags
+from pictures p where markdelete = false and checksumpicture IN ({{range $i, $c := .}}{{if $i}},{{end}}'{{$c}}'{{end}});
+`
+
+// HashEntry hashes of one picture used to search near-duplicates
+type HashEntry struct {
+	Checksumpicture string
+	Average         uint64
+	Perception      uint64
+	Difference      uint64
+}
+
+type nearHash struct {
+	Checksumpicture string
+	Averagehash     string
+	Perceptionhash  string
+	Differencehash  string
+}
+
+// BKTree Burkhard-Keller tree indexing 64-bit hashes by Hamming distance
+type BKTree struct {
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     uint64
+	items    []int
+	children map[int]*bkNode
+}
+
+// HammingDistance number of different bits of two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Add add the item reference with the given hash
+func (tree *BKTree) Add(hash uint64, item int) {
+	tree.size++
+	if tree.root == nil {
+		tree.root = &bkNode{hash: hash, items: []int{item}}
+		return
+	}
+	node := tree.root
+	for {
+		d := HammingDistance(node.hash, hash)
+		if d == 0 {
+			node.items = append(node.items, item)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{hash: hash, items: []int{item}}
+			return
+		}
+		node = child
+	}
+}
+
+// Size number of items in the tree
+func (tree *BKTree) Size() int {
+	return tree.size
+}
+
+// Search all items with hash within the maximal Hamming distance
+func (tree *BKTree) Search(hash uint64, maxDistance int) []int {
+	result := make([]int, 0)
+	if tree.root == nil {
+		return result
+	}
+	stack := []*bkNode{tree.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := HammingDistance(node.hash, hash)
+		if d <= maxDistance {
+			result = append(result, node.items...)
+		}
+		for cd, child := range node.children {
+			if cd >= d-maxDistance && cd <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return result
+}
+
+// nearDuplicate check if average, perception and difference hash of both
+// entries are within the maximal Hamming distance
+func nearDuplicate(e, o *HashEntry, maxDistance int) bool {
+	return HammingDistance(e.Perception, o.Perception) <= maxDistance &&
+		HammingDistance(e.Average, o.Average) <= maxDistance &&
+		HammingDistance(e.Difference, o.Difference) <= maxDistance
+}
+
+// NearDuplicateClusters group all entries where average, perception and
+// difference hash are all within the maximal Hamming distance. Clusters use
+// complete linkage: each member is near to all other members of the cluster,
+// so a chain of similar pictures is not merged into one cluster.
+func NearDuplicateClusters(entries []*HashEntry, maxDistance int) [][]*HashEntry {
+	tree := &BKTree{}
+	for i, e := range entries {
+		tree.Add(e.Perception, i)
+	}
+	cluster := make([]int, len(entries))
+	for i := range cluster {
+		cluster[i] = -1
+	}
+	for i, e := range entries {
+		if cluster[i] != -1 {
+			continue
+		}
+		cluster[i] = i
+		candidates := tree.Search(e.Perception, maxDistance)
+		sort.Slice(candidates, func(x, y int) bool {
+			dx := HammingDistance(e.Perception, entries[candidates[x]].Perception)
+			dy := HammingDistance(e.Perception, entries[candidates[y]].Perception)
+			if dx != dy {
+				return dx < dy
+			}
+			return candidates[x] < candidates[y]
+		})
+		members := []int{i}
+		for _, j := range candidates {
+			if cluster[j] != -1 {
+				continue
+			}
+			near := true
+			for _, m := range members {
+				if !nearDuplicate(entries[m], entries[j], maxDistance) {
+					near = false
+					break
+				}
+			}
+			if near {
+				cluster[j] = i
+				members = append(members, j)
+			}
+		}
+	}
+	groups := make(map[int][]*HashEntry)
+	for i, e := range entries {
+		groups[cluster[i]] = append(groups[cluster[i]], e)
+	}
+	clusters := make([][]*HashEntry, 0)
+	for _, g := range groups {
+		if len(g) > 1 {
+			sort.Slice(g, func(x, y int) bool { return g[x].Checksumpicture < g[y].Checksumpicture })
+			clusters = append(clusters, g)
+		}
+	}
+	sort.Slice(clusters, func(x, y int) bool {
+		if len(clusters[x]) != len(clusters[y]) {
+			return len(clusters[x]) > len(clusters[y])
+		}
+		return clusters[x][0].Checksumpicture < clusters[y][0].Checksumpicture
+	})
+	return clusters
+}
+
+// readHashEntries read hashes of all pictures not marked deleted
+func readHashEntries(id common.RegDbID) ([]*HashEntry, error) {
+	sqlCmd, err := templateSql(readNearHashs, StandardHashKind)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*HashEntry, 0)
+	query := &common.Query{
+		TableName:  "picturehash",
+		DataStruct: &nearHash{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		nh := result.Data.(*nearHash)
+		e := &HashEntry{Checksumpicture: nh.Checksumpicture}
+		var err error
+		for _, v := range []struct {
+			s string
+			h *uint64
+		}{{nh.Averagehash, &e.Average}, {nh.Perceptionhash, &e.Perception}, {nh.Differencehash, &e.Difference}} {
+			*v.h, err = strconv.ParseUint(v.s, 10, 64)
+			if err != nil {
+				log.Log.Errorf("Error parsing hash %s of %s: %v", v.s, nh.Checksumpicture, err)
+				return nil
+			}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// nearDuplicateClean search clusters of near-duplicates and resolve them
+// like exact hash duplicates
+func (parameter *HashCleanParameter) nearDuplicateClean() error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	entries, err := readHashEntries(id)
+	if err != nil {
+		fmt.Println("Error query hashes:", err)
+		return err
+	}
+	clusters := NearDuplicateClusters(entries, parameter.Distance)
+	services.ServerMessage("Found %d near-duplicate clusters in %d hashes with distance %d",
+		len(clusters), len(entries), parameter.Distance)
+	for i, cluster := range clusters {
+		if parameter.Limit > 0 && i >= parameter.Limit {
+			break
+		}
+		if len(cluster) <= parameter.MinCount {
+			break
+		}
+		checksums := make([]string, 0, len(cluster))
+		for _, e := range cluster {
+			checksums = append(checksums, e.Checksumpicture)
+		}
+		fmt.Printf("Working on %d.Cluster with %d pictures: %s\n", i+1, len(cluster), strings.Join(checksums, ","))
+		sqlCmd, err := templateSql(readPictureByChecksums, checksums)
+		if err != nil {
+			return err
+		}
+		picturesByHash, err := readPicturesByHash(id, sqlCmd)
+		if err != nil {
+			return err
+		}
+		err = parameter.resolvePictures(fmt.Sprintf("cluster %d", i+1), picturesByHash)
+		if err != nil {
+			fmt.Println("Error resolving cluster:", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBKTreeSearch(t *testing.T) {
+	tree := &BKTree{}
+	hashes := []uint64{0x0, 0x1, 0x3, 0xFF, 0xFFFF, 0x1}
+	for i, h := range hashes {
+		tree.Add(h, i)
+	}
+	assert.Equal(t, 6, tree.Size())
+	found := tree.Search(0x0, 1)
+	sort.Ints(found)
+	assert.Equal(t, []int{0, 1, 5}, found)
+	found = tree.Search(0x0, 2)
+	sort.Ints(found)
+	assert.Equal(t, []int{0, 1, 2, 5}, found)
+	assert.Len(t, tree.Search(0xFFFF0000, 3), 0)
+}
+
+func TestNearDuplicateClusters(t *testing.T) {
+	entries := []*HashEntry{
+		{Checksumpicture: "A", Average: 0xF0, Perception: 0xFF00, Difference: 0x0F},
+		{Checksumpicture: "B", Average: 0xF1, Perception: 0xFF01, Difference: 0x0F},
+		{Checksumpicture: "C", Average: 0xF3, Perception: 0xFF03, Difference: 0x0E},
+		{Checksumpicture: "D", Average: 0xF0, Perception: 0xFF00, Difference: 0xFFFF0000},
+		{Checksumpicture: "E", Average: 0x1234, Perception: 0xABCDEF, Difference: 0x5678},
+	}
+	clusters := NearDuplicateClusters(entries, 2)
+	assert.Len(t, clusters, 1)
+	checksums := make([]string, 0)
+	for _, e := range clusters[0] {
+		checksums = append(checksums, e.Checksumpicture)
+	}
+	// D differs in difference hash, E is not similar at all
+	assert.Equal(t, []string{"A", "B", "C"}, checksums)
+	assert.Len(t, NearDuplicateClusters(entries, 0), 0)
+}
+
+func TestNearDuplicateClustersChain(t *testing.T) {
+	// A-B and B-C are near, A-C is not, the chain must not be one cluster
+	entries := []*HashEntry{
+		{Checksumpicture: "A", Average: 0x0, Perception: 0x0, Difference: 0x0},
+		{Checksumpicture: "B", Average: 0x3, Perception: 0x3, Difference: 0x3},
+		{Checksumpicture: "C", Average: 0xF, Perception: 0xF, Difference: 0xF},
+	}
+	clusters := NearDuplicateClusters(entries, 2)
+	assert.Len(t, clusters, 1)
+	checksums := make([]string, 0)
+	for _, e := range clusters[0] {
+		checksums = append(checksums, e.Checksumpicture)
+	}
+	assert.Equal(t, []string{"A", "B"}, checksums)
+	for _, c := range clusters {
+		for _, e := range c {
+			for _, o := range c {
+				assert.True(t, nearDuplicate(e, o, 2))
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/services"
+
+	"github.com/corona10/goimagehash"
+	"github.com/nfnt/resize"
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// OrientationTransforms number of rotations and mirrored rotations
+const OrientationTransforms = 8
+
+// size the image is scaled to before the transforms are applied
+const orientationSize = 64
+
+const readOrientationHashs = `
+SELECT ph.checksumpicture, ph.exthash
+  FROM picturehash ph
+  WHERE ph.kind = {{.}} AND ph.exthash IS NOT NULL AND EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = ph.checksumpicture::text AND pp.markdelete = false)
+`
+
+var transformNames = []string{"identity", "rotate90", "rotate180", "rotate270",
+	"mirror", "mirror+rotate90", "mirror+rotate180", "mirror+rotate270"}
+
+// OrientationEntry perception hashes of all transforms of one picture
+type OrientationEntry struct {
+	Checksumpicture string
+	Hashes          []uint64
+}
+
+// OrientationMatch picture To is the picture From with the transform applied
+type OrientationMatch struct {
+	From      string
+	To        string
+	Transform int
+	Distance  int
+}
+
+type orientationRow struct {
+	Checksumpicture string
+	Exthash         string
+}
+
+// TransformName name of the transform, the mirror is applied before
+// the clockwise rotation
+func TransformName(transform int) string {
+	if transform < 0 || transform >= len(transformNames) {
+		return "unknown"
+	}
+	return transformNames[transform]
+}
+
+// orientImage apply the transform to the square gray image, mirror
+// horizontal first and then rotate clockwise by 90 degrees per step
+func orientImage(src *image.Gray, transform int) *image.Gray {
+	n := src.Bounds().Dx()
+	dst := image.NewGray(image.Rect(0, 0, n, n))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			tx, ty := x, y
+			if transform >= 4 {
+				tx = n - 1 - tx
+			}
+			for r := 0; r < transform%4; r++ {
+				tx, ty = n-1-ty, tx
+			}
+			dst.SetGray(tx, ty, src.GrayAt(x, y))
+		}
+	}
+	return dst
+}
+
+// OrientationHashes perception hashes of all rotations and mirrored
+// rotations of the image, index is the transform
+func OrientationHashes(img image.Image) ([]uint64, error) {
+	small := resize.Resize(orientationSize, orientationSize, img, resize.Bilinear)
+	gray := image.NewGray(image.Rect(0, 0, orientationSize, orientationSize))
+	b := small.Bounds()
+	for y := 0; y < orientationSize; y++ {
+		for x := 0; x < orientationSize; x++ {
+			gray.Set(x, y, color.GrayModel.Convert(small.At(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+	hashes := make([]uint64, 0, OrientationTransforms)
+	for t := 0; t < OrientationTransforms; t++ {
+		h, err := goimagehash.PerceptionHash(orientImage(gray, t))
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h.GetHash())
+	}
+	return hashes, nil
+}
+
+// CanonicalHash smallest hash of all transforms, it is equal for all
+// rotated and mirrored copies. The transform of the smallest hash is returned, too.
+func CanonicalHash(hashes []uint64) (uint64, int) {
+	canonical := 0
+	for t, h := range hashes {
+		if h < hashes[canonical] {
+			canonical = t
+		}
+	}
+	return hashes[canonical], canonical
+}
+
+// orientationMatch direct match of picture o as transform of picture e,
+// the transform with the smallest distance within maxDistance is returned.
+// With identity false plain duplicates are no match.
+func orientationMatch(e, o *OrientationEntry, maxDistance int, identity bool) (*OrientationMatch, bool) {
+	var match *OrientationMatch
+	for t, h := range e.Hashes {
+		if t == 0 && !identity {
+			continue
+		}
+		d := HammingDistance(h, o.Hashes[0])
+		if d <= maxDistance && (match == nil || d < match.Distance) {
+			match = &OrientationMatch{From: e.Checksumpicture, To: o.Checksumpicture, Transform: t, Distance: d}
+		}
+	}
+	return match, match != nil
+}
+
+// directOrientation match of the pictures in either direction
+func directOrientation(e, o *OrientationEntry, maxDistance int) (*OrientationMatch, bool) {
+	if m, ok := orientationMatch(e, o, maxDistance, true); ok {
+		return m, true
+	}
+	return orientationMatch(o, e, maxDistance, true)
+}
+
+// OrientationClusters search pictures which are rotated or mirrored copies
+// of each other. Matches of the identity transform are plain duplicates and
+// left for the hash clean. Each cluster has a representative all members are
+// rotated or mirrored copies of, and all members match each other directly.
+// The matches relate each member to the representative.
+func OrientationClusters(entries []*OrientationEntry, maxDistance int) ([][]*OrientationEntry, []*OrientationMatch) {
+	tree := &BKTree{}
+	for i, e := range entries {
+		tree.Add(e.Hashes[0], i)
+	}
+	cluster := make([]int, len(entries))
+	for i := range cluster {
+		cluster[i] = -1
+	}
+	matches := make([]*OrientationMatch, 0)
+	for i, e := range entries {
+		if cluster[i] != -1 {
+			continue
+		}
+		cluster[i] = i
+		candidates := make([]*OrientationMatch, 0)
+		found := make(map[int]bool)
+		index := make(map[string]int)
+		for t := 1; t < len(e.Hashes); t++ {
+			for _, j := range tree.Search(e.Hashes[t], maxDistance) {
+				// symmetric pictures match themselves
+				if j == i || found[j] || cluster[j] != -1 {
+					continue
+				}
+				found[j] = true
+				if m, ok := orientationMatch(e, entries[j], maxDistance, false); ok {
+					index[entries[j].Checksumpicture] = j
+					candidates = append(candidates, m)
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(x, y int) bool {
+			if candidates[x].Distance != candidates[y].Distance {
+				return candidates[x].Distance < candidates[y].Distance
+			}
+			return candidates[x].To < candidates[y].To
+		})
+		members := []int{}
+		for _, m := range candidates {
+			j := index[m.To]
+			direct := true
+			for _, k := range members {
+				if _, ok := directOrientation(entries[k], entries[j], maxDistance); !ok {
+					direct = false
+					break
+				}
+			}
+			if direct {
+				cluster[j] = i
+				members = append(members, j)
+				matches = append(matches, m)
+			}
+		}
+	}
+	groups := make(map[int][]*OrientationEntry)
+	for i, e := range entries {
+		groups[cluster[i]] = append(groups[cluster[i]], e)
+	}
+	clusters := make([][]*OrientationEntry, 0)
+	for _, g := range groups {
+		if len(g) > 1 {
+			sort.Slice(g, func(x, y int) bool { return g[x].Checksumpicture < g[y].Checksumpicture })
+			clusters = append(clusters, g)
+		}
+	}
+	sort.Slice(clusters, func(x, y int) bool {
+		if len(clusters[x]) != len(clusters[y]) {
+			return len(clusters[x]) > len(clusters[y])
+		}
+		return clusters[x][0].Checksumpicture < clusters[y][0].Checksumpicture
+	})
+	return clusters, matches
+}
+
+// parseHashHex parse multi-word hashes created by HashHex
+func parseHashHex(hex string) ([]uint64, error) {
+	if len(hex) == 0 || len(hex)%16 != 0 {
+		return nil, fmt.Errorf("invalid hash length %d", len(hex))
+	}
+	words := make([]uint64, 0, len(hex)/16)
+	for i := 0; i < len(hex); i += 16 {
+		w, err := strconv.ParseUint(hex[i:i+16], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, nil
+}
+
+// readOrientationEntries read orientation hashes of all pictures not marked deleted
+func readOrientationEntries(id common.RegDbID) ([]*OrientationEntry, error) {
+	sqlCmd, err := templateSql(readOrientationHashs, HashKind(OrientationAlgorithm, 64))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*OrientationEntry, 0)
+	query := &common.Query{
+		TableName:  "picturehash",
+		DataStruct: &orientationRow{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		row := result.Data.(*orientationRow)
+		hashes, err := parseHashHex(row.Exthash)
+		if err != nil || len(hashes) != OrientationTransforms {
+			log.Log.Errorf("Error parsing orientation hash of %s: %v", row.Checksumpicture, err)
+			return nil
+		}
+		entries = append(entries, &OrientationEntry{Checksumpicture: row.Checksumpicture, Hashes: hashes})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// orientationDuplicateClean search clusters of rotated or mirrored copies
+// and resolve them like exact hash duplicates
+func (parameter *HashCleanParameter) orientationDuplicateClean() error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	entries, err := readOrientationEntries(id)
+	if err != nil {
+		fmt.Println("Error query orientation hashes:", err)
+		return err
+	}
+	clusters, _ := OrientationClusters(entries, parameter.Distance)
+	services.ServerMessage("Found %d rotated or mirrored clusters in %d hashes with distance %d",
+		len(clusters), len(entries), parameter.Distance)
+	for i, cluster := range clusters {
+		if parameter.Limit > 0 && i >= parameter.Limit {
+			break
+		}
+		if len(cluster) <= parameter.MinCount {
+			break
+		}
+		checksums := make([]string, 0, len(cluster))
+		byChecksum := make(map[string]*OrientationEntry)
+		for _, e := range cluster {
+			checksums = append(checksums, e.Checksumpicture)
+			byChecksum[e.Checksumpicture] = e
+		}
+		fmt.Printf("Working on %d.Orientation cluster with %d pictures: %s\n", i+1, len(cluster), strings.Join(checksums, ","))
+		sqlCmd, err := templateSql(readPictureByChecksums, checksums)
+		if err != nil {
+			return err
+		}
+		picturesByHash, err := readPicturesByHash(id, sqlCmd)
+		if err != nil {
+			return err
+		}
+		err = parameter.resolvePictures(fmt.Sprintf("orientation cluster %d", i+1), picturesByHash)
+		if err != nil {
+			fmt.Println("Error resolving orientation cluster:", err)
+			return err
+		}
+		printOrientationRelations(byChecksum, picturesByHash, parameter.Distance)
+	}
+	return nil
+}
+
+// printOrientationRelations print the transform relating each deleted
+// picture to the kept picture, the kept picture is the first after the
+// retention decision
+func printOrientationRelations(entries map[string]*OrientationEntry, picturesByHash []*PictureByHash, maxDistance int) {
+	if len(picturesByHash) == 0 {
+		return
+	}
+	keep := entries[picturesByHash[0].Checksumpicture]
+	for _, pbh := range picturesByHash[1:] {
+		e := entries[pbh.Checksumpicture]
+		if !pbh.delete || keep == nil || e == nil {
+			continue
+		}
+		if m, ok := directOrientation(keep, e, maxDistance); ok {
+			fmt.Printf("%s is %s of %s (distance %d)\n", m.To, TransformName(m.Transform), m.From, m.Distance)
+		}
+	}
+}
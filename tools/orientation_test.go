@@ -0,0 +1,120 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testOrientationImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, orientationSize, orientationSize))
+	for y := 0; y < orientationSize; y++ {
+		for x := 0; x < orientationSize; x++ {
+			v := uint8((x*3 + y*y/8) % 256)
+			if x < 20 && y < 10 {
+				v = 255
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+	return img
+}
+
+func TestOrientImage(t *testing.T) {
+	img := testOrientationImage()
+	r := img
+	for i := 0; i < 4; i++ {
+		r = orientImage(r, 1)
+	}
+	assert.Equal(t, img.Pix, r.Pix)
+	assert.Equal(t, img.Pix, orientImage(orientImage(img, 4), 4).Pix)
+	assert.Equal(t, orientImage(img, 2).Pix, orientImage(orientImage(img, 1), 1).Pix)
+	assert.Equal(t, orientImage(img, 5).Pix, orientImage(orientImage(img, 4), 1).Pix)
+	assert.Equal(t, "mirror+rotate90", TransformName(5))
+	assert.Equal(t, "unknown", TransformName(8))
+}
+
+func TestOrientationHashes(t *testing.T) {
+	img := testOrientationImage()
+	hashes, err := OrientationHashes(img)
+	assert.NoError(t, err)
+	assert.Len(t, hashes, OrientationTransforms)
+	rotated, err := OrientationHashes(orientImage(img, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, hashes[1], rotated[0])
+	c1, _ := CanonicalHash(hashes)
+	c2, _ := CanonicalHash(rotated)
+	assert.Equal(t, c1, c2)
+
+	mirrored, err := OrientationHashes(orientImage(img, 4))
+	assert.NoError(t, err)
+	entries := []*OrientationEntry{
+		{Checksumpicture: "A", Hashes: hashes},
+		{Checksumpicture: "B", Hashes: rotated},
+		{Checksumpicture: "C", Hashes: mirrored},
+		{Checksumpicture: "D", Hashes: []uint64{1, 2, 3, 4, 5, 6, 7, 8}},
+	}
+	clusters, matches := OrientationClusters(entries, 0)
+	assert.Len(t, clusters, 1)
+	assert.Len(t, clusters[0], 3)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "A", matches[0].From)
+	assert.Equal(t, "B", matches[0].To)
+	assert.Equal(t, "rotate90", TransformName(matches[0].Transform))
+	assert.Equal(t, "C", matches[1].To)
+	assert.Equal(t, "mirror", TransformName(matches[1].Transform))
+}
+
+func TestOrientationClustersDirect(t *testing.T) {
+	// B is a rotated copy of A and C a rotated copy of B, but C matches no
+	// transform of A
+	entries := []*OrientationEntry{
+		{Checksumpicture: "A", Hashes: []uint64{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17}},
+		{Checksumpicture: "B", Hashes: []uint64{0x11, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27}},
+		{Checksumpicture: "C", Hashes: []uint64{0x22, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37}},
+	}
+	clusters, matches := OrientationClusters(entries, 0)
+	if assert.Len(t, clusters, 1) {
+		assert.Equal(t, "A", clusters[0][0].Checksumpicture)
+		assert.Equal(t, "B", clusters[0][1].Checksumpicture)
+		assert.Len(t, clusters[0], 2)
+	}
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "rotate90", TransformName(matches[0].Transform))
+	}
+	m, ok := orientationMatch(entries[1], entries[0], 0, true)
+	assert.False(t, ok)
+	m, ok = orientationMatch(entries[0], entries[1], 0, true)
+	if assert.True(t, ok) {
+		assert.Equal(t, "B", m.To)
+	}
+}
+
+func TestParseHashHex(t *testing.T) {
+	words := []uint64{0x1, 0xFFFFFFFFFFFFFFFF}
+	parsed, err := parseHashHex(HashHex(words))
+	assert.NoError(t, err)
+	assert.Equal(t, words, parsed)
+	_, err = parseHashHex("123")
+	assert.Error(t, err)
+}
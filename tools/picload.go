@@ -49,6 +49,8 @@ type PicLoadParameter struct {
 	Directories    []string
 	InsertAlbum    bool
 	Json           bool
+	VideoProxy     bool
+	ProxyRes       int
 }
 
 func PicLoad(parameter *PicLoadParameter) error {
@@ -105,6 +107,14 @@ func PicLoad(parameter *PicLoadParameter) error {
 	}
 	log.Log.Debugf("Wait wgstore")
 	wgStore.Wait()
+	if parameter.VideoProxy {
+		// all videos need to be inserted before transcoding the proxies
+		sql.WaitStored()
+		err := VideoProxy(&VideoProxyParameter{MaxResolution: parameter.ProxyRes, Commit: true})
+		if err != nil {
+			fmt.Println("Error generating video proxies:", err)
+		}
+	}
 
 	if parameter.Json {
 		sql.PrintJsonStats()
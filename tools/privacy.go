@@ -0,0 +1,315 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+	"gopkg.in/yaml.v2"
+)
+
+// GPS handling of the privacy profile, ordered from weakest to strictest
+const (
+	PrivacyKeep  = "keep"
+	PrivacyBlur  = "blur"
+	PrivacyStrip = "strip"
+)
+
+// DefaultBlurDecimals decimals of a degree kept by blur, one decimal is
+// about 11 km which is city level
+const DefaultBlurDecimals = 1
+
+var privacyGPS = []string{PrivacyKeep, PrivacyBlur, PrivacyStrip}
+
+const readPrivacyInfo = `
+SELECT COALESCE(( SELECT string_agg(pt.tagname::text, ','::text ORDER BY pt.tagname::text) FROM picturetags pt
+          WHERE pt.checksumpicture::text = p.checksumpicture::text), '') AS tags,
+  COALESCE(( SELECT string_agg(a.title::text, E'\n' ORDER BY a.title::text) FROM albumpictures ap, albums a
+          WHERE ap.albumid = a.id AND ap.checksumpicture::text = p.checksumpicture::text), '') AS albums
+  FROM pictures p WHERE p.checksumpicture = $1
+`
+
+// PrivacyProfile privacy filter applied to exports and published
+// renditions. The rules are applied in order, a rule can only make the
+// filter stricter.
+type PrivacyProfile struct {
+	// GPS keep, blur or strip the GPS position
+	GPS string `yaml:"gps"`
+	// Decimals of a degree kept by blur, unset is DefaultBlurDecimals
+	Decimals *int `yaml:"decimals"`
+	// StripSerial remove serial numbers, owner, unique id and maker notes
+	StripSerial bool          `yaml:"stripSerial"`
+	Rules       []PrivacyRule `yaml:"rules"`
+}
+
+// PrivacyRule stricter filter for pictures with the tag or in an album
+// matching the album pattern, e.g. 'Family*'
+type PrivacyRule struct {
+	Name  string `yaml:"name"`
+	Tag   string `yaml:"tag"`
+	Album string `yaml:"album"`
+	GPS   string `yaml:"gps"`
+	// Decimals of a degree kept by blur, unset keeps the decimals of the
+	// profile
+	Decimals    *int `yaml:"decimals"`
+	StripSerial bool `yaml:"stripSerial"`
+}
+
+// PrivacyDecision filter of a picture and the rules which decided it
+type PrivacyDecision struct {
+	GPS         string
+	Decimals    int
+	StripSerial bool
+	Rules       []string
+}
+
+type privacyInfo struct {
+	Tags   string
+	Albums string
+}
+
+// LoadPrivacyProfile read the privacy profile out of the YAML file
+func LoadPrivacyProfile(file string) (*PrivacyProfile, error) {
+	byteValue, err := ReadScanFile(file)
+	if err != nil {
+		return nil, err
+	}
+	profile := &PrivacyProfile{GPS: PrivacyKeep}
+	err = yaml.Unmarshal(byteValue, profile)
+	if err != nil {
+		log.Log.Debugf("Unmarshal error: %#v", err)
+		return nil, fmt.Errorf("error parsing privacy profile %s: %v", file, err)
+	}
+	err = profile.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func validPrivacyGPS(gps string) bool {
+	for _, g := range privacyGPS {
+		if g == gps {
+			return true
+		}
+	}
+	return false
+}
+
+func privacyLevel(gps string) int {
+	for i, g := range privacyGPS {
+		if g == gps {
+			return i
+		}
+	}
+	return 0
+}
+
+// Validate check the GPS handling, the decimals and the rule conditions
+func (profile *PrivacyProfile) Validate() error {
+	if profile.GPS == "" {
+		profile.GPS = PrivacyKeep
+	}
+	if !validPrivacyGPS(profile.GPS) {
+		return fmt.Errorf("unknown privacy gps '%s', use keep, blur or strip", profile.GPS)
+	}
+	if profile.Decimals == nil {
+		decimals := DefaultBlurDecimals
+		profile.Decimals = &decimals
+	}
+	if *profile.Decimals < 0 || *profile.Decimals > 6 {
+		return fmt.Errorf("privacy decimals %d out of range 0-6", *profile.Decimals)
+	}
+	for i, rule := range profile.Rules {
+		if rule.Name == "" {
+			profile.Rules[i].Name = fmt.Sprintf("rule%d", i+1)
+		}
+		if rule.Tag == "" && rule.Album == "" {
+			return fmt.Errorf("privacy rule %s has neither tag nor album", profile.Rules[i].Name)
+		}
+		if rule.GPS != "" && !validPrivacyGPS(rule.GPS) {
+			return fmt.Errorf("unknown gps '%s' of privacy rule %s", rule.GPS, profile.Rules[i].Name)
+		}
+		if _, err := path.Match(rule.Album, ""); err != nil {
+			return fmt.Errorf("invalid album pattern of privacy rule %s: %v", profile.Rules[i].Name, err)
+		}
+		if rule.Decimals != nil && (*rule.Decimals < 0 || *rule.Decimals > 6) {
+			return fmt.Errorf("decimals %d of privacy rule %s out of range 0-6", *rule.Decimals, profile.Rules[i].Name)
+		}
+	}
+	return nil
+}
+
+// matches check if the rule applies to a picture with the tags and albums
+func (rule *PrivacyRule) matches(tags, albums []string) bool {
+	if rule.Tag != "" && slices.Contains(tags, rule.Tag) {
+		return true
+	}
+	if rule.Album != "" {
+		for _, album := range albums {
+			if ok, _ := path.Match(rule.Album, album); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decide the filter of a picture with the tags and albums, a nil profile
+// keeps everything
+func (profile *PrivacyProfile) Decide(tags, albums []string) *PrivacyDecision {
+	if profile == nil {
+		return &PrivacyDecision{GPS: PrivacyKeep}
+	}
+	decision := &PrivacyDecision{GPS: profile.GPS, Decimals: DefaultBlurDecimals, StripSerial: profile.StripSerial}
+	if profile.Decimals != nil {
+		decision.Decimals = *profile.Decimals
+	}
+	for _, rule := range profile.Rules {
+		if !rule.matches(tags, albums) {
+			continue
+		}
+		decision.Rules = append(decision.Rules, rule.Name)
+		if rule.StripSerial {
+			decision.StripSerial = true
+		}
+		if rule.GPS == "" {
+			continue
+		}
+		switch {
+		case privacyLevel(rule.GPS) > privacyLevel(decision.GPS):
+			decision.GPS = rule.GPS
+			if rule.Decimals != nil {
+				decision.Decimals = *rule.Decimals
+			}
+		case rule.GPS == PrivacyBlur && decision.GPS == PrivacyBlur && rule.Decimals != nil:
+			decision.Decimals = min(decision.Decimals, *rule.Decimals)
+		}
+	}
+	return decision
+}
+
+// readPrivacyDecision read tags and albums of the picture and decide the
+// filter
+func (profile *PrivacyProfile) readPrivacyDecision(id common.RegDbID, checksum string) (*PrivacyDecision, error) {
+	if profile == nil {
+		return profile.Decide(nil, nil), nil
+	}
+	info := &privacyInfo{}
+	query := &common.Query{
+		TableName:  "pictures",
+		DataStruct: &privacyInfo{},
+		Search:     readPrivacyInfo,
+		Parameters: []any{checksum},
+	}
+	err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		*info = *result.Data.(*privacyInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var tags, albums []string
+	if info.Tags != "" {
+		tags = strings.Split(info.Tags, ",")
+	}
+	if info.Albums != "" {
+		albums = strings.Split(info.Albums, "\n")
+	}
+	return profile.Decide(tags, albums), nil
+}
+
+// Active check if the decision changes anything
+func (decision *PrivacyDecision) Active() bool {
+	return decision != nil && (decision.GPS != PrivacyKeep || decision.StripSerial)
+}
+
+// blurCoordinate round the coordinate to the decimals of a degree
+func blurCoordinate(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+// Coordinates filtered GPS position, stripped positions are returned as 0,0
+// which is taken as unknown position
+func (decision *PrivacyDecision) Coordinates(latitude, longitude float64) (float64, float64) {
+	if decision == nil {
+		return latitude, longitude
+	}
+	switch decision.GPS {
+	case PrivacyStrip:
+		return 0, 0
+	case PrivacyBlur:
+		return blurCoordinate(latitude, decision.Decimals), blurCoordinate(longitude, decision.Decimals)
+	}
+	return latitude, longitude
+}
+
+// filterXmp apply the decision to the GPS position of the XMP metadata
+func (decision *PrivacyDecision) filterXmp(x *XmpData) {
+	if x != nil {
+		x.Latitude, x.Longitude = decision.Coordinates(x.Latitude, x.Longitude)
+	}
+}
+
+// privacyMedia verify the media and return the filtered copy
+func privacyMedia(id common.RegDbID, checksum, sha256sum, picopt string, media []byte,
+	decision *PrivacyDecision) ([]byte, error) {
+	media, err := verifiedMedia(id, checksum, sha256sum, picopt, media)
+	if err != nil {
+		return nil, err
+	}
+	return FilterPrivacy(media, decision)
+}
+
+// verifiedMedia verify and return the media. Media not given is read out
+// of the database, webstore media is downloaded into memory.
+func verifiedMedia(id common.RegDbID, checksum, sha256sum, picopt string, media []byte) ([]byte, error) {
+	switch {
+	case picopt == "webstore":
+		var buffer bytes.Buffer
+		_, err := sql.DownloadToWriter(checksum, &buffer)
+		if err != nil {
+			return nil, err
+		}
+		media = buffer.Bytes()
+	case media == nil:
+		var err error
+		media, err = readMedia(id, checksum)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mh := newMediaHash()
+	mh.Write(media)
+	err := mh.verify(checksum, sha256sum)
+	if err != nil {
+		return nil, err
+	}
+	return media, nil
+}
@@ -0,0 +1,298 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// EXIF tags handled by the privacy filter
+const (
+	tiffTagArtist           = 0x013b
+	tiffTagExifIFD          = 0x8769
+	tiffTagGpsIFD           = 0x8825
+	tiffTagMakerNote        = 0x927c
+	tiffTagImageUniqueID    = 0xa420
+	tiffTagCameraOwnerName  = 0xa430
+	tiffTagBodySerialNumber = 0xa431
+	tiffTagLensSerialNumber = 0xa435
+	gpsTagLatitude          = 0x0002
+	gpsTagLongitude         = 0x0004
+	gpsTagDestLatitude      = 0x0014
+	gpsTagDestLongitude     = 0x0016
+	tiffTypeRational        = 5
+)
+
+var tiffTypeSize = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	xmpGpsRegex  = regexp.MustCompile(`exif:GPS(?:Dest)?(?:Latitude|Longitude)(?:="([^"]*)"|>([^<]*)<)`)
+	xmpSerialReg = regexp.MustCompile(`(?:aux:SerialNumber|aux:LensSerialNumber|aux:OwnerName|exifEX:BodySerialNumber|exifEX:LensSerialNumber|exifEX:CameraOwnerName)(?:="([^"]*)"|>([^<]*)<)`)
+	iso6709Regex = regexp.MustCompile(`([+-]\d{2})(\.\d+)?([+-]\d{3})(\.\d+)?([+-]\d+(?:\.\d+)?)?/`)
+)
+
+// FilterPrivacy copy of the media with GPS and serial information removed
+// or blurred as decided. The metadata is changed in place, so the structure
+// of the file is unchanged: EXIF of JPEG and HEIF, XMP packets and the
+// ISO 6709 location of QuickTime and MP4 videos. Blurred positions are only
+// kept in EXIF and QuickTime, in XMP they are removed.
+func FilterPrivacy(media []byte, decision *PrivacyDecision) ([]byte, error) {
+	if !decision.Active() {
+		return media, nil
+	}
+	data := bytes.Clone(media)
+	for pos := bytes.Index(data, exifHeader); pos >= 0; {
+		start := pos + len(exifHeader)
+		if isTiffHeader(data[start:]) {
+			err := scrubTiff(data[start:], decision)
+			if err != nil {
+				return nil, fmt.Errorf("error filtering EXIF: %v", err)
+			}
+		}
+		next := bytes.Index(data[start:], exifHeader)
+		if next < 0 {
+			break
+		}
+		pos = start + next
+	}
+	if decision.GPS != PrivacyKeep {
+		blankRegexValues(data, xmpGpsRegex)
+		if len(data) > 12 && string(data[4:8]) == "ftyp" {
+			filterISO6709(data, decision)
+		}
+	}
+	if decision.StripSerial {
+		blankRegexValues(data, xmpSerialReg)
+	}
+	return data, nil
+}
+
+func isTiffHeader(data []byte) bool {
+	return len(data) > 8 && (bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")))
+}
+
+// blankRegexValues overwrite the values of the first non-empty sub match
+// with spaces
+func blankRegexValues(data []byte, re *regexp.Regexp) {
+	for _, m := range re.FindAllSubmatchIndex(data, -1) {
+		for g := 2; g+1 < len(m); g += 2 {
+			if m[g] >= 0 {
+				for i := m[g]; i < m[g+1]; i++ {
+					data[i] = ' '
+				}
+			}
+		}
+	}
+}
+
+// filterISO6709 strip or blur the ISO 6709 location string of QuickTime
+// videos, e.g. '+49.8712+008.6500+123.456/'. Stripped locations are
+// overwritten with spaces, blurred locations are rounded like the EXIF
+// position and written with the same length.
+func filterISO6709(data []byte, decision *PrivacyDecision) {
+	for _, m := range iso6709Regex.FindAllSubmatchIndex(data, -1) {
+		if decision.GPS != PrivacyStrip && blurISO6709(data, m, decision.Decimals) {
+			continue
+		}
+		for i := m[0]; i < m[1]; i++ {
+			data[i] = ' '
+		}
+	}
+}
+
+// blurISO6709 round latitude and longitude of the ISO 6709 match in place,
+// returns false if a rounded value does not fit into the original length
+func blurISO6709(data []byte, m []int, decimals int) bool {
+	for _, g := range []int{2, 6} {
+		if m[g+2] < 0 {
+			continue
+		}
+		start, end := m[g], m[g+3]
+		value, err := strconv.ParseFloat(string(data[start:end]), 64)
+		if err != nil {
+			return false
+		}
+		fraction := m[g+3] - m[g+2] - 1
+		rounded := fmt.Sprintf("%+0*.*f", end-start, fraction, blurCoordinate(value, decimals))
+		if len(rounded) != end-start {
+			return false
+		}
+		copy(data[start:end], rounded)
+	}
+	return true
+}
+
+// tiffScrub TIFF structure of the EXIF data, all offsets are relative to
+// the TIFF header
+type tiffScrub struct {
+	data     []byte
+	order    binary.ByteOrder
+	decision *PrivacyDecision
+}
+
+func (t *tiffScrub) check(offset, size int) error {
+	if offset < 0 || size < 0 || offset+size > len(t.data) {
+		return fmt.Errorf("TIFF offset %d out of range", offset)
+	}
+	return nil
+}
+
+func (t *tiffScrub) zero(offset, size int) {
+	clear(t.data[offset : offset+size])
+}
+
+// tiffEntry IFD entry with the offset of its value
+type tiffEntry struct {
+	offset      int
+	tag         uint16
+	typ         uint16
+	count       uint32
+	valueOffset int
+	size        int
+}
+
+// entries read all entries of the IFD at the offset
+func (t *tiffScrub) entries(offset int) ([]*tiffEntry, error) {
+	if err := t.check(offset, 2); err != nil {
+		return nil, err
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	if err := t.check(offset+2, count*12); err != nil {
+		return nil, err
+	}
+	entries := make([]*tiffEntry, 0, count)
+	for i := 0; i < count; i++ {
+		e := offset + 2 + i*12
+		entry := &tiffEntry{offset: e, tag: t.order.Uint16(t.data[e:]), typ: t.order.Uint16(t.data[e+2:]),
+			count: t.order.Uint32(t.data[e+4:])}
+		entry.size = tiffTypeSize[entry.typ] * int(entry.count)
+		if entry.size <= 4 {
+			entry.valueOffset = e + 8
+		} else {
+			entry.valueOffset = int(t.order.Uint32(t.data[e+8:]))
+		}
+		if err := t.check(entry.valueOffset, entry.size); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// scrubTiff filter IFD0, the EXIF IFD and the GPS IFD
+func scrubTiff(data []byte, decision *PrivacyDecision) error {
+	t := &tiffScrub{data: data, order: binary.LittleEndian, decision: decision}
+	if data[0] == 'M' {
+		t.order = binary.BigEndian
+	}
+	ifd0, err := t.entries(int(t.order.Uint32(data[4:])))
+	if err != nil {
+		return err
+	}
+	for _, entry := range ifd0 {
+		switch entry.tag {
+		case tiffTagArtist:
+			if decision.StripSerial {
+				t.zero(entry.valueOffset, entry.size)
+			}
+		case tiffTagExifIFD:
+			if decision.StripSerial {
+				err = t.scrubExif(int(t.order.Uint32(data[entry.valueOffset:])))
+			}
+		case tiffTagGpsIFD:
+			if decision.GPS != PrivacyKeep {
+				err = t.scrubGps(int(t.order.Uint32(data[entry.valueOffset:])))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrubExif remove owner, serial numbers, unique id and maker notes
+func (t *tiffScrub) scrubExif(offset int) error {
+	entries, err := t.entries(offset)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		switch entry.tag {
+		case tiffTagCameraOwnerName, tiffTagBodySerialNumber, tiffTagLensSerialNumber,
+			tiffTagImageUniqueID, tiffTagMakerNote:
+			t.zero(entry.valueOffset, entry.size)
+		}
+	}
+	return nil
+}
+
+// scrubGps strip all GPS values and set the GPS IFD empty, or blur the
+// positions
+func (t *tiffScrub) scrubGps(offset int) error {
+	entries, err := t.entries(offset)
+	if err != nil {
+		return err
+	}
+	if t.decision.GPS == PrivacyStrip {
+		for _, entry := range entries {
+			t.zero(entry.valueOffset, entry.size)
+		}
+		t.zero(offset, 2+12*len(entries))
+		return nil
+	}
+	for _, entry := range entries {
+		switch entry.tag {
+		case gpsTagLatitude, gpsTagLongitude, gpsTagDestLatitude, gpsTagDestLongitude:
+			if entry.typ != tiffTypeRational || entry.count != 3 {
+				t.zero(entry.valueOffset, entry.size)
+				continue
+			}
+			t.blurRationals(entry.valueOffset)
+		}
+	}
+	return nil
+}
+
+// blurRationals blur degree, minutes and seconds of a GPS coordinate
+func (t *tiffScrub) blurRationals(offset int) {
+	value := 0.0
+	for i, factor := range []float64{1, 60, 3600} {
+		numerator := float64(t.order.Uint32(t.data[offset+i*8:]))
+		denominator := float64(t.order.Uint32(t.data[offset+i*8+4:]))
+		if denominator != 0 {
+			value += numerator / denominator / factor
+		}
+	}
+	value = blurCoordinate(value, t.decision.Decimals)
+	degree := math.Floor(value)
+	minutes := math.Floor((value - degree) * 60)
+	seconds := math.Round(((value-degree)*60 - minutes) * 60 * 100)
+	for i, r := range [][2]uint32{{uint32(degree), 1}, {uint32(minutes), 1}, {uint32(seconds), 100}} {
+		t.order.PutUint32(t.data[offset+i*8:], r[0])
+		t.order.PutUint32(t.data[offset+i*8+4:], r[1])
+	}
+}
@@ -0,0 +1,218 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	goexif "github.com/rwcarlsen/goexif/exif"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPrivacyProfile(t *testing.T) {
+	profile, err := LoadPrivacyProfile("../privacy.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, PrivacyBlur, profile.GPS)
+	assert.Equal(t, 1, *profile.Decimals)
+	assert.True(t, profile.StripSerial)
+	assert.Len(t, profile.Rules, 2)
+	// the family rule keeps no decimals
+	d := profile.Decide(nil, []string{"Family 2024"})
+	assert.Equal(t, PrivacyBlur, d.GPS)
+	assert.Equal(t, 0, d.Decimals)
+	assert.Equal(t, []string{"family"}, d.Rules)
+
+	file := filepath.Join(t.TempDir(), "privacy.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("gps: hide\n"), 0644))
+	_, err = LoadPrivacyProfile(file)
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(file, []byte("rules:\n - name: x\n   gps: strip\n"), 0644))
+	_, err = LoadPrivacyProfile(file)
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(file, []byte("stripSerial: true\n"), 0644))
+	profile, err = LoadPrivacyProfile(file)
+	if assert.NoError(t, err) {
+		assert.Equal(t, PrivacyKeep, profile.GPS)
+		assert.Equal(t, DefaultBlurDecimals, *profile.Decimals)
+	}
+}
+
+func TestPrivacyDecide(t *testing.T) {
+	two, one, zero := 2, 1, 0
+	profile := &PrivacyProfile{GPS: PrivacyBlur, Decimals: &two, Rules: []PrivacyRule{
+		{Name: "kids", Tag: "kids", GPS: PrivacyStrip},
+		{Name: "family", Album: "Family*", GPS: PrivacyBlur, Decimals: &zero},
+		{Name: "coarse", Album: "Trip*", GPS: PrivacyBlur, Decimals: &one},
+		{Name: "unset", Album: "Holiday*", GPS: PrivacyBlur},
+		{Name: "public", Tag: "public", GPS: PrivacyKeep, StripSerial: true},
+	}}
+	assert.NoError(t, profile.Validate())
+
+	d := profile.Decide(nil, nil)
+	assert.Equal(t, PrivacyBlur, d.GPS)
+	assert.Equal(t, 2, d.Decimals)
+	assert.Empty(t, d.Rules)
+
+	d = profile.Decide([]string{"beach", "kids"}, []string{"Trip 2024"})
+	assert.Equal(t, PrivacyStrip, d.GPS)
+	assert.Equal(t, []string{"kids", "coarse"}, d.Rules)
+
+	d = profile.Decide(nil, []string{"Trip 2024"})
+	assert.Equal(t, PrivacyBlur, d.GPS)
+	assert.Equal(t, 1, d.Decimals)
+
+	d = profile.Decide(nil, []string{"Family"})
+	assert.Equal(t, 0, d.Decimals)
+
+	// a rule without decimals keeps the decimals of the profile
+	d = profile.Decide(nil, []string{"Holiday"})
+	assert.Equal(t, 2, d.Decimals)
+
+	// a rule never keeps more than the profile
+	d = profile.Decide([]string{"public"}, nil)
+	assert.Equal(t, PrivacyBlur, d.GPS)
+	assert.True(t, d.StripSerial)
+
+	var none *PrivacyProfile
+	assert.False(t, none.Decide([]string{"kids"}, nil).Active())
+}
+
+func TestPrivacyCoordinates(t *testing.T) {
+	d := &PrivacyDecision{GPS: PrivacyBlur, Decimals: 1}
+	lat, lon := d.Coordinates(49.8712, 8.65)
+	assert.InDelta(t, 49.9, lat, 0.0001)
+	assert.InDelta(t, 8.7, lon, 0.0001)
+	lat, lon = (&PrivacyDecision{GPS: PrivacyStrip}).Coordinates(49.8712, 8.65)
+	assert.Equal(t, 0.0, lat)
+	assert.Equal(t, 0.0, lon)
+	lat, _ = (&PrivacyDecision{GPS: PrivacyKeep}).Coordinates(49.8712, 8.65)
+	assert.Equal(t, 49.8712, lat)
+}
+
+// privacyTestJPEG JPEG with EXIF containing artist, body serial number and
+// the GPS position 49°52'16.32"N 8°39'0"E
+func privacyTestJPEG() []byte {
+	tiff := make([]byte, 186)
+	le := binary.LittleEndian
+	copy(tiff, "II*\x00")
+	le.PutUint32(tiff[4:], 8)
+	entry := func(offset int, tag, typ uint16, count, value uint32) {
+		le.PutUint16(tiff[offset:], tag)
+		le.PutUint16(tiff[offset+2:], typ)
+		le.PutUint32(tiff[offset+4:], count)
+		le.PutUint32(tiff[offset+8:], value)
+	}
+	le.PutUint16(tiff[8:], 3)
+	entry(10, tiffTagArtist, 2, 6, 50)
+	entry(22, tiffTagExifIFD, 4, 1, 56)
+	entry(34, tiffTagGpsIFD, 4, 1, 84)
+	copy(tiff[50:], "Owner\x00")
+	le.PutUint16(tiff[56:], 1)
+	entry(58, tiffTagBodySerialNumber, 2, 9, 74)
+	copy(tiff[74:], "SER12345\x00")
+	le.PutUint16(tiff[84:], 4)
+	entry(86, 1, 2, 2, 'N')
+	entry(98, gpsTagLatitude, tiffTypeRational, 3, 138)
+	entry(110, 3, 2, 2, 'E')
+	entry(122, gpsTagLongitude, tiffTypeRational, 3, 162)
+	for i, r := range []uint32{49, 1, 52, 1, 1632, 100, 8, 1, 39, 1, 0, 1} {
+		le.PutUint32(tiff[138+i*4:], r)
+	}
+	segment, _ := jpegSegment(jpegAPP1, string(exifHeader), tiff)
+	media := append([]byte{0xff, 0xd8}, segment...)
+	return append(media, 0xff, 0xd9)
+}
+
+func TestFilterPrivacyExif(t *testing.T) {
+	media := privacyTestJPEG()
+	x, err := goexif.Decode(bytes.NewReader(media))
+	if !assert.NoError(t, err) {
+		return
+	}
+	lat, lon, err := x.LatLong()
+	assert.NoError(t, err)
+	assert.InDelta(t, 49.8712, lat, 0.0001)
+	assert.InDelta(t, 8.65, lon, 0.0001)
+
+	unchanged, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyKeep})
+	assert.NoError(t, err)
+	assert.Equal(t, media, unchanged)
+
+	blurred, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyBlur, Decimals: 1, StripSerial: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, bytes.Contains(media, []byte("SER12345")), "original is not changed")
+	assert.False(t, bytes.Contains(blurred, []byte("SER12345")))
+	assert.False(t, bytes.Contains(blurred, []byte("Owner")))
+	assert.Equal(t, len(media), len(blurred))
+	x, err = goexif.Decode(bytes.NewReader(blurred))
+	if !assert.NoError(t, err) {
+		return
+	}
+	lat, lon, err = x.LatLong()
+	assert.NoError(t, err)
+	assert.InDelta(t, 49.9, lat, 0.0001)
+	assert.InDelta(t, 8.7, lon, 0.0001)
+
+	stripped, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyStrip})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, bytes.Contains(stripped, []byte("SER12345")))
+	x, err = goexif.Decode(bytes.NewReader(stripped))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, _, err = x.LatLong()
+	assert.Error(t, err)
+}
+
+func TestFilterPrivacyXmp(t *testing.T) {
+	media := []byte(`<rdf:Description exif:GPSLatitude="49,52.272000N" aux:SerialNumber="123">` +
+		`<exif:GPSLongitude>8,39.000000E</exif:GPSLongitude></rdf:Description>`)
+	filtered, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyBlur, Decimals: 1, StripSerial: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `<rdf:Description exif:GPSLatitude="`+strings.Repeat(" ", 13)+`" aux:SerialNumber="   ">`+
+		`<exif:GPSLongitude>`+strings.Repeat(" ", 12)+`</exif:GPSLongitude></rdf:Description>`, string(filtered))
+}
+
+func TestFilterPrivacyISO6709(t *testing.T) {
+	media := []byte("\x00\x00\x00\x18ftypqt  ....\xa9xyz\x00\x1a+49.8712+008.6512+123.456/....")
+	blurred, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyBlur, Decimals: 1})
+	assert.NoError(t, err)
+	assert.Contains(t, string(blurred), "+49.9000+008.7000+123.456/")
+	rounded, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyBlur, Decimals: 0})
+	assert.NoError(t, err)
+	assert.Contains(t, string(rounded), "+50.0000+009.0000+123.456/")
+	stripped, err := FilterPrivacy(media, &PrivacyDecision{GPS: PrivacyStrip})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(stripped), "+49.")
+	assert.Equal(t, len(media), len(stripped))
+}
@@ -0,0 +1,179 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"fmt"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/services"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// DefaultGraceDays days a picture need to be marked deleted before it is purged
+const DefaultGraceDays = 30
+
+// readPurgeCandidates read all pictures marked deleted longer than the grace
+// period. The mark time is the last journal entry, pictures marked before
+// the journal existed use the last update of the picture.
+const readPurgeCandidates = `
+WITH m AS (
+SELECT p.checksumpicture, COALESCE(p.title, '') AS title, COALESCE(p.picopt, '') AS picopt,
+COALESCE(p.proxychecksum, '') AS proxychecksum,
+( SELECT count(*)::int4 FROM albumpictures ap WHERE ap.checksumpicture::text = p.checksumpicture::text) AS albums,
+COALESCE(( SELECT max(j.created) FROM markdeletejournal j
+   WHERE j.checksumpicture::text = p.checksumpicture::text AND j.restored IS NULL), p.updated_at, p.created) AS marked
+  FROM pictures p WHERE p.markdelete = true)
+SELECT checksumpicture, title, picopt, proxychecksum, albums,
+  to_char(marked, 'YYYY-MM-DD HH24:MI:SS') AS marked
+  FROM m WHERE marked < now() - interval '{{.GraceDays}} days'
+  ORDER BY marked
+  {{if gt .Limit 0 -}} LIMIT {{.Limit}} {{end}}
+`
+
+// purgeTables tables referencing the picture, the pictures table is removed last
+var purgeTables = []string{"picturehash", "picturetags", "picturelocations",
+	"picturerenditions", "videohash", "pictures"}
+
+type PurgeParameter struct {
+	GraceDays int
+	Limit     int
+	Commit    bool
+}
+
+// PurgeCandidate picture marked deleted longer than the grace period
+type PurgeCandidate struct {
+	Checksumpicture string
+	Title           string
+	Picopt          string
+	Proxychecksum   string
+	Albums          int
+	Marked          string
+}
+
+// Purge remove all pictures marked deleted longer than the grace period
+// from the database and the webstore. Pictures still referenced by an album
+// are refused.
+func Purge(parameter *PurgeParameter) error {
+	if parameter.GraceDays < 0 {
+		return fmt.Errorf("grace period must not be negative")
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	sqlCmd, err := templateSql(readPurgeCandidates, parameter)
+	if err != nil {
+		return err
+	}
+	candidates := make([]*PurgeCandidate, 0)
+	query := &common.Query{
+		TableName:  "pictures",
+		DataStruct: &PurgeCandidate{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		c := &PurgeCandidate{}
+		*c = *result.Data.(*PurgeCandidate)
+		candidates = append(candidates, c)
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error reading purge candidates:", err)
+		return err
+	}
+
+	purged, refused, failed := 0, 0, 0
+	for _, c := range candidates {
+		if c.Albums > 0 {
+			fmt.Printf("Refuse %s %s marked %s, referenced by %d album pictures\n",
+				c.Checksumpicture, c.Title, c.Marked, c.Albums)
+			refused++
+			continue
+		}
+		fmt.Printf("Purge %s %s marked %s (%s)\n", c.Checksumpicture, c.Title, c.Marked, c.Picopt)
+		if !parameter.Commit {
+			purged++
+			continue
+		}
+		err = purgePicture(id, c)
+		if err != nil {
+			fmt.Printf("Error purging %s: %v\n", c.Checksumpicture, err)
+			failed++
+			continue
+		}
+		purged++
+	}
+	if !parameter.Commit {
+		services.ServerMessage("Dry run, %d pictures would be purged, %d refused", purged, refused)
+		return nil
+	}
+	services.ServerMessage("Purged %d pictures, %d refused, %d failed", purged, refused, failed)
+	return nil
+}
+
+// purgePicture delete all rows of the picture in one transaction, the
+// webstore blobs are deleted after the commit
+func purgePicture(id common.RegDbID, c *PurgeCandidate) error {
+	err := id.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	criteria := fmt.Sprintf("checksumpicture = '%s'", c.Checksumpicture)
+	for _, table := range purgeTables {
+		if table == "pictures" {
+			// the album reference may be inserted since the candidates are read
+			criteria += " AND markdelete = true AND NOT EXISTS (SELECT 1 FROM albumpictures ap WHERE ap.checksumpicture::text = pictures.checksumpicture::text)"
+		}
+		n, err := id.Delete(table, &common.Entries{Criteria: criteria})
+		if err != nil {
+			id.Rollback()
+			log.Log.Errorf("Error deleting %s of %s: %v", table, c.Checksumpicture, err)
+			return err
+		}
+		if table == "pictures" && n == 0 {
+			id.Rollback()
+			return fmt.Errorf("picture not marked deleted or referenced by album")
+		}
+		log.Log.Debugf("Deleted %d rows of %s for %s", n, table, c.Checksumpicture)
+	}
+	err = id.Commit()
+	if err != nil {
+		return err
+	}
+	if c.Picopt == "webstore" {
+		err = sql.DeleteRestClient(c.Checksumpicture)
+		if err != nil {
+			fmt.Printf("Error deleting webstore blob %s: %v\n", c.Checksumpicture, err)
+		}
+	}
+	if c.Proxychecksum != "" {
+		err = sql.DeleteRestClient(VideoProxyName(c.Checksumpicture))
+		if err != nil {
+			fmt.Printf("Error deleting webstore proxy %s: %v\n", c.Checksumpicture, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeTemplate(t *testing.T) {
+	sqlCmd, err := templateSql(readPurgeCandidates, &PurgeParameter{GraceDays: 30, Limit: 10})
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(sqlCmd, "interval '30 days'"))
+	assert.True(t, strings.Contains(sqlCmd, "LIMIT 10"))
+	sqlCmd, err = templateSql(readPurgeCandidates, &PurgeParameter{GraceDays: 7})
+	assert.NoError(t, err)
+	assert.False(t, strings.Contains(sqlCmd, "LIMIT"))
+	assert.Equal(t, "pictures", purgeTables[len(purgeTables)-1])
+}
@@ -0,0 +1,202 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const selectAlbumCover = `SELECT a.id, a.title, COALESCE((SELECT p.checksumpicture FROM albumpictures ap, pictures p
+  WHERE ap.albumid = a.id AND ap.checksumpicture = p.checksumpicture
+    AND p.markdelete = false AND p.quality IS NOT NULL
+  ORDER BY p.quality DESC, ap.index LIMIT 1), '') AS cover
+FROM albums a WHERE a.title <> 'Default Album'
+{{if ne .Title "" -}} AND a.title = '{{.Title}}' {{end}}`
+
+type QualityParameter struct {
+	ChkSum string
+	Limit  int
+	All    bool
+	Commit bool
+}
+
+type qualityGenerate struct {
+	id        common.RegDbID
+	parameter *QualityParameter
+	generated uint64
+	failed    uint64
+}
+
+type albumCover struct {
+	Id    int
+	Title string
+	Cover string
+}
+
+// Quality backfill the image quality score of all pictures
+func Quality(parameter *QualityParameter) error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+	wid, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer wid.FreeHandler()
+
+	limit := "ALL"
+	if parameter.Limit > 0 {
+		limit = strconv.Itoa(parameter.Limit)
+	}
+	search := "lower(mimetype) LIKE 'image/%' AND markdelete = false"
+	if !parameter.All {
+		search += " AND quality IS NULL"
+	}
+	if parameter.ChkSum != "" {
+		search = fmt.Sprintf("checksumpicture = '%s' AND ", parameter.ChkSum) + search
+	}
+	gen := &qualityGenerate{id: wid, parameter: parameter}
+	q := &common.Query{TableName: "Pictures",
+		DataStruct:   &store.Pictures{},
+		Fields:       []string{"checksumpicture", "title", "mimetype", "media", "picopt"},
+		Search:       search,
+		Limit:        limit,
+		FctParameter: gen,
+	}
+	_, err = id.Query(q, generateQuality)
+	if err != nil {
+		log.Log.Errorf("Error quality query: %v", err)
+		fmt.Println("Error quality query ...:", err)
+		return err
+	}
+	fmt.Printf("Quality scores generated=%d failed=%d\n", gen.generated, gen.failed)
+	return nil
+}
+
+func generateQuality(search *common.Query, result *common.Result) error {
+	gen := search.FctParameter.(*qualityGenerate)
+	pic := result.Data.(*store.Pictures)
+	if pic.PicOpt == "webstore" {
+		title := tempMediaName(pic)
+		err := sql.DownloadToTitle(pic.ChecksumPicture, title)
+		if err != nil {
+			fmt.Println("Error download title:", err)
+			gen.failed++
+			return nil
+		}
+		pic.Media, err = os.ReadFile(title)
+		removeTempMedia(title)
+		if err != nil {
+			fmt.Println("Error reading download:", err)
+			gen.failed++
+			return nil
+		}
+	}
+	img, err := store.DecodeImage(pic.Media, pic.MIMEType)
+	if err != nil {
+		log.Log.Errorf("Error decoding %s: %v", pic.ChecksumPicture, err)
+		fmt.Printf("Error decoding %s(%s): %v\n", pic.ChecksumPicture, pic.Title, err)
+		gen.failed++
+		return nil
+	}
+	q := store.QualityScore(img)
+	log.Log.Debugf("Quality %s: %#v", pic.ChecksumPicture, q)
+	fmt.Printf("%s %5.1f %s\n", pic.ChecksumPicture, q.Score, pic.Title)
+	gen.generated++
+	if !gen.parameter.Commit {
+		return nil
+	}
+	update := &common.Entries{
+		Fields: []string{"quality"},
+		Values: [][]any{{q.Score}},
+		Update: []string{fmt.Sprintf("checksumpicture = '%s'", pic.ChecksumPicture)},
+	}
+	_, _, err = gen.id.Update("pictures", update)
+	if err != nil {
+		fmt.Println("Error updating quality", pic.ChecksumPicture, ":", err)
+		return err
+	}
+	return gen.id.Commit()
+}
+
+// AlbumCover set the album cover to the picture with best quality score
+// of the album, if title is empty all albums get a new cover
+func AlbumCover(title string, commit bool) error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	sqlCmd, err := templateSql(selectAlbumCover, struct{ Title string }{title})
+	if err != nil {
+		return err
+	}
+	covers := make([]*albumCover, 0)
+	query := &common.Query{
+		TableName:  "albums",
+		DataStruct: &albumCover{},
+		Search:     sqlCmd,
+	}
+	err = id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		ac := result.Data.(*albumCover)
+		c := &albumCover{}
+		*c = *ac
+		covers = append(covers, c)
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error query album cover ...:", err)
+		return err
+	}
+	for _, c := range covers {
+		if c.Cover == "" {
+			fmt.Printf("Album %s has no picture with quality score\n", c.Title)
+			continue
+		}
+		fmt.Printf("Album %s cover -> %s\n", c.Title, c.Cover)
+		if !commit {
+			continue
+		}
+		update := &common.Entries{
+			Fields: []string{"thumbnailhash"},
+			Values: [][]any{{c.Cover}},
+			Update: []string{fmt.Sprintf("id = %d", c.Id)},
+		}
+		_, _, err = id.Update("albums", update)
+		if err != nil {
+			fmt.Println("Error updating album cover", c.Title, ":", err)
+			return err
+		}
+	}
+	return nil
+}
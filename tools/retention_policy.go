@@ -0,0 +1,224 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tknie/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Rules of the retention policy, a rule prefers pictures with the higher value
+const (
+	RuleWidest    = "widest"
+	RuleLargest   = "largest"
+	RuleHeic      = "heic"
+	RuleExif      = "exif"
+	RuleAlbum     = "album"
+	RuleSqlstore  = "sqlstore"
+	RuleQuality   = "quality"
+	RuleProtected = "protected"
+	RuleTags      = "tags"
+	// RuleTagPrefix prefer pictures with the given tag, e.g. 'tag:favorite'
+	RuleTagPrefix = "tag:"
+)
+
+// ruleOrder no rule distinguishes the pictures, the first checksum is kept
+const ruleOrder = "order"
+
+var retentionRules = []string{RuleWidest, RuleLargest, RuleHeic, RuleExif, RuleAlbum,
+	RuleSqlstore, RuleQuality, RuleProtected, RuleTags}
+
+// RetentionPolicy rules deciding which picture of a duplicate group is kept.
+// The rules are evaluated in order, the first rule distinguishing the best
+// pictures decides the group.
+type RetentionPolicy struct {
+	// Protect pictures with one of these tags are never deleted, '*' protects all tagged pictures
+	Protect []string `yaml:"protect"`
+	// MergeTags tags of the deleted pictures are added to the kept picture
+	MergeTags bool `yaml:"mergeTags"`
+	// Prefer ordered list of rules
+	Prefer []string `yaml:"prefer"`
+}
+
+// RetentionDecision picture to keep and the rule which decided it
+type RetentionDecision struct {
+	Keep   *PictureByHash
+	Rule   string
+	TagMap map[string]bool
+}
+
+// DefaultRetentionPolicy policy used if no policy file is given
+var DefaultRetentionPolicy = &RetentionPolicy{
+	Protect:   []string{"bitgarten"},
+	MergeTags: true,
+	Prefer:    []string{RuleWidest, RuleHeic, RuleProtected, RuleQuality},
+}
+
+// LoadRetentionPolicy read the retention policy out of the YAML file
+func LoadRetentionPolicy(file string) (*RetentionPolicy, error) {
+	byteValue, err := ReadScanFile(file)
+	if err != nil {
+		return nil, err
+	}
+	policy := &RetentionPolicy{}
+	err = yaml.Unmarshal(byteValue, policy)
+	if err != nil {
+		log.Log.Debugf("Unmarshal error: %#v", err)
+		return nil, fmt.Errorf("error parsing retention policy %s: %v", file, err)
+	}
+	err = policy.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate check that all rules are known
+func (policy *RetentionPolicy) Validate() error {
+	if len(policy.Prefer) == 0 {
+		return fmt.Errorf("retention policy contains no rule")
+	}
+	for _, rule := range policy.Prefer {
+		if strings.HasPrefix(rule, RuleTagPrefix) && len(rule) > len(RuleTagPrefix) {
+			continue
+		}
+		found := false
+		for _, r := range retentionRules {
+			if r == rule {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown retention rule %s, valid are %v and %s<name>", rule, retentionRules, RuleTagPrefix)
+		}
+	}
+	return nil
+}
+
+func hasTag(tags, tag string) bool {
+	if tag == "*" {
+		return tags != ""
+	}
+	return strings.Contains(tags, "'"+tag+"'")
+}
+
+// Protected check if the picture has a protected tag
+func (policy *RetentionPolicy) Protected(pbh *PictureByHash) bool {
+	for _, tag := range policy.Protect {
+		if hasTag(pbh.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func boolScore(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// score value of the picture for the rule
+func (policy *RetentionPolicy) score(rule string, pbh *PictureByHash) float64 {
+	switch rule {
+	case RuleWidest:
+		return float64(pbh.Width)
+	case RuleLargest:
+		return float64(pbh.Width) * float64(pbh.Height)
+	case RuleHeic:
+		return boolScore(strings.HasSuffix(strings.ToLower(pbh.Title), ".heic"))
+	case RuleExif:
+		return boolScore(pbh.Exifmodel != "")
+	case RuleAlbum:
+		return boolScore(pbh.Albums > 0)
+	case RuleSqlstore:
+		return boolScore(pbh.Picopt == "sqlstore")
+	case RuleQuality:
+		return pbh.Quality
+	case RuleProtected:
+		return boolScore(policy.Protected(pbh))
+	case RuleTags:
+		if pbh.Tags == "" {
+			return 0
+		}
+		return float64(len(strings.Split(pbh.Tags, ",")))
+	}
+	if strings.HasPrefix(rule, RuleTagPrefix) {
+		return boolScore(hasTag(pbh.Tags, strings.TrimPrefix(rule, RuleTagPrefix)))
+	}
+	return 0
+}
+
+// better compare two pictures, the first rule with different values decides
+func (policy *RetentionPolicy) better(a, b *PictureByHash) (bool, string) {
+	for _, rule := range policy.Prefer {
+		sa, sb := policy.score(rule, a), policy.score(rule, b)
+		if sa != sb {
+			return sa > sb, rule
+		}
+	}
+	return a.Checksumpicture < b.Checksumpicture, ruleOrder
+}
+
+// Decide select the picture to keep out of the group, all other pictures
+// which are not protected are marked to be deleted
+func (policy *RetentionPolicy) Decide(picturesByHash []*PictureByHash) *RetentionDecision {
+	if len(picturesByHash) == 0 {
+		return nil
+	}
+	sort.SliceStable(picturesByHash, func(x, y int) bool {
+		b, _ := policy.better(picturesByHash[x], picturesByHash[y])
+		return b
+	})
+	decision := &RetentionDecision{Keep: picturesByHash[0], Rule: ruleOrder, TagMap: make(map[string]bool)}
+	if len(picturesByHash) > 1 {
+		_, decision.Rule = policy.better(picturesByHash[0], picturesByHash[1])
+	}
+	for _, pbh := range picturesByHash {
+		pbh.delete = pbh != decision.Keep && !policy.Protected(pbh)
+		if policy.MergeTags {
+			policy.mergeTags(decision.TagMap, pbh)
+		}
+	}
+	return decision
+}
+
+// mergeTags add all tags of the picture except protected tags
+func (policy *RetentionPolicy) mergeTags(tagMap map[string]bool, pbh *PictureByHash) {
+	if pbh.Tags == "" {
+		return
+	}
+	for _, t := range strings.Split(pbh.Tags, ",") {
+		protected := false
+		for _, tag := range policy.Protect {
+			if t == "'"+tag+"'" {
+				protected = true
+			}
+		}
+		if !protected {
+			tagMap[t] = true
+		}
+	}
+}
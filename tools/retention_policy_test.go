@@ -0,0 +1,95 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRetentionPolicy(t *testing.T) {
+	pictures := testDuplicateGroup()
+	decision := DefaultRetentionPolicy.Decide(pictures)
+	assert.Equal(t, checksumC, decision.Keep.Checksumpicture)
+	assert.Equal(t, RuleProtected, decision.Rule)
+	assert.Equal(t, map[string]bool{"'holiday'": true, "'family'": true}, decision.TagMap)
+	for _, p := range pictures {
+		assert.Equal(t, p.Checksumpicture != checksumC, p.delete, p.Checksumpicture)
+	}
+
+	// HEIC wins on same width
+	pictures = []*PictureByHash{
+		{Checksumpicture: "A", Title: "a.jpg", Width: 1600, Quality: 0.9},
+		{Checksumpicture: "B", Title: "b.HEIC", Width: 1600, Quality: 0.1},
+		{Checksumpicture: "C", Title: "c.jpg", Width: 800, Tags: "'bitgarten'"},
+	}
+	decision = DefaultRetentionPolicy.Decide(pictures)
+	assert.Equal(t, "B", decision.Keep.Checksumpicture)
+	assert.Equal(t, RuleHeic, decision.Rule)
+	for _, p := range pictures {
+		// protected picture is never deleted
+		assert.Equal(t, p.Checksumpicture == "A", p.delete, p.Checksumpicture)
+	}
+	assert.Nil(t, DefaultRetentionPolicy.Decide(nil))
+}
+
+func TestLoadRetentionPolicy(t *testing.T) {
+	policy, err := LoadRetentionPolicy("../retention.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRetentionPolicy, policy)
+
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`protect:
+ - "*"
+prefer:
+ - exif
+ - album
+ - sqlstore
+ - tag:favorite
+`), 0644))
+	policy, err = LoadRetentionPolicy(file)
+	assert.NoError(t, err)
+	pictures := []*PictureByHash{
+		{Checksumpicture: "A", Width: 1600, Picopt: "webstore"},
+		{Checksumpicture: "B", Width: 800, Exifmodel: "iPhone", Albums: 1, Picopt: "webstore"},
+		{Checksumpicture: "C", Width: 800, Exifmodel: "iPhone", Albums: 1, Picopt: "sqlstore"},
+		{Checksumpicture: "D", Width: 800, Exifmodel: "iPhone", Albums: 1, Picopt: "sqlstore", Tags: "'favorite'"},
+		{Checksumpicture: "E", Width: 800, Tags: "'holiday'"},
+	}
+	decision := policy.Decide(pictures)
+	assert.Equal(t, "D", decision.Keep.Checksumpicture)
+	assert.Equal(t, "tag:favorite", decision.Rule)
+	assert.Len(t, decision.TagMap, 0)
+	for _, p := range pictures {
+		assert.Equal(t, p.Checksumpicture != "D" && p.Checksumpicture != "E", p.delete, p.Checksumpicture)
+	}
+
+	assert.NoError(t, os.WriteFile(file, []byte("prefer:\n - newest\n"), 0644))
+	_, err = LoadRetentionPolicy(file)
+	assert.Error(t, err)
+}
+
+func TestQuoteTag(t *testing.T) {
+	assert.Equal(t, "'holiday'", quoteTag("'holiday'"))
+	assert.Equal(t, "'Anna''s birthday'", quoteTag("Anna's birthday"))
+}
@@ -0,0 +1,109 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// DefaultColorShare minimal percentage of the dominant colour palette the searched colour needs
+const DefaultColorShare = 40
+
+type SearchParameter struct {
+	Color    string
+	MinShare int
+	Limit    int
+	Json     bool
+}
+
+type colorMatch struct {
+	Checksumpicture string
+	Title           string
+	AverageColor    string
+	Share           int
+}
+
+// Search search pictures which are mostly of the given colour
+func Search(parameter *SearchParameter) error {
+	if parameter.Color == "" {
+		return fmt.Errorf("search color not given")
+	}
+	if parameter.MinShare <= 0 {
+		parameter.MinShare = DefaultColorShare
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	matches := make([]*colorMatch, 0)
+	q := &common.Query{TableName: "Pictures",
+		DataStruct: &store.Pictures{},
+		Fields:     []string{"checksumpicture", "title", "averagecolor", "dominantcolors"},
+		Search:     "dominantcolors IS NOT NULL AND markdelete = false",
+	}
+	_, err = id.Query(q, func(search *common.Query, result *common.Result) error {
+		pic := result.Data.(*store.Pictures)
+		share := store.ColorShare(pic.DominantColors, parameter.Color)
+		if share >= parameter.MinShare {
+			matches = append(matches, &colorMatch{pic.ChecksumPicture, pic.Title, pic.AverageColor, share})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Log.Errorf("Error color search query: %v", err)
+		fmt.Println("Error color search query ...:", err)
+		return err
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Share > matches[j].Share
+	})
+	if parameter.Limit > 0 && len(matches) > parameter.Limit {
+		matches = matches[:parameter.Limit]
+	}
+	if parameter.Json {
+		fmt.Printf("\"Pictures\":[")
+	}
+	for i, m := range matches {
+		if parameter.Json {
+			if i > 0 {
+				fmt.Printf(",")
+			}
+			fmt.Printf("{\"Checksumpicture\":\"%s\",\"Title\":%q,\"AverageColor\":\"%s\",\"Share\":%d}",
+				m.Checksumpicture, m.Title, m.AverageColor, m.Share)
+		} else {
+			fmt.Printf("%s %3d%% %s %s\n", m.Checksumpicture, m.Share, m.AverageColor, m.Title)
+		}
+	}
+	if parameter.Json {
+		fmt.Printf("],\"Found\":%d,", len(matches))
+	} else {
+		fmt.Printf("Found %d pictures mostly %s\n", len(matches), parameter.Color)
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+	"github.com/tknie/services"
+
+	"github.com/corona10/goimagehash"
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// DefaultVideoFrames number of keyframes hashed per video
+const DefaultVideoFrames = 8
+
+// DefaultVideoDistance maximal average Hamming distance of the frame hashes
+const DefaultVideoDistance = 10
+
+// width the keyframes are scaled to before hashing
+const videoHashWidth = 160
+
+// durations may differ by this many seconds or by this fraction
+// of the duration after re-encoding
+const (
+	videoDurationTolerance  = 1.0
+	videoDurationToleranceF = 0.02
+)
+
+const readVideoHashs = `
+SELECT vh.checksumpicture, vh.duration, vh.hashes
+  FROM videohash vh
+  WHERE EXISTS ( SELECT 1
+           FROM pictures pp
+          WHERE pp.checksumpicture::text = vh.checksumpicture::text AND pp.markdelete = false)
+`
+
+type VideoHashParameter struct {
+	ChkSum string
+	Frames int
+	Limit  int
+	All    bool
+	Commit bool
+}
+
+// VideoFingerprint perceptual hashes of evenly spaced keyframes and the duration
+type VideoFingerprint struct {
+	Checksumpicture string
+	Duration        float64
+	Hashes          []uint64
+}
+
+type videoHashRow struct {
+	Checksumpicture string
+	Duration        float64
+	Hashes          string
+}
+
+type videoHashGenerate struct {
+	id        common.RegDbID
+	parameter *VideoHashParameter
+	generated uint64
+	failed    uint64
+}
+
+// ComputeVideoFingerprint extract the keyframes with ffmpeg and hash them. The
+// keyframes are taken at relative positions, so re-encoded copies are compared
+// at the same positions.
+func ComputeVideoFingerprint(fileName string, frames int) (*VideoFingerprint, error) {
+	duration, err := VideoDuration(fileName)
+	if err != nil {
+		return nil, err
+	}
+	fp := &VideoFingerprint{Duration: duration}
+	for _, t := range frameTimes(duration, frames) {
+		img, err := extractVideoFrame(fileName, t, videoHashWidth)
+		if err != nil {
+			return nil, err
+		}
+		h, err := goimagehash.PerceptionHash(img)
+		if err != nil {
+			return nil, err
+		}
+		fp.Hashes = append(fp.Hashes, h.GetHash())
+	}
+	return fp, nil
+}
+
+// durationMatch check if the durations are equal within the re-encoding tolerance
+func durationMatch(a, b float64) bool {
+	tolerance := math.Max(videoDurationTolerance, videoDurationToleranceF*math.Max(a, b))
+	return math.Abs(a-b) <= tolerance
+}
+
+// distance average Hamming distance of the frame hashes, -1 if the
+// fingerprints can not be compared
+func (fp *VideoFingerprint) distance(other *VideoFingerprint) float64 {
+	if len(fp.Hashes) == 0 || len(fp.Hashes) != len(other.Hashes) {
+		return -1
+	}
+	sum := 0
+	for i, h := range fp.Hashes {
+		sum += HammingDistance(h, other.Hashes[i])
+	}
+	return float64(sum) / float64(len(fp.Hashes))
+}
+
+// Similar compare two fingerprints, the durations need to match and the
+// average Hamming distance of the frame hashes need to be within maxDistance
+func (fp *VideoFingerprint) Similar(other *VideoFingerprint, maxDistance int) bool {
+	if !durationMatch(fp.Duration, other.Duration) {
+		return false
+	}
+	d := fp.distance(other)
+	return d >= 0 && d <= float64(maxDistance)
+}
+
+// VideoClusters group all similar videos. Clusters use complete linkage
+// like NearDuplicateClusters: each video is similar to all other videos of
+// the cluster, so a chain of similar videos is not merged into one cluster.
+func VideoClusters(fingerprints []*VideoFingerprint, maxDistance int) [][]*VideoFingerprint {
+	sort.SliceStable(fingerprints, func(i, j int) bool { return fingerprints[i].Duration < fingerprints[j].Duration })
+	cluster := make([]int, len(fingerprints))
+	for i := range cluster {
+		cluster[i] = -1
+	}
+	for i, fp := range fingerprints {
+		if cluster[i] != -1 {
+			continue
+		}
+		cluster[i] = i
+		// sorted by duration, only videos within the duration tolerance need to be compared
+		candidates := make([]int, 0)
+		for j := i + 1; j < len(fingerprints) && durationMatch(fp.Duration, fingerprints[j].Duration); j++ {
+			if cluster[j] == -1 && fp.Similar(fingerprints[j], maxDistance) {
+				candidates = append(candidates, j)
+			}
+		}
+		sort.SliceStable(candidates, func(x, y int) bool {
+			return fp.distance(fingerprints[candidates[x]]) < fp.distance(fingerprints[candidates[y]])
+		})
+		members := []int{i}
+		for _, j := range candidates {
+			similar := true
+			for _, m := range members[1:] {
+				if !fingerprints[m].Similar(fingerprints[j], maxDistance) {
+					similar = false
+					break
+				}
+			}
+			if similar {
+				cluster[j] = i
+				members = append(members, j)
+			}
+		}
+	}
+	groups := make(map[int][]*VideoFingerprint)
+	for i, fp := range fingerprints {
+		groups[cluster[i]] = append(groups[cluster[i]], fp)
+	}
+	clusters := make([][]*VideoFingerprint, 0)
+	for _, g := range groups {
+		if len(g) > 1 {
+			sort.Slice(g, func(x, y int) bool { return g[x].Checksumpicture < g[y].Checksumpicture })
+			clusters = append(clusters, g)
+		}
+	}
+	sort.Slice(clusters, func(x, y int) bool {
+		if len(clusters[x]) != len(clusters[y]) {
+			return len(clusters[x]) > len(clusters[y])
+		}
+		return clusters[x][0].Checksumpicture < clusters[y][0].Checksumpicture
+	})
+	return clusters
+}
+
+func formatVideoHashes(hashes []uint64) string {
+	list := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		list = append(list, fmt.Sprintf("%016x", h))
+	}
+	return strings.Join(list, ",")
+}
+
+func parseVideoHashes(hashes string) ([]uint64, error) {
+	list := make([]uint64, 0)
+	for _, h := range strings.Split(hashes, ",") {
+		v, err := strconv.ParseUint(h, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// VideoHash generate fingerprints of all videos
+func VideoHash(parameter *VideoHashParameter) error {
+	if parameter.Frames <= 0 {
+		parameter.Frames = DefaultVideoFrames
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+	wid, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer wid.FreeHandler()
+
+	limit := "ALL"
+	if parameter.Limit > 0 {
+		limit = strconv.Itoa(parameter.Limit)
+	}
+	search := "lower(mimetype) LIKE 'video%' AND markdelete = false"
+	if !parameter.All {
+		search += " AND NOT EXISTS(SELECT 1 FROM videohash vh WHERE vh.checksumpicture = pictures.checksumpicture)"
+	}
+	if parameter.ChkSum != "" {
+		search = fmt.Sprintf("checksumpicture = '%s' AND ", parameter.ChkSum) + search
+	}
+	gen := &videoHashGenerate{id: wid, parameter: parameter}
+	q := &common.Query{TableName: "Pictures",
+		DataStruct:   &store.Pictures{},
+		Fields:       []string{"MIMEType", "title", "checksumpicture", "Media", "picopt"},
+		Search:       search,
+		Limit:        limit,
+		FctParameter: gen,
+	}
+	_, err = id.Query(q, generateVideoHash)
+	if err != nil {
+		log.Log.Errorf("Error video hash query: %v", err)
+		fmt.Println("Error video hash query ...:", err)
+		return err
+	}
+	hashOutput(nil, fmt.Sprintf("Video fingerprints generated=%d failed=%d", gen.generated, gen.failed))
+	return nil
+}
+
+func generateVideoHash(search *common.Query, result *common.Result) error {
+	gen := search.FctParameter.(*videoHashGenerate)
+	pic := result.Data.(*store.Pictures)
+	title, err := mediaFile(pic)
+	if err != nil {
+		gen.failed++
+		return nil
+	}
+	defer removeTempMedia(title)
+	fp, err := ComputeVideoFingerprint(title, gen.parameter.Frames)
+	if err != nil {
+		hashOutput(pic, fmt.Sprintf("Error generating video hash for %s/%s: %v\n", pic.Title, pic.ChecksumPicture, err))
+		log.Log.Errorf("Error generating video hash for %s/%s: %v", pic.Title, pic.ChecksumPicture, err)
+		gen.failed++
+		return nil
+	}
+	fmt.Printf("%s -> %s (%.1fs)\n", pic.Title, pic.ChecksumPicture, fp.Duration)
+	gen.generated++
+	if !gen.parameter.Commit {
+		return nil
+	}
+	err = gen.id.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	_, err = gen.id.Delete("videohash", &common.Entries{
+		Criteria: fmt.Sprintf("checksumpicture = '%s'", pic.ChecksumPicture)})
+	if err != nil {
+		gen.id.Rollback()
+		return err
+	}
+	insert := &common.Entries{
+		Fields: []string{"checksumpicture", "duration", "frames", "hashes"},
+		Values: [][]any{{pic.ChecksumPicture, fp.Duration, len(fp.Hashes), formatVideoHashes(fp.Hashes)}},
+	}
+	_, err = gen.id.Insert("videohash", insert)
+	if err != nil {
+		gen.id.Rollback()
+		fmt.Printf("Error inserting video hash %s/%s: %v\n", pic.Title, pic.ChecksumPicture, err)
+		return err
+	}
+	return gen.id.Commit()
+}
+
+// readVideoFingerprints read fingerprints of all videos not marked deleted
+func readVideoFingerprints(id common.RegDbID) ([]*VideoFingerprint, error) {
+	fingerprints := make([]*VideoFingerprint, 0)
+	query := &common.Query{
+		TableName:  "videohash",
+		DataStruct: &videoHashRow{},
+		Search:     readVideoHashs,
+	}
+	err := id.BatchSelectFct(query, func(search *common.Query, result *common.Result) error {
+		row := result.Data.(*videoHashRow)
+		hashes, err := parseVideoHashes(row.Hashes)
+		if err != nil {
+			log.Log.Errorf("Error parsing video hash of %s: %v", row.Checksumpicture, err)
+			return nil
+		}
+		fingerprints = append(fingerprints, &VideoFingerprint{Checksumpicture: row.Checksumpicture,
+			Duration: row.Duration, Hashes: hashes})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fingerprints, nil
+}
+
+// videoDuplicateClean search clusters of similar videos and resolve them
+// like picture duplicates
+func (parameter *HashCleanParameter) videoDuplicateClean() error {
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("POSTGRES error", err)
+		return err
+	}
+	defer id.FreeHandler()
+
+	fingerprints, err := readVideoFingerprints(id)
+	if err != nil {
+		fmt.Println("Error query video hashes:", err)
+		return err
+	}
+	distance := parameter.Distance
+	if distance <= 0 {
+		distance = DefaultVideoDistance
+	}
+	clusters := VideoClusters(fingerprints, distance)
+	services.ServerMessage("Found %d video clusters in %d videos with distance %d",
+		len(clusters), len(fingerprints), distance)
+	for i, cluster := range clusters {
+		if parameter.Limit > 0 && i >= parameter.Limit {
+			break
+		}
+		checksums := make([]string, 0, len(cluster))
+		for _, fp := range cluster {
+			checksums = append(checksums, fp.Checksumpicture)
+		}
+		fmt.Printf("Working on %d.Video cluster with %d videos: %s\n", i+1, len(cluster), strings.Join(checksums, ","))
+		sqlCmd, err := templateSql(readPictureByChecksums, checksums)
+		if err != nil {
+			return err
+		}
+		picturesByHash, err := readPicturesByHash(id, sqlCmd)
+		if err != nil {
+			return err
+		}
+		err = parameter.resolvePictures(fmt.Sprintf("video cluster %d", i+1), picturesByHash)
+		if err != nil {
+			fmt.Println("Error resolving video cluster:", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVideoHashesFormat(t *testing.T) {
+	hashes := []uint64{0x0, 0xFFFF0000FFFF0000, 0x1234}
+	s := formatVideoHashes(hashes)
+	assert.Equal(t, "0000000000000000,ffff0000ffff0000,0000000000001234", s)
+	parsed, err := parseVideoHashes(s)
+	assert.NoError(t, err)
+	assert.Equal(t, hashes, parsed)
+	_, err = parseVideoHashes("xyz")
+	assert.Error(t, err)
+}
+
+func TestVideoClusters(t *testing.T) {
+	fps := []*VideoFingerprint{
+		{Checksumpicture: "A", Duration: 60.0, Hashes: []uint64{0xF0, 0xFF00}},
+		// re-encoded copy, slightly different duration and hashes
+		{Checksumpicture: "B", Duration: 60.5, Hashes: []uint64{0xF1, 0xFF03}},
+		// same frames but too different duration
+		{Checksumpicture: "C", Duration: 75.0, Hashes: []uint64{0xF0, 0xFF00}},
+		// same duration but different content
+		{Checksumpicture: "D", Duration: 60.0, Hashes: []uint64{0xFFFFFFFF, 0xFFFF00000000}},
+		{Checksumpicture: "E", Duration: 60.0, Hashes: []uint64{0xF0}},
+	}
+	assert.True(t, fps[0].Similar(fps[1], 2))
+	assert.False(t, fps[0].Similar(fps[2], 2))
+	assert.False(t, fps[0].Similar(fps[4], 2))
+	clusters := VideoClusters(fps, 2)
+	assert.Len(t, clusters, 1)
+	assert.Len(t, clusters[0], 2)
+	assert.Equal(t, "A", clusters[0][0].Checksumpicture)
+	assert.Equal(t, "B", clusters[0][1].Checksumpicture)
+}
+
+func TestVideoClustersChain(t *testing.T) {
+	// A~B and B~C but A and C are not similar
+	fps := []*VideoFingerprint{
+		{Checksumpicture: "A", Duration: 60.0, Hashes: []uint64{0x0}},
+		{Checksumpicture: "B", Duration: 60.0, Hashes: []uint64{0x3}},
+		{Checksumpicture: "C", Duration: 60.0, Hashes: []uint64{0xf}},
+	}
+	assert.True(t, fps[0].Similar(fps[1], 2))
+	assert.True(t, fps[1].Similar(fps[2], 2))
+	assert.False(t, fps[0].Similar(fps[2], 2))
+	clusters := VideoClusters(fps, 2)
+	if assert.Len(t, clusters, 1) {
+		assert.Len(t, clusters[0], 2)
+		assert.Equal(t, "A", clusters[0][0].Checksumpicture)
+		assert.Equal(t, "B", clusters[0][1].Checksumpicture)
+	}
+}
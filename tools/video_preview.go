@@ -0,0 +1,267 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+const previewWidth = 320
+const spriteTileWidth = 160
+const spriteColumns = 10
+
+// previewDelay delay between preview frames in 100th of a second
+const previewDelay = 50
+
+// SpriteManifest timing manifest of the sprite sheet, each frame
+// references the tile position inside the sprite sheet
+type SpriteManifest struct {
+	Duration   float64       `json:"duration"`
+	Interval   float64       `json:"interval"`
+	Columns    int           `json:"columns"`
+	Rows       int           `json:"rows"`
+	TileWidth  int           `json:"tileWidth"`
+	TileHeight int           `json:"tileHeight"`
+	Frames     []SpriteFrame `json:"frames"`
+}
+
+// SpriteFrame sprite sheet tile shown from the given time (seconds)
+type SpriteFrame struct {
+	Time float64 `json:"time"`
+	X    int     `json:"x"`
+	Y    int     `json:"y"`
+}
+
+// VideoDuration evaluate video duration in seconds using ffprobe
+func VideoDuration(fileName string) (float64, error) {
+	args := []string{"-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", fileName}
+	var errBuffer bytes.Buffer
+	c := exec.Command("ffprobe", args...)
+	c.Stderr = &errBuffer
+	out, err := c.Output()
+	if err != nil {
+		log.Log.Errorf("Error ffprobe duration: %v\nOutput: %s", err, errBuffer.String())
+		return 0, err
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid video duration %q: %v", strings.TrimSpace(string(out)), err)
+	}
+	return duration, nil
+}
+
+// frameTimes evenly spaced frame positions, taken in the middle of each
+// of the count segments of the video
+func frameTimes(duration float64, count int) []float64 {
+	times := make([]float64, count)
+	for i := range times {
+		times[i] = duration * (float64(i) + 0.5) / float64(count)
+	}
+	return times
+}
+
+// extractVideoFrame use ffmpeg to extract one frame at given second scaled to width
+func extractVideoFrame(fileName string, second float64, width int) (image.Image, error) {
+	args := []string{"-ss", strconv.FormatFloat(second, 'f', 3, 64), "-i", fileName,
+		"-vf", fmt.Sprintf("scale=iw*sar:ih,scale=%d:-2", width),
+		"-frames:v", "1", "-f", "image2pipe", "-vcodec", "mjpeg", "-"}
+	log.Log.Debugf("Start ffmpeg with arguments: %v", args)
+	var errBuffer bytes.Buffer
+	c := exec.Command("ffmpeg", args...)
+	c.Stderr = &errBuffer
+	out, err := c.Output()
+	if err != nil {
+		log.Log.Errorf("Error extracting frame at %f: %v\nOutput: %s", second, err, errBuffer.String())
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no frame at second %f", second)
+	}
+	return jpeg.Decode(bytes.NewReader(out))
+}
+
+// extractVideoFrames extract count evenly spaced frames of the video
+// scaled to width. All frames are taken by one ffmpeg call, the fps
+// filter starting in the middle of the first segment selects one frame
+// per segment.
+func extractVideoFrames(fileName string, count, width int) ([]image.Image, []float64, float64, error) {
+	duration, err := VideoDuration(fileName)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if duration <= 0 || count <= 0 {
+		return nil, nil, 0, fmt.Errorf("no video frames extracted")
+	}
+	times := frameTimes(duration, count)
+	args := []string{"-ss", strconv.FormatFloat(times[0], 'f', 3, 64), "-i", fileName,
+		"-vf", fmt.Sprintf("fps=%d/%s,scale=iw*sar:ih,scale=%d:-2", count,
+			strconv.FormatFloat(duration, 'f', 3, 64), width),
+		"-frames:v", strconv.Itoa(count), "-f", "image2pipe", "-vcodec", "mjpeg", "-"}
+	log.Log.Debugf("Start ffmpeg with arguments: %v", args)
+	var errBuffer bytes.Buffer
+	c := exec.Command("ffmpeg", args...)
+	c.Stderr = &errBuffer
+	out, err := c.Output()
+	if err != nil {
+		log.Log.Errorf("Error extracting frames: %v\nOutput: %s", err, errBuffer.String())
+		return nil, nil, 0, err
+	}
+	frames := make([]image.Image, 0, count)
+	taken := make([]float64, 0, count)
+	for i, data := range splitJPEGStream(out) {
+		if i >= count {
+			break
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			log.Log.Debugf("Error decoding frame %d: %v", i, err)
+			continue
+		}
+		frames = append(frames, img)
+		taken = append(taken, times[i])
+	}
+	if len(frames) == 0 {
+		return nil, nil, 0, fmt.Errorf("no video frames extracted")
+	}
+	return frames, taken, duration, nil
+}
+
+// splitJPEGStream split the MJPEG output of ffmpeg into the single JPEG
+// images, each image ends with the EOI marker
+func splitJPEGStream(data []byte) [][]byte {
+	images := make([][]byte, 0)
+	for len(data) > 0 {
+		start := bytes.Index(data, []byte{0xff, 0xd8})
+		if start < 0 {
+			break
+		}
+		data = data[start:]
+		end := bytes.Index(data, []byte{0xff, 0xd9})
+		if end < 0 {
+			break
+		}
+		images = append(images, data[:end+2])
+		data = data[end+2:]
+	}
+	return images
+}
+
+// CreateAnimatedPreview create a looping GIF out of the frames
+func CreateAnimatedPreview(frames []image.Image) ([]byte, error) {
+	anim := &gif.GIF{LoopCount: 0}
+	for _, frame := range frames {
+		b := frame.Bounds()
+		p := image.NewPaletted(b, palette.Plan9)
+		draw.FloydSteinberg.Draw(p, b, frame, b.Min)
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, previewDelay)
+	}
+	var buffer bytes.Buffer
+	err := gif.EncodeAll(&buffer, anim)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// CreateSpriteSheet put all frames as tiles into one JPEG sprite sheet
+// and generate the corresponding timing manifest
+func CreateSpriteSheet(frames []image.Image, times []float64, duration float64) ([]byte, *SpriteManifest, error) {
+	if len(frames) == 0 {
+		return nil, nil, fmt.Errorf("no frames for sprite sheet")
+	}
+	tw := frames[0].Bounds().Dx()
+	th := frames[0].Bounds().Dy()
+	columns := spriteColumns
+	if len(frames) < columns {
+		columns = len(frames)
+	}
+	rows := (len(frames) + columns - 1) / columns
+	manifest := &SpriteManifest{Duration: duration, Interval: duration / float64(len(frames)),
+		Columns: columns, Rows: rows, TileWidth: tw, TileHeight: th}
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*tw, rows*th))
+	for i, frame := range frames {
+		x := (i % columns) * tw
+		y := (i / columns) * th
+		draw.Draw(sheet, image.Rect(x, y, x+tw, y+th), frame, frame.Bounds().Min, draw.Src)
+		manifest.Frames = append(manifest.Frames, SpriteFrame{Time: times[i], X: x, Y: y})
+	}
+	var buffer bytes.Buffer
+	err := jpeg.Encode(&buffer, sheet, &jpeg.Options{Quality: jpeg.DefaultQuality})
+	if err != nil {
+		return nil, nil, err
+	}
+	return buffer.Bytes(), manifest, nil
+}
+
+// storeVideoPreview generate the animated preview rendition
+func storeVideoPreview(id common.RegDbID, fileName, checksum string, count int) error {
+	frames, _, _, err := extractVideoFrames(fileName, count, previewWidth)
+	if err != nil {
+		return err
+	}
+	data, err := CreateAnimatedPreview(frames)
+	if err != nil {
+		return err
+	}
+	b := frames[0].Bounds()
+	return sql.StoreRendition(id, &sql.Rendition{ChecksumPicture: checksum,
+		Kind: sql.RenditionPreview, MIMEType: "image/gif",
+		Width: b.Dx(), Height: b.Dy(), Media: data})
+}
+
+// storeVideoSprite generate the sprite sheet and timing manifest renditions
+func storeVideoSprite(id common.RegDbID, fileName, checksum string, count int) error {
+	frames, times, duration, err := extractVideoFrames(fileName, count, spriteTileWidth)
+	if err != nil {
+		return err
+	}
+	data, manifest, err := CreateSpriteSheet(frames, times, duration)
+	if err != nil {
+		return err
+	}
+	err = sql.StoreRendition(id, &sql.Rendition{ChecksumPicture: checksum,
+		Kind: sql.RenditionSprite, MIMEType: "image/jpeg",
+		Width:  manifest.Columns * manifest.TileWidth,
+		Height: manifest.Rows * manifest.TileHeight, Media: data})
+	if err != nil {
+		return err
+	}
+	m, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return sql.StoreRendition(id, &sql.Rendition{ChecksumPicture: checksum,
+		Kind: sql.RenditionSpriteManifest, MIMEType: "application/json", Media: m})
+}
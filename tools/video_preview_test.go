@@ -0,0 +1,54 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+
+package tools
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitJPEGStream(t *testing.T) {
+	var stream bytes.Buffer
+	for _, c := range []color.Gray{{Y: 0}, {Y: 128}, {Y: 255}} {
+		img := image.NewGray(image.Rect(0, 0, 16, 8))
+		for i := range img.Pix {
+			img.Pix[i] = c.Y
+		}
+		assert.NoError(t, jpeg.Encode(&stream, img, nil))
+	}
+	images := splitJPEGStream(stream.Bytes())
+	assert.Len(t, images, 3)
+	for _, data := range images {
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if assert.NoError(t, err) {
+			assert.Equal(t, 16, img.Bounds().Dx())
+		}
+	}
+	assert.Empty(t, splitJPEGStream([]byte("no jpeg")))
+}
+
+func TestFrameTimes(t *testing.T) {
+	assert.Equal(t, []float64{1, 3, 5, 7, 9}, frameTimes(10, 5))
+}
@@ -0,0 +1,252 @@
+/*
+* Copyright © 2026 private, Darmstadt, Germany and/or its licensors
+*
+* SPDX-License-Identifier: Apache-2.0
+*
+*   Licensed under the Apache License, Version 2.0 (the "License");
+*   you may not use this file except in compliance with the License.
+*   You may obtain a copy of the License at
+*
+*       http://www.apache.org/licenses/LICENSE-2.0
+*
+*   Unless required by applicable law or agreed to in writing, software
+*   distributed under the License is distributed on an "AS IS" BASIS,
+*   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*   See the License for the specific language governing permissions and
+*   limitations under the License.
+*
+ */
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/tknie/bitgartentools/sql"
+	"github.com/tknie/bitgartentools/store"
+
+	"github.com/tknie/flynn/common"
+	"github.com/tknie/log"
+)
+
+// DefaultProxyResolution default maximal width or height of the video proxy
+const DefaultProxyResolution = 1280
+
+const proxySuffix = "_proxy.mp4"
+
+// selectAlbumProxy videos of the album, the conditions and the limit are
+// the same as of the search without album
+const selectAlbumProxy = `SELECT Pictures.ChecksumPicture,MIMEType,Title,picopt,Media FROM Pictures
+  WHERE Pictures.checksumpicture IN ( SELECT ap.checksumpicture FROM albumpictures ap, albums a
+          WHERE ap.albumid = a.id AND a.title = $1)
+  AND %s ORDER BY Pictures.checksumpicture LIMIT %s`
+
+type VideoProxyParameter struct {
+	Title         string
+	ChkSum        string
+	MaxResolution int
+	Limit         int
+	All           bool
+	Commit        bool
+	// Privacy profile filtering GPS and serial numbers of the proxies
+	Privacy *PrivacyProfile
+}
+
+type videoProxyGenerate struct {
+	id        common.RegDbID
+	parameter *VideoProxyParameter
+	generated uint64
+	failed    uint64
+}
+
+// VideoProxyName name of the web-friendly proxy of the original media in the webstore
+func VideoProxyName(checksum string) string {
+	return checksum + proxySuffix
+}
+
+// VideoProxy transcode all videos into H.264/AAC MP4 proxies which can be
+// played by all browsers
+func VideoProxy(parameter *VideoProxyParameter) error {
+	if parameter.MaxResolution <= 0 {
+		parameter.MaxResolution = DefaultProxyResolution
+	}
+	id, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer id.FreeHandler()
+	wid, err := sql.DatabaseHandler()
+	if err != nil {
+		fmt.Println("Error connect ...:", err)
+		return err
+	}
+	defer wid.FreeHandler()
+
+	limit := "ALL"
+	if parameter.Limit > 0 {
+		limit = strconv.Itoa(parameter.Limit)
+	}
+	gen := &videoProxyGenerate{id: wid, parameter: parameter}
+	q := &common.Query{TableName: "Pictures",
+		DataStruct:   &store.Pictures{},
+		Fields:       []string{"MIMEType", "title", "checksumpicture", "Media", "picopt"},
+		Limit:        limit,
+		FctParameter: gen,
+	}
+	search := "lower(MIMEType) LIKE 'video%' AND markdelete = false"
+	if !parameter.All {
+		search += " AND proxychecksum IS NULL"
+	}
+	if parameter.Title != "" {
+		q.Search = fmt.Sprintf(selectAlbumProxy, search, limit)
+		q.Parameters = []any{parameter.Title}
+		q.Limit = ""
+		err = id.BatchSelectFct(q, generateQueryVideoProxy)
+	} else {
+		if parameter.ChkSum != "" {
+			search = fmt.Sprintf("checksumpicture = '%s' AND ", parameter.ChkSum) + search
+		}
+		q.Search = search
+		_, err = id.Query(q, generateQueryVideoProxy)
+	}
+	if err != nil {
+		log.Log.Errorf("Error video proxy query: %v", err)
+		fmt.Println("Error video proxy query ...:", err)
+		return err
+	}
+	fmt.Printf("Video proxies generated=%d failed=%d\n", gen.generated, gen.failed)
+	return nil
+}
+
+func generateQueryVideoProxy(search *common.Query, result *common.Result) error {
+	gen := search.FctParameter.(*videoProxyGenerate)
+	pic := result.Data.(*store.Pictures)
+	title, err := mediaFile(pic)
+	if err != nil {
+		gen.failed++
+		return nil
+	}
+	defer removeTempMedia(title)
+	decision, err := gen.parameter.Privacy.readPrivacyDecision(gen.id, pic.ChecksumPicture)
+	if err != nil {
+		fmt.Printf("Error reading privacy of %s: %v\n", pic.ChecksumPicture, err)
+		gen.failed++
+		return nil
+	}
+	err = gen.storeProxy(pic.ChecksumPicture, title, decision)
+	if err != nil {
+		fmt.Printf("Error generating proxy %s: %v\n", pic.ChecksumPicture, err)
+		gen.failed++
+		return nil
+	}
+	gen.generated++
+	return nil
+}
+
+// storeProxy transcode the given file, upload the proxy to the webstore
+// and register the proxy checksum at the original picture. The privacy
+// decision is applied to the proxy metadata.
+func (gen *videoProxyGenerate) storeProxy(checksum, fileName string, decision *PrivacyDecision) error {
+	proxy, err := TranscodeVideoProxy(fileName, gen.parameter.MaxResolution,
+		decision.GPS == PrivacyStrip || decision.StripSerial)
+	if err != nil {
+		return err
+	}
+	proxy, err = FilterPrivacy(proxy, decision)
+	if err != nil {
+		return err
+	}
+	proxyChecksum := store.CreateMd5(proxy)
+	fmt.Printf("Proxy %s -> %s (%d bytes)\n", checksum, proxyChecksum, len(proxy))
+	if !gen.parameter.Commit {
+		return nil
+	}
+	err = sql.StoreRestClient(VideoProxyName(checksum), proxy)
+	if err != nil {
+		log.Log.Errorf("Error storing proxy %s: %v", checksum, err)
+		return err
+	}
+	input := &common.Entries{
+		Fields: []string{"proxychecksum"},
+		Values: [][]any{{proxyChecksum}},
+		Update: []string{fmt.Sprintf("checksumpicture = '%s'", checksum)},
+	}
+	_, n, err := gen.id.Update("Pictures", input)
+	if err != nil {
+		log.Log.Errorf("Update proxy checksum problem: %v", err)
+		return err
+	}
+	log.Log.Debugf("Update proxy checksum n=%d", n)
+	return gen.id.Commit()
+}
+
+// TranscodeVideoProxy use ffmpeg to transcode the video file into H.264/AAC MP4
+// with the longest edge limited to maxResolution, stripMetadata drops all
+// metadata of the original like location and camera
+func TranscodeVideoProxy(fileName string, maxResolution int, stripMetadata bool) ([]byte, error) {
+	proxyFile := fileName + proxySuffix
+	scale := fmt.Sprintf("scale=w='min(%d,iw)':h='min(%d,ih)':force_original_aspect_ratio=decrease:force_divisible_by=2",
+		maxResolution, maxResolution)
+	args := []string{"-y", "-i", fileName, "-vf", scale,
+		"-c:v", "libx264", "-preset", "medium", "-crf", "23", "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", "128k", "-movflags", "+faststart"}
+	if stripMetadata {
+		args = append(args, "-map_metadata", "-1")
+	}
+	args = append(args, proxyFile)
+	log.Log.Debugf("Start ffmpeg with arguments: %v", args)
+	var cBuffer bytes.Buffer
+	c := exec.Command("ffmpeg", args...)
+	c.Stdout = &cBuffer
+	c.Stderr = &cBuffer
+	err := c.Run()
+	defer removeTempMedia(proxyFile)
+	if err != nil {
+		log.Log.Errorf("Error transcoding proxy: %v\nOutput: %s", err, cBuffer.String())
+		return nil, fmt.Errorf("ffmpeg proxy transcode failed: %v", err)
+	}
+	return os.ReadFile(proxyFile)
+}
+
+func tempMediaName(pic *store.Pictures) string {
+	logpath := os.Getenv("LOGPATH")
+	if logpath == "" {
+		logpath = "."
+	}
+	return logpath + "/" + pic.ChecksumPicture + "-" + safeFileName(pic.Title)
+}
+
+// mediaFile write the media of the picture into a temporary file, media
+// stored in the webstore is downloaded
+func mediaFile(pic *store.Pictures) (string, error) {
+	title := tempMediaName(pic)
+	switch pic.PicOpt {
+	case "sqlstore":
+		err := os.WriteFile(title, pic.Media, 0644)
+		if err != nil {
+			fmt.Println("Error writing file:", err)
+			return "", err
+		}
+	case "webstore":
+		err := sql.DownloadToTitle(pic.ChecksumPicture, title)
+		if err != nil {
+			fmt.Println("Error download title:", err)
+			return "", err
+		}
+	default:
+		fmt.Println("Picture not in sqlstore or webstore:", pic.PicOpt)
+		return "", fmt.Errorf("picture not in sqlstore or webstore: %s", pic.PicOpt)
+	}
+	return title, nil
+}
+
+func removeTempMedia(fileName string) {
+	err := os.Remove(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error removing:", err)
+	}
+}
@@ -49,17 +49,21 @@ var gid = common.RegDbID(0)
 
 const SELECT_ALBUM = `with albumIdSelect(Id) as ( SELECT Id FROM Albums WHERE Title = '%s'), checksumSelect as (  
 	SELECT ChecksumPicture FROM AlbumPictures, albumIdSelect WHERE albumid = albumIdSelect.Id AND MIMEType LIKE 'video%%')
-	SELECT Pictures.ChecksumPicture,MIMEType,Media FROM Pictures, checksumSelect WHERE Pictures.checksumpicture = checksumSelect.ChecksumPicture`
+	SELECT Pictures.ChecksumPicture,MIMEType,Title,picopt,Thumbnail,Media FROM Pictures, checksumSelect WHERE Pictures.checksumpicture = checksumSelect.ChecksumPicture`
 
 type VideoThumbParameter struct {
-	Title  string
-	ChkSum string
-	Commit bool
+	Title         string
+	ChkSum        string
+	PreviewFrames int
+	SpriteFrames  int
+	Commit        bool
 }
 
 type VideoGenerateParameter struct {
-	id     common.RegDbID
-	commit bool
+	id            common.RegDbID
+	previewFrames int
+	spriteFrames  int
+	commit        bool
 }
 
 func VideoThumb(parameter *VideoThumbParameter) error {
@@ -76,8 +80,9 @@ func VideoThumb(parameter *VideoThumbParameter) error {
 	gid = id
 	q := &common.Query{TableName: "Pictures",
 		DataStruct: &store.Pictures{},
-		Fields:     []string{"MIMEType", "title", "checksumpicture", "Media", "picopt"},
+		Fields:     []string{"MIMEType", "title", "checksumpicture", "Thumbnail", "Media", "picopt"},
 		FctParameter: &VideoGenerateParameter{id: wid,
+			previewFrames: parameter.PreviewFrames, spriteFrames: parameter.SpriteFrames,
 			commit: parameter.Commit},
 	}
 	if parameter.Title != "" {
@@ -94,7 +99,14 @@ func VideoThumb(parameter *VideoThumbParameter) error {
 			return err
 		}
 	} else {
-		prefix := "lower(MIMEType) LIKE 'video%' AND thumbnail is NULL AND markdelete = false"
+		prefix := "lower(MIMEType) LIKE 'video%' AND markdelete = false AND (thumbnail is NULL"
+		if parameter.PreviewFrames > 0 {
+			prefix += missingRendition(sql.RenditionPreview)
+		}
+		if parameter.SpriteFrames > 0 {
+			prefix += missingRendition(sql.RenditionSprite)
+		}
+		prefix += ")"
 		if parameter.ChkSum != "" {
 			cprefix := fmt.Sprintf("checksumpicture = '%s' AND ", parameter.ChkSum)
 			prefix = cprefix + prefix
@@ -111,6 +123,11 @@ func VideoThumb(parameter *VideoThumbParameter) error {
 	return nil
 }
 
+// missingRendition search condition part for videos without given rendition
+func missingRendition(kind string) string {
+	return fmt.Sprintf(" OR NOT EXISTS (SELECT 1 FROM picturerenditions r WHERE r.checksumpicture = pictures.checksumpicture AND r.kind = '%s')", kind)
+}
+
 func generateQueryVideoThumbnail(search *common.Query, result *common.Result) error {
 	para := search.FctParameter.(*VideoGenerateParameter)
 	pic := result.Data.(*store.Pictures)
@@ -144,6 +161,16 @@ func generateVideoThumbnail(para *VideoGenerateParameter, pic *store.Pictures) e
 		log.Log.Fatalf("Picture not in sqlstore or webstore: %s", pic.PicOpt)
 		return fmt.Errorf("picture not in sqlstore: %s", pic.PicOpt)
 	}
+	defer func() {
+		err := os.Remove(title)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Println("Error removing:", err)
+		}
+	}()
+	para.generateRenditions(title, pic.ChecksumPicture)
+	if len(pic.Thumbnail) > 0 {
+		return nil
+	}
 	err := storeThumb(title, pic.ChecksumPicture, pic)
 	if err != nil {
 		if err == io.EOF {
@@ -178,14 +205,43 @@ func generateVideoThumbnail(para *VideoGenerateParameter, pic *store.Pictures) e
 			return err
 		}
 	}
-	err = os.Remove(title)
-	if err != nil && !os.IsNotExist(err) {
-		fmt.Println("Error removing:", err)
-		return err
-	}
 	return nil
 }
 
+// generateRenditions create the requested animated preview and sprite sheet
+// if they are missing, without commit only list what would be generated
+func (para *VideoGenerateParameter) generateRenditions(fileName, checksum string) {
+	kinds, err := sql.ReadRenditionKinds(para.id, checksum)
+	if err != nil {
+		fmt.Printf("Error reading renditions %s: %v\n", checksum, err)
+		return
+	}
+	if para.previewFrames > 0 && !kinds[sql.RenditionPreview] {
+		if !para.commit {
+			fmt.Println("Would generate animated preview", checksum)
+		} else {
+			fmt.Println("Generate animated preview", checksum)
+			err := storeVideoPreview(para.id, fileName, checksum, para.previewFrames)
+			if err != nil {
+				log.Log.Errorf("Error generating preview: %v", err)
+				fmt.Printf("Error generating preview %s: %v\n", checksum, err)
+			}
+		}
+	}
+	if para.spriteFrames > 0 && (!kinds[sql.RenditionSprite] || !kinds[sql.RenditionSpriteManifest]) {
+		if !para.commit {
+			fmt.Println("Would generate sprite sheet", checksum)
+		} else {
+			fmt.Println("Generate sprite sheet", checksum)
+			err := storeVideoSprite(para.id, fileName, checksum, para.spriteFrames)
+			if err != nil {
+				log.Log.Errorf("Error generating sprite sheet: %v", err)
+				fmt.Printf("Error generating sprite sheet %s: %v\n", checksum, err)
+			}
+		}
+	}
+}
+
 func searchTitle(title string, id common.RegDbID) string {
 	q := &common.Query{TableName: "Albums",
 		DataStruct: &sql.Albums{},